@@ -9,6 +9,7 @@ require (
 	github.com/muesli/go-app-paths v0.2.2
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/pflag v1.0.9
+	modernc.org/sqlite v1.39.0
 )
 
 require (
@@ -29,5 +30,4 @@ require (
 	modernc.org/libc v1.66.3 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.39.0 // indirect
 )
@@ -5,12 +5,26 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/mateconpizza/gmweb/internal/archive"
+	"github.com/mateconpizza/gmweb/internal/favicon"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/responder"
 	"github.com/mateconpizza/gmweb/internal/router"
+	"github.com/mateconpizza/gmweb/internal/wayback"
 )
 
-var ErrPathNotFound = errors.New("path not found")
+var (
+	ErrPathNotFound    = errors.New("path not found")
+	ErrWaybackDisabled = errors.New("wayback machine disabled")
+	ErrNoTags          = errors.New("no tags given")
+	ErrNoSelection     = errors.New("no bookmarks selected")
+	ErrUnknownOp       = errors.New("unknown operation")
+	ErrNoTargetRepo    = errors.New("no target repository given")
+	ErrBulkAborted     = errors.New("not applied, another bookmark failed")
+	ErrIDMismatch      = errors.New("id does not match the path")
+	ErrNotPatchable    = errors.New("field cannot be patched")
+)
 
 type HandlerOptFn func(*handlerOpt)
 
@@ -21,6 +35,12 @@ type handlerOpt struct {
 	cacheDir   string // dataDir path where the database are found.
 	logger     *slog.Logger
 	router     *router.Router
+	checker    *linkcheck.Checker
+	archiver   *archive.Archiver
+	scraper    *archive.Scraper
+	favicons   *favicon.Cache
+	wayback    *wayback.Client
+	client     *http.Client // client resolves the short links of the URL rules.
 }
 
 type Handler struct {
@@ -63,6 +83,44 @@ func WithRoutes(r *router.Router) HandlerOptFn {
 	}
 }
 
+func WithLinkChecker(c *linkcheck.Checker) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.checker = c
+	}
+}
+
+func WithArchiver(a *archive.Archiver) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.archiver = a
+	}
+}
+
+// WithScraper sets the scraper reading the metadata of new bookmarks, kept
+// apart from the archiver so it works with archiving disabled.
+func WithScraper(s *archive.Scraper) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.scraper = s
+	}
+}
+
+func WithFavicons(c *favicon.Cache) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.favicons = c
+	}
+}
+
+func WithWayback(c *wayback.Client) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.wayback = c
+	}
+}
+
+func WithClient(c *http.Client) HandlerOptFn {
+	return func(o *handlerOpt) {
+		o.client = c
+	}
+}
+
 func NewHandler(opts ...HandlerOptFn) *Handler {
 	ao := &handlerOpt{}
 	for _, opt := range opts {
@@ -80,6 +138,7 @@ func dbStats(r *http.Request, h *Handler, dbKey string) (*responder.RepoStatsRes
 		slog.Error("listing bookmarks", "error", err, "db", dbKey)
 		return nil, err
 	}
+	defer repo.Close()
 
 	return &responder.RepoStatsResponse{
 		Name:      dbKey,
@@ -3,24 +3,44 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mateconpizza/gm/pkg/bookmark"
 
+	"github.com/mateconpizza/gmweb/internal/archive"
+	"github.com/mateconpizza/gmweb/internal/database"
+	"github.com/mateconpizza/gmweb/internal/favicon"
+	"github.com/mateconpizza/gmweb/internal/fetch"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/middleware"
 	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/models/mocks"
+	"github.com/mateconpizza/gmweb/internal/preview"
 	"github.com/mateconpizza/gmweb/internal/responder"
 	"github.com/mateconpizza/gmweb/internal/router"
+	"github.com/mateconpizza/gmweb/internal/search"
+	"github.com/mateconpizza/gmweb/internal/tags"
+	"github.com/mateconpizza/gmweb/internal/wayback"
 )
 
 func setupHandler(t *testing.T, mock *mocks.Mock) *Handler {
@@ -250,6 +270,12 @@ func TestNewRecord_Errors(t *testing.T) {
 			withRecords: mocks.Bookmarks,
 			wantErrMesg: models.ErrRecordDuplicate.Error(),
 		},
+		{
+			name:        "same page under another URL",
+			record:      bookmark.BookmarkJSON{URL: "https://WWW.example.com:443/docs/?utm_source=feed#intro"},
+			withRecords: []*bookmark.Bookmark{{ID: 7, URL: "https://example.com/docs"}},
+			wantErrMesg: models.ErrRecordDuplicate.Error() + ": #7 https://example.com/docs",
+		},
 		{
 			name:        "empty URL",
 			record:      mocks.BookmarkJSONValid,
@@ -306,3 +332,1357 @@ func TestToggleFavorite(t *testing.T) {
 	mock := mocks.New()
 	mock.Records = mocks.Bookmarks
 }
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		fail       bool
+		query      string
+		wantStatus int
+		wantVacuum bool
+	}{
+		{name: "integrity ok", wantStatus: http.StatusOK, wantVacuum: true},
+		{name: "skip vacuum", query: "?vacuum=false", wantStatus: http.StatusOK},
+		{name: "integrity error", fail: true, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mock := mocks.New()
+			mock.Fail = tt.fail
+			h := setupHandler(t, mock)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/mock/maintenance"+tt.query, http.NoBody)
+			req.SetPathValue("db", mock.Name())
+			w := httptest.NewRecorder()
+
+			h.dbMaintenance(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.fail {
+				return
+			}
+
+			var got responder.MaintenanceResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !got.OK {
+				t.Errorf("expected integrity ok, got %v", got.Integrity)
+			}
+			if got.Vacuumed != tt.wantVacuum {
+				t.Errorf("expected vacuumed=%v, got %v", tt.wantVacuum, got.Vacuumed)
+			}
+		})
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	t.Parallel()
+	database.Register("search-one", "")
+	database.Register("search-two", "")
+
+	mock := mocks.New()
+	h := setupHandler(t, mock)
+	h.repoLoader = func(name string) (models.Repo, error) {
+		m := mocks.New()
+		if strings.HasPrefix(name, "search-") {
+			m.Records = mocks.Bookmarks
+		}
+		return m, nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search", http.NoBody)
+	w := httptest.NewRecorder()
+	h.searchAll(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for empty query, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/search?q=other", http.NoBody)
+	w = httptest.NewRecorder()
+	h.searchAll(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var got search.Result
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if got.Total != 2 {
+		t.Fatalf("expected 2 results, got %d", got.Total)
+	}
+	for _, hit := range got.Hits {
+		if hit.Bookmark.ID != 2 {
+			t.Errorf("unexpected bookmark %d", hit.Bookmark.ID)
+		}
+		want := "/web/" + hit.Repo + "/bookmarks/detail/2"
+		if hit.DetailURL != want {
+			t.Errorf("expected detail URL %q, got %q", want, hit.DetailURL)
+		}
+	}
+}
+
+func TestRecordHistory(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{{ID: 1, URL: "https://example.com", Title: "Current"}}
+	mock.History = []*models.Revision{
+		{ID: 2, BookmarkID: 1, URL: "https://example.com", Title: "Second"},
+		{ID: 1, BookmarkID: 1, URL: "https://example.org", Title: "Second"},
+	}
+	h := setupHandler(t, mock)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/mock/bookmarks/1/history", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	h.recordHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var got struct {
+		Revisions []struct {
+			ID      int                  `json:"id"`
+			Changes []models.FieldChange `json:"changes"`
+		} `json:"revisions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(got.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(got.Revisions))
+	}
+	want := []models.FieldChange{{Field: "title", Old: "Second", New: "Current"}}
+	if c := got.Revisions[0].Changes; len(c) != 1 || c[0] != want[0] {
+		t.Errorf("revision 2: expected changes %v, got %v", want, c)
+	}
+	want = []models.FieldChange{{Field: "url", Old: "https://example.org", New: "https://example.com"}}
+	if c := got.Revisions[1].Changes; len(c) != 1 || c[0] != want[0] {
+		t.Errorf("revision 1: expected changes %v, got %v", want, c)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/1/history/9/restore", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	req.SetPathValue("id", "1")
+	req.SetPathValue("rev", "9")
+	w = httptest.NewRecorder()
+	h.restoreRevision(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("restore missing revision: expected status 404, got %d", w.Code)
+	}
+}
+
+func TestDeadLinks(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://alive.example", IsActive: true, LastStatusChecked: "20250101000000", HTTPStatusCode: 200},
+		{ID: 2, URL: "https://gone.example", LastStatusChecked: "20250101000000", HTTPStatusCode: 404},
+		{ID: 3, URL: "https://unchecked.example"},
+	}
+	h := setupHandler(t, mock)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/mock/bookmarks/dead", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	w := httptest.NewRecorder()
+	h.deadLinks(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var got responder.DeadLinksResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if got.Total != 3 {
+		t.Errorf("expected total 3, got %d", got.Total)
+	}
+	if len(got.Dead) != 1 || got.Dead[0].ID != 2 {
+		t.Fatalf("expected only bookmark 2 to be dead, got %+v", got.Dead)
+	}
+}
+
+func TestRedirectsAccept(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "http://old.example"},
+		{ID: 2, URL: "http://temp.example"},
+	}
+	mock.Moved = []*models.Redirect{
+		{BookmarkID: 1, URL: "http://old.example", FinalURL: "https://new.example", Permanent: true},
+		{BookmarkID: 2, URL: "http://temp.example", FinalURL: "https://temp.example/login"},
+	}
+	h := setupHandler(t, mock)
+
+	body := strings.NewReader(`{"all": true, "permanent_only": true}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/redirects/accept", body)
+	req.SetPathValue("db", mock.Name())
+	w := httptest.NewRecorder()
+	h.redirectsAccept(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var got responder.RedirectsResult
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(got.Accepted) != 1 || got.Accepted[0] != 1 {
+		t.Fatalf("expected only bookmark 1 to be accepted, got %v", got.Accepted)
+	}
+	if mock.Records[0].URL != "https://new.example" {
+		t.Errorf("expected the URL to be updated, got %q", mock.Records[0].URL)
+	}
+	if len(mock.Moved) != 1 || mock.Moved[0].BookmarkID != 2 {
+		t.Errorf("expected the temporary redirect to remain pending, got %+v", mock.Moved)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/redirects/accept", strings.NewReader(`{}`))
+	req.SetPathValue("db", mock.Name())
+	w = httptest.NewRecorder()
+	h.redirectsAccept(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an empty selection, got %d", w.Code)
+	}
+}
+
+func TestTagsOps(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://a.example", Tags: "go,web"},
+		{ID: 2, URL: "https://b.example", Tags: "golang,cli"},
+		{ID: 3, URL: "https://c.example", Tags: "web"},
+	}
+	h := setupHandler(t, mock)
+
+	do := func(t *testing.T, fn http.HandlerFunc, body string) (*httptest.ResponseRecorder, responder.TagsResult) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/tags", strings.NewReader(body))
+		req.SetPathValue("db", mock.Name())
+		w := httptest.NewRecorder()
+		fn(w, req)
+		var got responder.TagsResult
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w, got
+	}
+
+	t.Run("preview saves nothing", func(t *testing.T) {
+		w, got := do(t, h.tagsMerge, `{"tags": ["go", "golang"], "to": "go", "preview": true}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got.Affected != 1 || got.Before["go"] != 1 || got.After["go"] != 2 || got.After["golang"] != 0 {
+			t.Errorf("unexpected preview: %+v", got)
+		}
+		if mock.Records[1].Tags != "golang,cli" {
+			t.Errorf("expected tags untouched by a preview, got %q", mock.Records[1].Tags)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		_, got := do(t, h.tagsMerge, `{"tags": ["go", "golang"], "to": "go"}`)
+		if got.Affected != 1 || mock.Records[1].Tags != "cli,go" {
+			t.Errorf("expected golang merged into go, got %q (%+v)", mock.Records[1].Tags, got)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		_, got := do(t, h.tagsRename, `{"tags": ["web"], "to": "www"}`)
+		if got.Affected != 2 || mock.Records[0].Tags != "go,www" || mock.Records[2].Tags != "www" {
+			t.Errorf("expected web renamed, got %+v", got)
+		}
+		w, _ := do(t, h.tagsRename, `{"tags": ["www"], "to": "a b"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for an invalid tag, got %d", w.Code)
+		}
+	})
+
+	t.Run("retag only the selection", func(t *testing.T) {
+		_, got := do(t, h.tagsRetag, `{"ids": [1, 3], "add": ["read"], "remove": ["go"]}`)
+		if got.Affected != 2 || mock.Records[0].Tags != "read,www" || mock.Records[1].Tags != "cli,go" {
+			t.Errorf("unexpected retag: %+v", got)
+		}
+		w, _ := do(t, h.tagsRetag, `{"add": ["read"]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 without a selection, got %d", w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, got := do(t, h.tagsDelete, `{"tags": ["READ"]}`)
+		if got.Affected != 2 || mock.Records[2].Tags != "www" {
+			t.Errorf("expected read removed regardless of case, got %+v", got)
+		}
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		mock.MockUpdateOne = func(_ context.Context, b *bookmark.Bookmark) error {
+			if b.ID == 3 {
+				return mocks.ErrMock
+			}
+			return nil
+		}
+		defer func() { mock.MockUpdateOne = nil }()
+
+		_, got := do(t, h.tagsRename, `{"tags": ["www"], "to": "web"}`)
+		if got.Failed[3] != mocks.ErrMock.Error() || got.Failed[1] != ErrBulkAborted.Error() {
+			t.Errorf("expected bookmark 3 to fail and abort bookmark 1, got %+v", got.Failed)
+		}
+		if mock.Records[0].Tags != "www" || mock.Records[2].Tags != "www" {
+			t.Errorf("expected the rename rolled back, got %q and %q", mock.Records[0].Tags, mock.Records[2].Tags)
+		}
+	})
+}
+
+func TestBulk(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://a.example", Title: "Go tour", Tags: "go"},
+		{ID: 2, URL: "https://b.example", Title: "Rust book", Tags: "rust"},
+		{ID: 3, URL: "https://c.example", Title: "Go blog", Tags: "go,blog"},
+	}
+	other := mocks.New()
+	h := setupHandler(t, mock)
+	h.repoLoader = func(name string) (models.Repo, error) {
+		if name == "other" {
+			return other, nil
+		}
+		return mock, nil
+	}
+
+	do := func(t *testing.T, body string) (*httptest.ResponseRecorder, responder.BulkResult) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/bulk", strings.NewReader(body))
+		req.SetPathValue("db", mock.Name())
+		w := httptest.NewRecorder()
+		h.bulk(w, req)
+		var got responder.BulkResult
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w, got
+	}
+
+	t.Run("per-item results", func(t *testing.T) {
+		_, got := do(t, `{"op": "favorite", "ids": [1, 9]}`)
+		if got.Total != 2 || got.Succeeded != 1 || got.Failed != 1 || got.Items[1].Error == "" {
+			t.Errorf("unexpected result: %+v", got)
+		}
+		if !mock.Records[0].Favorite {
+			t.Error("expected bookmark 1 favorited")
+		}
+	})
+
+	t.Run("tags by filter", func(t *testing.T) {
+		_, got := do(t, `{"op": "add-tags", "filter": {"query": "go"}, "tags": ["read"]}`)
+		if got.Succeeded != 2 || mock.Records[0].Tags != "go,read" || mock.Records[1].Tags != "rust" {
+			t.Errorf("expected read added to the Go bookmarks, got %+v", got)
+		}
+	})
+
+	t.Run("move", func(t *testing.T) {
+		_, got := do(t, `{"op": "move", "ids": [2], "repo": "other"}`)
+		if got.Succeeded != 1 || len(other.Records) != 1 || other.Records[0].URL != "https://b.example" {
+			t.Errorf("expected bookmark 2 moved, got %+v", got)
+		}
+		if got.Items[0].NewID != other.Records[0].ID {
+			t.Errorf("expected new ID %d, got %d", other.Records[0].ID, got.Items[0].NewID)
+		}
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		other.Records = append(other.Records, &bookmark.Bookmark{ID: 2, URL: "https://c.example"})
+		_, got := do(t, `{"op": "move", "ids": [1, 3], "repo": "other"}`)
+		if got.Succeeded != 0 || got.Failed != 2 || len(other.Records) != 2 {
+			t.Errorf("expected nothing moved, got %+v and %d records", got, len(other.Records))
+		}
+		if got.Items[0].Error != ErrBulkAborted.Error() || got.Items[0].NewID != 0 {
+			t.Errorf("expected bookmark 1 rolled back, got %+v", got.Items[0])
+		}
+		if !strings.HasPrefix(got.Items[1].Error, models.ErrRecordDuplicate.Error()) {
+			t.Errorf("expected bookmark 3 to fail as a duplicate, got %+v", got.Items[1])
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for _, body := range []string{
+			`{"op": "favorite"}`,
+			`{"op": "delete", "filter": {}}`,
+			`{"op": "delete", "filter": {"filter_by": "newest"}}`,
+			`{"op": "explode", "ids": [1]}`,
+			`{"op": "add-tags", "ids": [1]}`,
+			`{"op": "move", "ids": [1], "repo": "mock"}`,
+		} {
+			if w, _ := do(t, body); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", body, w.Code)
+			}
+		}
+	})
+}
+
+func TestDuplicates(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://example.com/post?utm_source=rss", Tags: "blog"},
+		{ID: 2, URL: "https://www.example.com/post/", Title: "A post", Tags: "go", VisitCount: 3},
+		{ID: 3, URL: "https://a.example/intro", Title: "Introduction to Go generics"},
+		{ID: 4, URL: "https://b.example/generics", Title: "Introduction to Go Generics!"},
+		{ID: 5, URL: "https://c.example", Title: "Home"},
+	}
+	h := setupHandler(t, mock)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/mock/bookmarks/duplicates", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	w := httptest.NewRecorder()
+	h.duplicates(w, req)
+
+	var got responder.DuplicatesResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(got.Groups))
+	}
+	if g := got.Groups[0]; g.Reason != "url" || g.Key != "https://example.com/post" || g.Bookmarks[0].ID != 2 {
+		t.Errorf("expected the URL group with the richest first, got %+v", g)
+	}
+	if g := got.Groups[1]; g.Reason != "title" || len(g.Bookmarks) != 2 {
+		t.Errorf("expected the title group, got %+v", g)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/duplicates/merge", strings.NewReader(`{"ids": [1, 2]}`))
+	req.SetPathValue("db", mock.Name())
+	w = httptest.NewRecorder()
+	h.duplicatesMerge(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var merged responder.MergeResult
+	if err := json.NewDecoder(w.Body).Decode(&merged); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if merged.Bookmark.ID != 2 || merged.Bookmark.Tags != "blog,go" || !slices.Equal(merged.Deleted, []int{1}) {
+		t.Errorf("expected #1 merged into #2, got %+v", merged)
+	}
+}
+
+func TestTagAliases(t *testing.T) {
+	t.Parallel()
+	const dbName = "tag-aliases"
+	database.Register(dbName, "")
+	mock := mocks.New()
+	h := setupHandler(t, mock)
+
+	put := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/"+dbName+"/tags/aliases", strings.NewReader(body))
+		req.SetPathValue("db", dbName)
+		w := httptest.NewRecorder()
+		h.tagAliasesSet(w, req)
+		return w.Code
+	}
+
+	if code := put(`{"aliases": {"golang": "go", "bad": "a b"}}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid alias, got %d", code)
+	}
+	if code := put(`{"aliases": {"Golang": "go"}}`); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/"+dbName+"/tags/aliases", http.NoBody)
+	req.SetPathValue("db", dbName)
+	w := httptest.NewRecorder()
+	h.tagAliases(w, req)
+	if !strings.Contains(w.Body.String(), `"golang":"go"`) {
+		t.Errorf("expected the lowercased rule, got %s", w.Body.String())
+	}
+
+	body := `{"url": "https://go.dev", "tags": ["golang/web", "cli", "go"]}`
+	req = httptest.NewRequest(http.MethodPost, "/api/"+dbName+"/bookmarks/new", strings.NewReader(body))
+	req.SetPathValue("db", dbName)
+	w = httptest.NewRecorder()
+	h.newRecord(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(mock.Records) != 1 || mock.Records[0].Tags != "cli,go,go/web" {
+		t.Errorf("expected the aliases applied on save, got %+v", mock.Records)
+	}
+}
+
+func TestSuggestTags(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://github.com/golang/go", Tags: "code,lang/go"},
+		{ID: 2, URL: "https://github.com/rust-lang/rust", Tags: "code,lang/rust"},
+		{ID: 3, URL: "https://blog.example/post", Tags: "reading,security"},
+		{ID: 4, URL: "https://news.example", Tags: "security,news"},
+	}
+	h := setupHandler(t, mock)
+
+	suggest := func(t *testing.T, query string) []*tags.Suggestion {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/mock/bookmarks/suggest-tags?"+query, http.NoBody)
+		req.SetPathValue("db", mock.Name())
+		w := httptest.NewRecorder()
+		h.suggestTags(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var got []*tags.Suggestion
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return got
+	}
+	names := func(ss []*tags.Suggestion) []string {
+		out := make([]string, 0, len(ss))
+		for _, s := range ss {
+			out = append(out, s.Tag)
+		}
+		return out
+	}
+
+	q := url.Values{"url": {"https://github.com/new/repo"}, "title": {"A Go module"}}
+	got := names(suggest(t, q.Encode()))
+	// lang/go matches both the domain and the title.
+	if !slices.Equal(got, []string{"lang/go", "code", "lang/rust"}) {
+		t.Errorf("expected the domain tags ranked by the title match, got %v", got)
+	}
+
+	q = url.Values{"url": {"https://other.example"}, "tags": {"news"}}
+	got = names(suggest(t, q.Encode()))
+	if !slices.Equal(got, []string{"security"}) {
+		t.Errorf("expected the co-occurring tag only, got %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/mock/bookmarks/suggest-tags", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	w := httptest.NewRecorder()
+	h.suggestTags(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a URL, got %d", w.Code)
+	}
+}
+
+func TestTagRules(t *testing.T) {
+	t.Parallel()
+	const dbName = "tag-rules"
+	database.Register(dbName, "")
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://a.example", Tags: "Web,web,Go Lang"},
+		{ID: 2, URL: "https://b.example", Tags: "cli"},
+	}
+	h := setupHandler(t, mock)
+
+	do := func(fn http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/"+dbName+path, strings.NewReader(body))
+		req.SetPathValue("db", dbName)
+		w := httptest.NewRecorder()
+		fn(w, req)
+		return w
+	}
+
+	if w := do(h.tagRulesSet, http.MethodPut, "/tags/rules", `{"charset": "a b"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a charset with spaces, got %d", w.Code)
+	}
+	w := do(h.tagRulesSet, http.MethodPut, "/tags/rules", `{"lowercase": true, "max_length": 8, "charset": "-/"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do(h.newRecord, http.MethodPost, "/bookmarks/new", `{"url": "https://c.example", "tags": ["Dev/Tools", "c++", "notifications", "c"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := mock.Records[2].Tags; got != "c,dev/tool,notifica" {
+		t.Errorf("expected the rules applied on save, got %q", got)
+	}
+
+	w = do(h.tagsNormalize, http.MethodPost, "/bookmarks/tags/normalize", `{"preview": true}`)
+	var res responder.TagsResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if res.Affected != 1 || mock.Records[0].Tags != "Web,web,Go Lang" {
+		t.Fatalf("expected a preview of one bookmark, got %+v", res)
+	}
+
+	do(h.tagsNormalize, http.MethodPost, "/bookmarks/tags/normalize", `{}`)
+	if got := mock.Records[0].Tags; got != "go-lang,web" {
+		t.Errorf("expected the stored tags normalized, got %q", got)
+	}
+}
+
+func TestURLRules(t *testing.T) {
+	t.Parallel()
+	dbName := "url-rules"
+	database.Register(dbName, "")
+	mock := mocks.New()
+	h := setupHandler(t, mock)
+
+	put := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/"+dbName+"/urls/rules", strings.NewReader(body))
+		req.SetPathValue("db", dbName)
+		w := httptest.NewRecorder()
+		h.urlRulesSet(w, req)
+		return w.Code
+	}
+
+	if code := put(`{"strip_params": ["a=b"]}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid parameter, got %d", code)
+	}
+	if code := put(`{"strip_tracking": true, "strip_params": ["ref"], "force_https": true}`); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	original := "http://example.com/post?id=7&utm_source=news&ref=feed"
+	body := `{"url": "` + original + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/"+dbName+"/bookmarks/new", strings.NewReader(body))
+	req.SetPathValue("db", dbName)
+	w := httptest.NewRecorder()
+	h.newRecord(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := mock.Records[0].URL; got != "https://example.com/post?id=7" {
+		t.Errorf("expected the cleaned URL, got %q", got)
+	}
+	if o := mock.Origins[1]; o == nil || o.URL != original {
+		t.Errorf("expected the original URL kept, got %+v", o)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/"+dbName+"/bookmarks/1/original-url", http.NoBody)
+	req.SetPathValue("db", dbName)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	h.originalURL(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "utm_source") {
+		t.Errorf("expected the original URL, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	h.originalURL(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a URL saved as given, got %d", w.Code)
+	}
+}
+
+func TestPatchRecord(t *testing.T) {
+	t.Parallel()
+	mock := mocks.New()
+	mock.Records = mocks.Bookmarks
+	h := setupHandler(t, mock)
+
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/mock/bookmarks/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.SetPathValue("db", "mock")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.patchRecord(w, req)
+		return w
+	}
+
+	w := patch("2", `{"tags": ["go", "cli"], "desc": null}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got bookmark.BookmarkJSON
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.ID != 2 || got.Title != "Other Example" || got.URL != "https://otherexample.com" {
+		t.Errorf("expected the other fields kept, got %+v", got)
+	}
+	if !slices.Contains(got.Tags, "cli") || !slices.Contains(got.Tags, "go") {
+		t.Errorf("expected the patched tags, got %v", got.Tags)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{"id of another record", "2", `{"id": 1, "title": "x"}`, http.StatusBadRequest},
+		{"read-only field", "2", `{"created_at": "2020-01-01"}`, http.StatusBadRequest},
+		{"url cleared", "2", `{"url": null}`, http.StatusBadRequest},
+		{"url of another record", "2", `{"url": "https://www.example.com/"}`, http.StatusBadRequest},
+		{"wrong type", "2", `{"tags": "go"}`, http.StatusBadRequest},
+		{"not an object", "2", `[{"op": "replace"}]`, http.StatusBadRequest},
+		{"missing record", "99", `{"title": "x"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := patch(tt.id, tt.body); w.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// the full update must not reach a record other than the path's.
+	req := httptest.NewRequest(http.MethodPut, "/api/mock/bookmarks/2/update",
+		strings.NewReader(`{"id": 1, "url": "https://example.com"}`))
+	req.SetPathValue("db", "mock")
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	h.updateRecord(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an id not matching the path, got %d", w.Code)
+	}
+}
+
+func TestRecordWayback(t *testing.T) {
+	t.Parallel()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/wayback/available" && r.URL.Query().Get("url") == "https://archived.example":
+			io.WriteString(w, `{"archived_snapshots": {"closest": {"available": true,
+				"url": "http://web.archive.org/web/20240102030405/https://archived.example",
+				"timestamp": "20240102030405", "status": "200"}}}`)
+		case r.URL.Path == "/wayback/available":
+			io.WriteString(w, `{"archived_snapshots": {}}`)
+		case strings.HasPrefix(r.URL.Path, "/save/"):
+			w.Header().Set("Location", "/web/20250101000000/"+strings.TrimPrefix(r.URL.Path, "/save/"))
+			w.WriteHeader(http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/web/"):
+			io.WriteString(w, "snapshot")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://archived.example"},
+		{ID: 2, URL: "https://new.example"},
+	}
+	h := setupHandler(t, mock)
+	wb, err := wayback.New(stub.URL, wayback.WithInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.wayback = wb
+
+	post := func(id, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/"+id+"/wayback"+query, http.NoBody)
+		req.SetPathValue("db", mock.Name())
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.recordWayback(w, req)
+		return w
+	}
+
+	if w := post("1", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mock.Records[0].ArchiveTimestamp != "20240102030405" {
+		t.Errorf("expected the closest snapshot to be stored, got %q", mock.Records[0].ArchiveTimestamp)
+	}
+
+	if w := post("2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without snapshot, got %d", w.Code)
+	}
+
+	if w := post("2", "?save=true"); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 after saving, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := stub.URL + "/web/20250101000000/https://new.example"; mock.Records[1].ArchiveURL != want {
+		t.Errorf("expected archive URL %q, got %q", want, mock.Records[1].ArchiveURL)
+	}
+
+	stub.Close()
+	if w := post("1", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 when unreachable, got %d", w.Code)
+	}
+}
+
+func TestRecordMetadata(t *testing.T) {
+	t.Parallel()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<!doctype html><html lang="en-GB"><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="Open Graph title">
+			<meta property="og:site_name" content="Example News">
+			<meta name="twitter:image" content="/img/card.png">
+			<meta name="keywords" content="go, web ,">
+			<link rel="canonical" href="/articles/42">
+			<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebSite", "name": "Example"},
+				{"@type": "NewsArticle", "headline": "Headline",
+				 "author": [{"@type": "Person", "name": "Ada"}, {"@type": "Person", "name": "Alan"}],
+				 "datePublished": "2024-03-01T10:00:00Z"}]}</script>
+			</head><body><p>Hello</p></body></html>`)
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{{ID: 1, URL: stub.URL + "/articles/42?utm_source=x"}}
+	h := setupHandler(t, mock)
+	h.archiver = archive.New(t.TempDir(), func(string) (models.Repo, error) { return mock, nil })
+	h.scraper = archive.NewScraper(http.DefaultClient)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/1/metadata", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	h.metadataScrape(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	m := mock.Metas[1]
+	if m == nil {
+		t.Fatal("expected the metadata to be saved")
+	}
+	want := map[string][2]string{
+		"site name": {m.SiteName, "Example News"},
+		"type":      {m.Type, "NewsArticle"},
+		"author":    {m.Author, "Ada, Alan"},
+		"canonical": {m.Canonical, stub.URL + "/articles/42"},
+		"image":     {m.Image, stub.URL + "/img/card.png"},
+		"lang":      {m.Lang, "en-GB"},
+		"published": {m.Published.Format("2006-01-02"), "2024-03-01"},
+	}
+	for field, v := range want {
+		if v[0] != v[1] {
+			t.Errorf("%s: expected %q, got %q", field, v[1], v[0])
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/scrape?url="+stub.URL, http.NoBody)
+	w = httptest.NewRecorder()
+	h.scrapeData(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var res responder.FetchDataResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "Open Graph title" || strings.Join(res.Tags, ",") != "go,web" || res.Type != "NewsArticle" {
+		t.Errorf("unexpected scrape response: %+v", res)
+	}
+	if res.FaviconURL != stub.URL+"/favicon.ico" {
+		t.Errorf("expected the default favicon, got %q", res.FaviconURL)
+	}
+}
+
+func TestRecordMetadata_Preview(t *testing.T) {
+	t.Parallel()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/card.png":
+			img := image.NewRGBA(image.Rect(0, 0, 1000, 400))
+			for x := range 1000 {
+				for y := range 400 {
+					img.Set(x, y, color.RGBA{R: uint8(x % 256), B: 200, A: 255})
+				}
+			}
+			_ = png.Encode(w, img)
+		case "/broken.png":
+			io.WriteString(w, "not an image")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><meta property="og:image" content="%s.png"></head></html>`,
+				strings.TrimPrefix(r.URL.Path, "/page"))
+		}
+	}))
+	defer stub.Close()
+
+	dir := t.TempDir()
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: stub.URL + "/page/card"},
+		{ID: 2, URL: stub.URL + "/page/broken"},
+	}
+	h := setupHandler(t, mock)
+	h.archiver = archive.New(t.TempDir(), func(string) (models.Repo, error) { return mock, nil },
+		archive.WithPreviews(preview.New(dir, http.DefaultClient)))
+
+	for _, id := range []string{"1", "2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/"+id+"/metadata", http.NoBody)
+		req.SetPathValue("db", mock.Name())
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.metadataScrape(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("id %s: expected status 201, got %d: %s", id, w.Code, w.Body.String())
+		}
+	}
+
+	if key := mock.Metas[2].Preview; key != "" {
+		t.Errorf("expected no preview for a broken image, got %q", key)
+	}
+
+	key := mock.Metas[1].Preview
+	if key == "" {
+		t.Fatal("expected a preview key")
+	}
+	for _, size := range preview.Sizes {
+		f, err := os.Open(filepath.Join(dir, preview.File(key, size.Name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := jpeg.DecodeConfig(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != size.Width || cfg.Height != size.Height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", size.Name, size.Width, size.Height, cfg.Width, cfg.Height)
+		}
+	}
+
+	previews, err := mock.Previews(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previews) != 1 || previews[1] != key {
+		t.Errorf("unexpected previews: %v", previews)
+	}
+}
+
+// waitFavicons waits for the favicon workers to update n bookmarks.
+func waitFavicons(t *testing.T, c *favicon.Cache, n uint64) *favicon.Metrics {
+	t.Helper()
+	for range 200 {
+		m := c.Metrics()
+		if m.Done+m.Failed >= n && m.Queued == 0 && m.Active == 0 {
+			return m
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("favicon workers still busy: %+v", c.Metrics())
+	return nil
+}
+
+func TestFaviconRefresh(t *testing.T) {
+	t.Parallel()
+	var (
+		icons  int
+		broken bool
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/icon.png" {
+			icons++
+			if broken {
+				http.NotFound(w, r)
+				return
+			}
+			_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><head><link rel="icon" href="/icon.png"></head></html>`)
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{{ID: 1, URL: stub.URL + "/page"}}
+	dir := filepath.Join(t.TempDir(), "favicon")
+	h := setupHandler(t, mock)
+	h.favicons = favicon.New(dir, "/cache/favicon/",
+		func(string) (models.Repo, error) { return mock, nil },
+		func() []string { return []string{mock.Name()} },
+		favicon.WithClient(http.DefaultClient),
+		favicon.WithGCInterval(0),
+		favicon.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+
+	refresh := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/1/favicon", http.NoBody)
+		req.SetPathValue("db", mock.Name())
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		h.faviconRefresh(w, req)
+		return w
+	}
+
+	if w := refresh(); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	b := mock.Records[0]
+	if b.FaviconURL != stub.URL+"/icon.png" || !strings.HasPrefix(b.FaviconLocal, "/cache/favicon/") {
+		t.Fatalf("unexpected favicon fields: %q, %q", b.FaviconURL, b.FaviconLocal)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.Base(b.FaviconLocal))); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed fetch backs off instead of being retried on every page view.
+	broken = true
+	if w := refresh(); w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d: %s", w.Code, w.Body.String())
+	}
+	if b.FaviconLocal != "" {
+		t.Errorf("expected the local favicon to be cleared, got %q", b.FaviconLocal)
+	}
+	h.favicons.Enqueue(mock.Name(), mock.Records)
+	if m := h.favicons.Metrics(); m.Enqueued != 0 || icons != 2 {
+		t.Errorf("expected no job for a domain backing off, got %+v and %d requests", m, icons)
+	}
+
+	st, err := h.favicons.Stats(t.Context(), mock.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Files != 0 || st.Failures != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	// only the favicons of the bookmarked domains survive a collection.
+	broken = false
+	if w := refresh(); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stray := filepath.Join(dir, "0123456789abcdef.ico")
+	if err := os.WriteFile(stray, []byte("ico"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// downloads in progress and files written during the collection stay.
+	partial := filepath.Join(dir, "0123456789abcdef.png.tmp")
+	fresh := filepath.Join(dir, "fedcba9876543210.png")
+	for _, p := range []string{partial, fresh} {
+		if err := os.WriteFile(p, []byte("png"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fresh, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := h.favicons.GC(t.Context()); err != nil || n != 1 {
+		t.Fatalf("expected 1 file removed, got %d: %v", n, err)
+	}
+	if _, err := os.Stat(stray); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the unreferenced favicon to be removed")
+	}
+	for _, p := range []string{partial, fresh} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to be kept: %v", filepath.Base(p), err)
+		}
+	}
+	if st, _ := h.favicons.Stats(t.Context(), mock.Name()); st.Files != 1 || st.Failures != 0 {
+		t.Errorf("unexpected stats after refresh: %+v", st)
+	}
+
+	// the bookmarks of a domain share one job, fetched by the workers.
+	other := strings.Replace(stub.URL, "127.0.0.1", "localhost", 1)
+	mock.Records = append(mock.Records,
+		&bookmark.Bookmark{ID: 2, URL: other + "/a"},
+		&bookmark.Bookmark{ID: 3, URL: other + "/b"})
+	h.favicons.Enqueue(mock.Name(), mock.Records)
+	h.favicons.Enqueue(mock.Name(), mock.Records)
+	go h.favicons.Run(t.Context())
+	defer h.favicons.Close()
+	if m := waitFavicons(t, h.favicons, 2); m.Enqueued != 1 || m.Merged != 1 || m.Failed != 0 {
+		t.Errorf("expected 1 job with 1 merged bookmark, got %+v", m)
+	}
+	if icons != 4 {
+		t.Errorf("expected 4 favicon requests, got %d", icons)
+	}
+	if l := mock.Records[1].FaviconLocal; l == "" || l != mock.Records[2].FaviconLocal {
+		t.Errorf("expected the bookmarks to share the favicon, got %q and %q", l, mock.Records[2].FaviconLocal)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/favicons", http.NoBody)
+	w := httptest.NewRecorder()
+	h.faviconMetrics(w, req)
+	var got favicon.Metrics
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.Done != 2 || got.Workers != favicon.DefaultConcurrency {
+		t.Errorf("unexpected metrics: %+v, %v", got, err)
+	}
+}
+
+// icoStub returns an ICO file holding a 24-bit bitmap of the given size,
+// red on its left half, with the right half masked out.
+func icoStub(size int) []byte {
+	le := binary.LittleEndian
+	stride := (size*3 + 3) / 4 * 4
+	maskStride := (size + 31) / 32 * 4
+
+	var dib bytes.Buffer
+	header := make([]byte, 40)
+	le.PutUint32(header, 40)
+	le.PutUint32(header[4:], uint32(size))
+	le.PutUint32(header[8:], uint32(2*size))
+	le.PutUint16(header[12:], 1)
+	le.PutUint16(header[14:], 24)
+	dib.Write(header)
+	for range size {
+		row := make([]byte, stride)
+		for x := range size {
+			row[3*x+2] = 0xff
+		}
+		dib.Write(row)
+	}
+	for range size {
+		row := make([]byte, maskStride)
+		for x := size / 2; x < size; x++ {
+			row[x/8] |= 0x80 >> (x % 8)
+		}
+		dib.Write(row)
+	}
+
+	ico := []byte{0, 0, 1, 0, 1, 0}
+	entry := make([]byte, 16)
+	entry[0], entry[1] = byte(size), byte(size)
+	le.PutUint16(entry[4:], 1)
+	le.PutUint16(entry[6:], 24)
+	le.PutUint32(entry[8:], uint32(dib.Len()))
+	le.PutUint32(entry[12:], 22)
+
+	return append(append(ico, entry...), dib.Bytes()...)
+}
+
+func TestFaviconNormalize(t *testing.T) {
+	t.Parallel()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/large.png":
+			_ = png.Encode(w, image.NewRGBA(image.Rect(0, 0, 512, 256)))
+		case r.URL.Path == "/icon.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			io.WriteString(w, `<svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+		case r.URL.Path == "/favicon.ico":
+			w.Header().Set("Content-Type", "image/vnd.microsoft.icon")
+			w.Write(icoStub(16))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<html><head><link rel="icon" href="`+r.URL.Query().Get("icon")+`"></head></html>`)
+		}
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	dir := filepath.Join(t.TempDir(), "favicon")
+	h := setupHandler(t, mock)
+	h.favicons = favicon.New(dir, "/cache/favicon/",
+		func(string) (models.Repo, error) { return mock, nil },
+		func() []string { return []string{mock.Name()} },
+		favicon.WithClient(http.DefaultClient),
+		favicon.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+
+	tests := []struct {
+		name          string
+		icon          string
+		opaque, clear image.Point // pixels checked, unless equal
+	}{
+		{name: "oversized png", icon: "/large.png"},
+		{name: "ico", icon: "/favicon.ico", opaque: image.Pt(8, 32), clear: image.Pt(56, 32)},
+		{name: "svg falls back to the root icon", icon: "/icon.svg", opaque: image.Pt(8, 32), clear: image.Pt(56, 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.Records = []*bookmark.Bookmark{{ID: 1, URL: stub.URL + "/page?icon=" + tt.icon}}
+			req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/1/favicon", http.NoBody)
+			req.SetPathValue("db", mock.Name())
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+			h.faviconRefresh(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			local := mock.Records[0].FaviconLocal
+			if filepath.Ext(local) != ".png" {
+				t.Fatalf("expected a png favicon, got %q", local)
+			}
+			f, err := os.Open(filepath.Join(dir, filepath.Base(local)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			img, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() != favicon.Size || b.Dy() != favicon.Size {
+				t.Errorf("expected %dx%d, got %v", favicon.Size, favicon.Size, b)
+			}
+			if tt.opaque == tt.clear {
+				return
+			}
+			if r, _, _, a := img.At(tt.opaque.X, tt.opaque.Y).RGBA(); r>>8 != 0xff || a>>8 != 0xff {
+				t.Errorf("expected an opaque red pixel at %v", tt.opaque)
+			}
+			if _, _, _, a := img.At(tt.clear.X, tt.clear.Y).RGBA(); a != 0 {
+				t.Errorf("expected a transparent pixel at %v", tt.clear)
+			}
+		})
+	}
+}
+
+func TestFaviconAvatar(t *testing.T) {
+	t.Parallel()
+	a := favicon.NewAvatar("https://www.example.com/page")
+	if a.Letter != "E" {
+		t.Errorf("expected letter E, got %q", a.Letter)
+	}
+	if a.Color < 0 || a.Color >= favicon.AvatarColors {
+		t.Errorf("color out of range: %d", a.Color)
+	}
+	if b := favicon.NewAvatar("http://example.com/other"); b != a {
+		t.Errorf("expected the same avatar for the same domain, got %+v and %+v", a, b)
+	}
+}
+
+func TestScrapeData_PrivateAddress(t *testing.T) {
+	t.Parallel()
+	var agent string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><head><title>Internal</title></head></html>`)
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	h := setupHandler(t, mock)
+	scrape := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/scrape?url="+stub.URL, http.NoBody)
+		w := httptest.NewRecorder()
+		h.scrapeData(w, req)
+		return w
+	}
+
+	h.scraper = archive.NewScraper(fetch.New())
+	if w := scrape(); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a loopback URL, got %d: %s", w.Code, w.Body.String())
+	}
+	if agent != "" {
+		t.Error("expected no request to reach the server")
+	}
+
+	nets, err := fetch.ParseNets([]string{"127.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fetch.New(fetch.WithAllowedNets(nets...), fetch.WithUserAgent("gmweb-test"))
+	h.scraper = archive.NewScraper(client)
+	if w := scrape(); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for an allowed network, got %d: %s", w.Code, w.Body.String())
+	}
+	if agent != "gmweb-test" {
+		t.Errorf("expected the configured user agent, got %q", agent)
+	}
+
+	client = fetch.New(fetch.WithAllowedNets(nets...), fetch.WithMaxBodySize(8))
+	h.scraper = archive.NewScraper(client)
+	if w := scrape(); w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 for a response over the size limit, got %d", w.Code)
+	}
+}
+
+func TestScrapeData_Proxy(t *testing.T) {
+	t.Parallel()
+	// the stub answers both as the proxy, seeing absolute URLs, and as the
+	// destination of the direct requests.
+	var seen []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.URL.String())
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><head><title>Stub</title></head></html>`)
+	}))
+	defer stub.Close()
+
+	pu, err := fetch.ParseProxy(stub.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets, _ := fetch.ParseNets([]string{"127.0.0.0/8"})
+	client := fetch.New(
+		fetch.WithAllowedNets(nets...),
+		fetch.WithProxy(pu),
+		fetch.WithProxyBypass(func(repo string) bool { return repo == "internal" }),
+	)
+
+	mock := mocks.New()
+	h := setupHandler(t, mock)
+	h.scraper = archive.NewScraper(client)
+	scrape := func(target, db string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/scrape?url="+target+"&db="+db, http.NoBody)
+		w := httptest.NewRecorder()
+		h.scrapeData(w, req)
+		return w.Code
+	}
+
+	page := stub.URL + "/page"
+	if code := scrape(page, "main"); code != http.StatusOK {
+		t.Fatalf("expected status 200 through the proxy, got %d", code)
+	}
+	if code := scrape(page, "internal"); code != http.StatusOK {
+		t.Fatalf("expected status 200 bypassing the proxy, got %d", code)
+	}
+	if want := []string{page, "/page"}; !slices.Equal(seen, want) {
+		t.Errorf("expected requests %v, got %v", want, seen)
+	}
+
+	if code := scrape("http://10.0.0.1/", "main"); code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a private URL through the proxy, got %d", code)
+	}
+}
+
+func TestCheckStatus_Redirect(t *testing.T) {
+	t.Parallel()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer stub.Close()
+
+	mock := mocks.New()
+	mock.Records = []*bookmark.Bookmark{{ID: 1, URL: stub.URL + "/old"}}
+	h := setupHandler(t, mock)
+	h.checker = linkcheck.New(nil, func() []string { return nil }, linkcheck.WithClient(stub.Client()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/mock/bookmarks/1/status", http.NoBody)
+	req.SetPathValue("db", mock.Name())
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	h.checkStatus(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if b := mock.Records[0]; b.HTTPStatusCode != http.StatusOK || !b.IsActive {
+		t.Errorf("expected the status saved, got %d (active %v)", b.HTTPStatusCode, b.IsActive)
+	}
+	if len(mock.Moved) != 1 || mock.Moved[0].FinalURL != stub.URL+"/new" || !mock.Moved[0].Permanent {
+		t.Errorf("expected the permanent redirect recorded, got %+v", mock.Moved)
+	}
+}
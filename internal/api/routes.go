@@ -1,12 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -15,14 +20,23 @@ import (
 	"github.com/mateconpizza/gm/pkg/bookio"
 	"github.com/mateconpizza/gm/pkg/bookmark"
 	"github.com/mateconpizza/gm/pkg/files"
-	"github.com/mateconpizza/gm/pkg/scraper"
 
+	"github.com/mateconpizza/gmweb/internal/archive"
 	"github.com/mateconpizza/gmweb/internal/database"
+	"github.com/mateconpizza/gmweb/internal/dupes"
+	"github.com/mateconpizza/gmweb/internal/fetch"
 	"github.com/mateconpizza/gmweb/internal/helpers"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/middleware"
 	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/qr"
+	"github.com/mateconpizza/gmweb/internal/reader"
 	"github.com/mateconpizza/gmweb/internal/responder"
+	"github.com/mateconpizza/gmweb/internal/router"
+	"github.com/mateconpizza/gmweb/internal/search"
+	"github.com/mateconpizza/gmweb/internal/tags"
+	"github.com/mateconpizza/gmweb/internal/urlclean"
+	"github.com/mateconpizza/gmweb/internal/wayback"
 )
 
 // Routes registers the routes for the API.
@@ -34,6 +48,9 @@ func (h *Handler) Routes(mux *http.ServeMux) {
 	mustDBParam := func(fn func(w http.ResponseWriter, r *http.Request)) http.Handler {
 		return middleware.RequireDBParam(http.HandlerFunc(fn))
 	}
+	mustWritable := func(fn func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return middleware.RequireWritable(http.HandlerFunc(fn)).ServeHTTP
+	}
 
 	r := h.router.API
 
@@ -45,29 +62,71 @@ func (h *Handler) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("POST "+r.GenQR(), h.genQR)
 	mux.HandleFunc("POST "+r.GenQRPNG(), h.genQRPNG)
 	mux.HandleFunc("GET "+r.Shutdown(), h.shutdown)
+	mux.HandleFunc("GET "+r.Search(), h.searchAll)
+	mux.HandleFunc("GET "+r.FaviconMetrics(), h.faviconMetrics)
 
 	// Records
 	mux.Handle("GET "+r.All(), mustDBParam(h.allBookmarks))
 	mux.Handle("GET "+r.BookmarkByID("{id}"), mustIDAndDBParam(h.recordByID))
+	mux.Handle("PATCH "+r.BookmarkByID("{id}"), mustIDAndDBParam(mustWritable(h.patchRecord)))
 	mux.Handle("GET "+r.Tags(), mustDBParam(h.tagsList))
-	mux.Handle("POST "+r.NewBookmark(), mustDBParam(h.newRecord))
-	mux.Handle("PUT "+r.ToggleFavorite("{id}"), mustIDAndDBParam(h.toggleFavorite))
-	mux.Handle("PUT "+r.AddVisit("{id}"), mustIDAndDBParam(h.addVisit))
-	mux.Handle("PUT "+r.UpdateBookmark("{id}"), mustIDAndDBParam(h.updateRecord))
-	mux.Handle("DELETE "+r.DeleteBookmark("{id}"), mustIDAndDBParam(h.deleteRecord))
-	mux.Handle("GET "+r.CheckStatus("{id}"), mustIDAndDBParam(h.checkStatus))
-	mux.Handle("PUT "+r.Notes("{id}"), mustIDAndDBParam(h.updateNotes))
+	mux.Handle("POST "+r.TagsRename(), mustDBParam(mustWritable(h.tagsRename)))
+	mux.Handle("POST "+r.TagsMerge(), mustDBParam(mustWritable(h.tagsMerge)))
+	mux.Handle("POST "+r.TagsDelete(), mustDBParam(mustWritable(h.tagsDelete)))
+	mux.Handle("POST "+r.TagsRetag(), mustDBParam(mustWritable(h.tagsRetag)))
+	mux.Handle("POST "+r.TagsNormalize(), mustDBParam(mustWritable(h.tagsNormalize)))
+	mux.Handle("POST "+r.Bulk(), mustDBParam(mustWritable(h.bulk)))
+	mux.Handle("GET "+r.Duplicates(), mustDBParam(h.duplicates))
+	mux.Handle("POST "+r.DuplicatesMerge(), mustDBParam(mustWritable(h.duplicatesMerge)))
+	mux.Handle("GET "+r.SuggestTags(), mustDBParam(h.suggestTags))
+	mux.Handle("GET "+r.DeadLinks(), mustDBParam(h.deadLinks))
+	mux.Handle("GET "+r.Redirects(), mustDBParam(h.redirectsList))
+	mux.Handle("POST "+r.RedirectsAccept(), mustDBParam(mustWritable(h.redirectsAccept)))
+	mux.Handle("POST "+r.RedirectsDismiss(), mustDBParam(mustWritable(h.redirectsDismiss)))
+	mux.Handle("POST "+r.NewBookmark(), mustDBParam(mustWritable(h.newRecord)))
+	mux.Handle("PUT "+r.ToggleFavorite("{id}"), mustIDAndDBParam(mustWritable(h.toggleFavorite)))
+	mux.Handle("PUT "+r.AddVisit("{id}"), mustIDAndDBParam(mustWritable(h.addVisit)))
+	mux.Handle("PUT "+r.UpdateBookmark("{id}"), mustIDAndDBParam(mustWritable(h.updateRecord)))
+	mux.Handle("DELETE "+r.DeleteBookmark("{id}"), mustIDAndDBParam(mustWritable(h.deleteRecord)))
+	mux.Handle("GET "+r.CheckStatus("{id}"), mustIDAndDBParam(mustWritable(h.checkStatus)))
+	mux.Handle("PUT "+r.Notes("{id}"), mustIDAndDBParam(mustWritable(h.updateNotes)))
+	mux.Handle("GET "+r.History("{id}"), mustIDAndDBParam(h.recordHistory))
+	mux.Handle("POST "+r.RestoreRevision("{id}", "{rev}"), mustIDAndDBParam(mustWritable(h.restoreRevision)))
+	mux.Handle("GET "+r.Snapshots("{id}"), mustIDAndDBParam(h.snapshotList))
+	mux.Handle("POST "+r.Snapshots("{id}"), mustIDAndDBParam(mustWritable(h.snapshotCreate)))
+	mux.Handle("DELETE "+r.Snapshot("{id}", "{sid}"), mustIDAndDBParam(mustWritable(h.snapshotDelete)))
+	mux.Handle("POST "+r.Wayback("{id}"), mustIDAndDBParam(mustWritable(h.recordWayback)))
+	mux.Handle("GET "+r.Article("{id}"), mustIDAndDBParam(h.articleGet))
+	mux.Handle("POST "+r.Article("{id}"), mustIDAndDBParam(mustWritable(h.articleExtract)))
+	mux.Handle("GET "+r.Metadata("{id}"), mustIDAndDBParam(h.metadataGet))
+	mux.Handle("POST "+r.Metadata("{id}"), mustIDAndDBParam(mustWritable(h.metadataScrape)))
+	mux.Handle("POST "+r.Favicon("{id}"), mustIDAndDBParam(mustWritable(h.faviconRefresh)))
+	mux.Handle("GET "+r.OriginalURL("{id}"), mustIDAndDBParam(h.originalURL))
 
 	// Import|Export
-	mux.Handle("POST "+r.ImportHTML(), mustDBParam(h.importHTML))
-	mux.Handle("POST "+r.ImportRepoJSON(), mustDBParam(h.importJSON))
-	mux.Handle("POST "+r.ImportRepoGPG(), mustDBParam(h.importGPG))
+	mux.Handle("POST "+r.ImportHTML(), mustDBParam(mustWritable(h.importHTML)))
+	mux.Handle("POST "+r.ImportRepoJSON(), mustDBParam(mustWritable(h.importJSON)))
+	mux.Handle("POST "+r.ImportRepoGPG(), mustDBParam(mustWritable(h.importGPG)))
 
 	// Repositories
 	mux.HandleFunc("GET "+r.RepoList(), h.dbList)
 	mux.HandleFunc("GET "+r.RepoAll(), h.dbInfoAll)
 	mux.Handle("GET "+r.RepoInfo(), mustDBParam(h.dbInfo))
-	mux.Handle("DELETE "+r.RepoDelete(), mustDBParam(h.dbDelete))
+	mux.Handle("DELETE "+r.RepoDelete(), mustDBParam(mustWritable(h.dbDelete)))
+	mux.Handle("POST "+r.RepoMaint(), mustDBParam(h.dbMaintenance))
+	mux.Handle("PUT "+r.RepoReadOnly(), mustDBParam(h.dbReadOnly))
+	mux.Handle("GET "+r.RepoArchive(), mustDBParam(h.archiveUsage))
+	mux.Handle("PUT "+r.RepoArchive(), mustDBParam(h.archiveQuota))
+	mux.Handle("PUT "+r.RepoProxy(), mustDBParam(h.dbProxy))
+	mux.Handle("GET "+r.RepoAliases(), mustDBParam(h.tagAliases))
+	mux.Handle("PUT "+r.RepoAliases(), mustDBParam(h.tagAliasesSet))
+	mux.Handle("GET "+r.RepoTagRules(), mustDBParam(h.tagRules))
+	mux.Handle("PUT "+r.RepoTagRules(), mustDBParam(h.tagRulesSet))
+	mux.Handle("GET "+r.RepoURLRules(), mustDBParam(h.urlRules))
+	mux.Handle("PUT "+r.RepoURLRules(), mustDBParam(h.urlRulesSet))
+	mux.Handle("POST "+r.RepoFavicons(), mustDBParam(mustWritable(h.faviconsRefresh)))
+	mux.Handle("GET "+r.LinkCheck(), mustDBParam(h.linkCheckStatus))
+	mux.Handle("POST "+r.LinkCheck(), mustDBParam(mustWritable(h.linkCheckRun)))
 	mux.HandleFunc("POST "+r.RepoNew(), h.dbCreate)
 }
 
@@ -89,6 +148,7 @@ func (h *Handler) allBookmarks(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	bs, err := repo.All(r.Context())
 	if err != nil {
@@ -127,13 +187,17 @@ func (h *Handler) dbList(w http.ResponseWriter, _ *http.Request) {
 func (h *Handler) dbInfoAll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	stats := make([]*responder.RepoStatsResponse, 0, len(database.Valid))
-	for k := range database.Valid {
-		stat, err := dbStats(r, h, k)
+	repos := database.List()
+	stats := make([]*responder.RepoStatsResponse, 0, len(repos))
+	for _, info := range repos {
+		stat, err := dbStats(r, h, info.Name)
 		if err != nil {
 			responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		stat.OpenedAt = info.OpenedAt
+		stat.ReadOnly = info.ReadOnly
+		stat.LastUsed = info.LastUsed
 		stats = append(stats, stat)
 	}
 
@@ -161,53 +225,228 @@ func (h *Handler) dbInfo(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
+
+	version, err := repo.SchemaVersion(r.Context())
+	if err != nil {
+		h.logger.Warn("repo info: schema version", "error", err, "repo", dbName)
+	}
 
 	stats := &responder.RepoStatsResponse{
-		Name:      dbName,
-		Bookmarks: repo.Count(r.Context(), "bookmarks"),
-		Tags:      repo.Count(r.Context(), "tags"),
-		Favorites: repo.CountFavorites(r.Context()),
+		Name:          dbName,
+		Bookmarks:     repo.Count(r.Context(), "bookmarks"),
+		Tags:          repo.Count(r.Context(), "tags"),
+		Favorites:     repo.CountFavorites(r.Context()),
+		SchemaVersion: version,
+		ReadOnly:      database.IsReadOnly(dbName),
+		BypassProxy:   database.BypassesProxy(dbName),
+	}
+	if stats.ArchiveSize, err = repo.ArchiveSize(r.Context()); err != nil {
+		h.logger.Warn("repo info: archive size", "error", err, "repo", dbName)
+	}
+	if h.favicons != nil {
+		if stats.Favicons, err = h.favicons.Stats(r.Context(), dbName); err != nil {
+			h.logger.Warn("repo info: favicon stats", "error", err, "repo", dbName)
+		}
 	}
 
 	responder.WriteJSON(w, http.StatusOK, stats)
 }
 
+// dbReadOnly sets or clears the read-only mode of the repo.
+func (h *Handler) dbReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		ReadOnly *bool `json:"read_only"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReadOnly == nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid request: expected {\"read_only\": bool}")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.SetReadOnly(dbName, *req.ReadOnly); err != nil {
+		h.logger.Error("repo read-only", "error", err, "repo", dbName)
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrReadOnlyByConfig) {
+			status = http.StatusConflict
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	msg := "repository is now writable: " + dbName
+	if *req.ReadOnly {
+		msg = "repository is now read-only: " + dbName
+	}
+
+	h.logger.Info("repo read-only", "repo", dbName, "read_only", *req.ReadOnly)
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    msg,
+		StatusCode: http.StatusOK,
+	})
+}
+
+// dbProxy sets whether the outbound requests of the repo bypass the proxy.
+func (h *Handler) dbProxy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		BypassProxy *bool `json:"bypass_proxy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BypassProxy == nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid request: expected {\"bypass_proxy\": bool}")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.UpdateSettings(dbName, func(s *database.Settings) { s.BypassProxy = *req.BypassProxy }); err != nil {
+		h.logger.Error("repo proxy", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	msg := "repository uses the proxy: " + dbName
+	if *req.BypassProxy {
+		msg = "repository bypasses the proxy: " + dbName
+	}
+
+	h.logger.Info("repo proxy", "repo", dbName, "bypass_proxy", *req.BypassProxy)
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    msg,
+		StatusCode: http.StatusOK,
+	})
+}
+
+// dbMaintenance runs an integrity check on the repo and, if it passes and
+// `vacuum` is not disabled, rebuilds the database file.
+func (h *Handler) dbMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("repo maintenance", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	start := time.Now()
+	path, _ := database.Path(dbName)
+	res := &responder.MaintenanceResponse{
+		Name:       dbName,
+		SizeBefore: files.SizeBytes(path),
+	}
+
+	res.Integrity, err = repo.IntegrityCheck(r.Context())
+	if err != nil {
+		h.logger.Error("repo maintenance: integrity check", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res.OK = len(res.Integrity) == 1 && res.Integrity[0] == "ok"
+
+	if res.OK && r.URL.Query().Get("vacuum") != "false" && !database.IsReadOnly(dbName) {
+		if err := repo.Vacuum(r.Context()); err != nil {
+			h.logger.Error("repo maintenance: vacuum", "error", err, "repo", dbName)
+			responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		res.Vacuumed = true
+	}
+
+	res.SizeAfter = files.SizeBytes(path)
+	res.SchemaVersion, _ = repo.SchemaVersion(r.Context())
+	res.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	h.logger.Info("repo maintenance", "repo", dbName, "ok", res.OK, "vacuumed", res.Vacuumed)
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// searchAll searches every repository and returns the merged, ranked
+// results.
+func (h *Handler) searchAll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	query := search.Query{
+		Query:    q.Get("q"),
+		Tag:      q.Get("tag"),
+		Letter:   q.Get("letter"),
+		FilterBy: q.Get("filter"),
+		Limit:    limit,
+	}
+
+	if query.Query == "" && query.Tag == "" && query.Letter == "" && query.FilterBy == "" {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "missing search query")
+		return
+	}
+
+	res := search.Run(r.Context(), h.repoLoader, database.Names(), query)
+	for name, err := range res.Failed {
+		h.logger.Error("search", "error", err, "repo", name)
+	}
+	if len(res.TimedOut) > 0 {
+		h.logger.Warn("search: repositories timed out", "repos", res.TimedOut)
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
 // scrapeData scrapes a URL and returns its data.
 func (h *Handler) scrapeData(w http.ResponseWriter, r *http.Request) {
-	// TODO: add URL validation
 	w.Header().Set("Content-Type", "application/json")
 
+	if h.scraper == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "scraping disabled")
+		return
+	}
+
 	u := r.URL.Query().Get("url")
 	if u == "" {
 		h.logger.Error("fetching URL", "error", "empty URL")
 		responder.EncodeErrJSON(w, http.StatusBadRequest, "empty URL")
 		return
 	}
+	if pu, err := url.Parse(u); err != nil || (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid URL")
+		return
+	}
 
 	h.logger.Debug("fetching URL", "url", u)
 
-	sc := scraper.New(u)
-	if err := sc.Start(); err != nil {
+	// the optional repo decides whether the request bypasses the proxy.
+	ctx := fetch.WithRepo(r.Context(), r.URL.Query().Get("db"))
+	m, err := h.scraper.Scrape(ctx, u)
+	if err != nil {
 		h.logger.Error("scrape new bookmark", "error", err)
-		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, archive.ErrNotHTML):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, fetch.ErrForbiddenAddress):
+			status = http.StatusForbidden
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
 		return
 	}
 
-	b := bookmark.NewJSON()
-	b.Title, _ = sc.Title()
-	b.Desc, _ = sc.Desc()
-	k, _ := sc.Keywords()
-	b.Tags = strings.Split(k, ",")
-	b.FaviconURL, _ = sc.Favicon()
-
-	responsePayload := &responder.FetchDataResponse{
-		Title:      b.Title,
-		Desc:       b.Desc,
-		Tags:       b.Tags,
-		FaviconURL: b.FaviconURL,
-	}
-
-	responder.WriteJSON(w, http.StatusOK, responsePayload)
+	responder.WriteJSON(w, http.StatusOK, &responder.FetchDataResponse{
+		Title:      m.Title,
+		Desc:       m.Desc,
+		Tags:       m.Keywords,
+		FaviconURL: m.FaviconURL,
+		Image:      m.Image,
+		SiteName:   m.SiteName,
+		Type:       m.Type,
+		Author:     m.Author,
+		Canonical:  m.Canonical,
+		Lang:       m.Lang,
+		Published:  m.Published,
+	})
 }
 
 // genQR generates a QR-code as a Base64.
@@ -293,13 +532,7 @@ func (h *Handler) genQRPNG(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) dbDelete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	repo, err := h.repoLoader(r.PathValue("db"))
-	if err != nil {
-		h.logger.Error("listing bookmarks", "error", err, "db", r.PathValue("db"))
-		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	repo.Close()
+	// closes the connection, if any, before renaming the file.
 	database.Forget(r.PathValue("db"))
 
 	// FIX: should i use os.Root?
@@ -316,7 +549,6 @@ func (h *Handler) dbDelete(w http.ResponseWriter, r *http.Request) {
 		StatusCode: http.StatusOK,
 	}
 
-	database.Forget(dbName)
 	responder.WriteJSON(w, http.StatusOK, res)
 }
 
@@ -333,12 +565,14 @@ func (h *Handler) dbCreate(w http.ResponseWriter, r *http.Request) {
 
 	newDBName := files.EnsureSuffix(dbParam, ".db")
 	dbPath := filepath.Clean(filepath.Join(h.dataDir, newDBName))
-	_, err := models.Initialize(r.Context(), dbPath)
+	m, err := models.Initialize(r.Context(), dbPath)
 	if err != nil {
 		h.logger.Error("creating database", "error", err, "db", newDBName)
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	// the registry opens its own connection on first use.
+	m.Close()
 
 	res := &responder.ResponseData{
 		Message:    fmt.Sprintf("New database %q successfully created", newDBName),
@@ -360,6 +594,7 @@ func (h *Handler) recordByID(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	idStr := r.PathValue("id")
 	bID, _ := strconv.Atoi(idStr)
@@ -384,6 +619,7 @@ func (h *Handler) newRecord(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	bj := bookmark.NewJSON()
 	if err := json.NewDecoder(r.Body).Decode(bj); err != nil {
@@ -417,10 +653,20 @@ func (h *Handler) newRecord(w http.ResponseWriter, r *http.Request) {
 	u, err := url.ParseRequestURI(rawURL)
 	if err != nil {
 		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	original := u.String()
+	cleaned := h.cleanURL(r.Context(), dbName, original)
+
+	if err := duplicateOf(r.Context(), repo, cleaned, 0); err != nil {
+		h.logger.Error("creating bookmark", "error", err)
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	newB := bookmark.NewFromJSON(bj)
-	newB.URL = u.String()
+	newB.URL = cleaned
+	newB.Tags = database.TagNormalizer(dbName).Normalize(newB.Tags)
 
 	if err := bookmark.Validate(newB); err != nil {
 		h.logger.Error("creating bookmark", "error", err)
@@ -428,11 +674,17 @@ func (h *Handler) newRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := repo.InsertOne(r.Context(), newB); err != nil {
+	id, err := repo.InsertOne(r.Context(), newB)
+	if err != nil {
 		h.logger.Error("creating bookmark", "error", err)
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.keepOriginalURL(r.Context(), repo, int(id), original, cleaned)
+
+	if h.archiver != nil {
+		h.archiver.ArchiveNew(dbName, int(id))
+	}
 
 	res := &responder.ResponseData{
 		Message:    "New bookmark successfully created",
@@ -442,7 +694,52 @@ func (h *Handler) newRecord(w http.ResponseWriter, r *http.Request) {
 	responder.WriteJSON(w, http.StatusCreated, res)
 }
 
-// updateRecord updates the given record.
+// duplicateOf returns ErrRecordDuplicate, naming the bookmark, when one
+// other than bID is saved under a URL of the same page as rawURL.
+func duplicateOf(ctx context.Context, repo models.Reader, rawURL string, bID int) error {
+	if dup, exists := repo.ByCanonicalURL(ctx, rawURL); exists && dup.ID != bID {
+		return fmt.Errorf("%w: #%d %s", models.ErrRecordDuplicate, dup.ID, dup.URL)
+	}
+
+	return nil
+}
+
+// cleanURL applies the URL rules of the repo. A short link that cannot be
+// resolved is kept.
+func (h *Handler) cleanURL(ctx context.Context, dbName, rawURL string) string {
+	var resolve urlclean.Resolver
+	if h.client != nil {
+		resolve = func(ctx context.Context, short string) (string, error) {
+			resp, err := fetch.Get(fetch.WithRepo(ctx, dbName), h.client, short)
+			if err != nil {
+				return "", err
+			}
+			_ = resp.Body.Close()
+			return resp.Request.URL.String(), nil
+		}
+	}
+
+	rules := database.URLRules(dbName)
+	cleaned, err := rules.Clean(ctx, rawURL, resolve)
+	if err != nil {
+		h.logger.Warn("cleaning url", "error", err, "db", dbName)
+	}
+
+	return cleaned
+}
+
+// keepOriginalURL records the URL the record was saved with when the URL
+// rules changed it.
+func (h *Handler) keepOriginalURL(ctx context.Context, repo models.Repo, bID int, original, cleaned string) {
+	if original == cleaned {
+		return
+	}
+	if err := repo.SaveOriginalURL(ctx, &models.OriginalURL{BookmarkID: bID, URL: original}); err != nil {
+		h.logger.Warn("saving original url", "error", err, "id", bID)
+	}
+}
+
+// updateRecord replaces the editable fields of the record named by the path.
 func (h *Handler) updateRecord(w http.ResponseWriter, r *http.Request) {
 	// FIX: use normal bookmark, drop BookmarkJSON
 	w.Header().Set("Content-Type", "application/json")
@@ -454,6 +751,7 @@ func (h *Handler) updateRecord(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	bj := bookmark.NewJSON()
 	if err := json.NewDecoder(r.Body).Decode(bj); err != nil {
@@ -467,29 +765,44 @@ func (h *Handler) updateRecord(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	newB := bookmark.NewFromJSON(bj)
-	oldB, err := repo.ByID(r.Context(), bj.ID)
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	if bj.ID != 0 && bj.ID != bID {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, fmt.Sprintf("%s: id=%d", ErrIDMismatch, bj.ID))
+		return
+	}
+	bj.ID = bID
+
+	oldB, err := repo.ByID(r.Context(), bID)
 	if err != nil {
-		h.logger.Error("updating bookmark", "error", err)
-		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		h.logger.Error("updating bookmark", "error", err, "id", bID)
+		status := http.StatusInternalServerError
+		if errors.Is(err, bookmark.ErrBookmarkNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
 		return
 	}
 
+	newB := bookmark.NewFromJSON(bj)
+	newB.Tags = database.TagNormalizer(dbName).Normalize(newB.Tags)
 	if err := bookmark.Validate(newB); err != nil {
 		h.logger.Error("updating bookmark", "error", err)
 		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	newB.GenChecksum()
 	newB.CreatedAt = oldB.CreatedAt
 	newB.LastVisit = oldB.LastVisit
 	newB.Favorite = oldB.Favorite
 	newB.VisitCount = oldB.VisitCount
 
-	if err := repo.UpdateOne(r.Context(), newB); err != nil {
+	if err := h.saveRecord(r.Context(), dbName, repo, oldB, newB); err != nil {
 		h.logger.Error("updating bookmark", "error", err)
-		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrRecordDuplicate) {
+			status = http.StatusBadRequest
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
 		return
 	}
 
@@ -501,6 +814,228 @@ func (h *Handler) updateRecord(w http.ResponseWriter, r *http.Request) {
 	responder.WriteJSON(w, http.StatusOK, res)
 }
 
+// patchRecord applies a JSON Merge Patch (RFC 7396) to the record named by
+// the path: only the members given change, and a null member clears its
+// field. It responds with the patched record.
+func (h *Handler) patchRecord(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, _ := mime.ParseMediaType(ct)
+		if mt != "application/merge-patch+json" && mt != "application/json" {
+			responder.EncodeErrJSON(w, http.StatusUnsupportedMediaType, "unsupported content type: "+ct)
+			return
+		}
+	}
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("patching bookmark", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "patch must be a JSON object")
+		return
+	}
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	oldB, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("patching bookmark", "error", err, "id", bID)
+		status := http.StatusInternalServerError
+		if errors.Is(err, bookmark.ErrBookmarkNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	newB := *oldB
+	if err := applyPatch(&newB, patch); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := patch["tags"]; ok {
+		newB.Tags = database.TagNormalizer(dbName).Normalize(newB.Tags)
+	}
+	if err := bookmark.Validate(&newB); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.saveRecord(r.Context(), dbName, repo, oldB, &newB); err != nil {
+		h.logger.Error("patching bookmark", "error", err, "id", bID)
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrRecordDuplicate) {
+			status = http.StatusBadRequest
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, newB.JSON())
+}
+
+// applyPatch sets the fields named by the members of a merge patch. Only
+// the fields a user edits can be patched; id, when given, must match the
+// record.
+func applyPatch(b *bookmark.Bookmark, patch map[string]json.RawMessage) error {
+	for _, k := range slices.Sorted(maps.Keys(patch)) {
+		v := patch[k]
+		var err error
+		switch k {
+		case "id":
+			var id int
+			if err = json.Unmarshal(v, &id); err == nil && id != b.ID {
+				return fmt.Errorf("%w: id=%d", ErrIDMismatch, id)
+			}
+		case "url":
+			err = patchValue(&b.URL, v)
+		case "title":
+			err = patchValue(&b.Title, v)
+		case "desc":
+			err = patchValue(&b.Desc, v)
+		case "notes":
+			err = patchValue(&b.Notes, v)
+		case "favorite":
+			err = patchValue(&b.Favorite, v)
+		case "tags":
+			var ts []string
+			if err = patchValue(&ts, v); err == nil {
+				b.Tags = strings.Join(ts, ",")
+			}
+		default:
+			return fmt.Errorf("%w: %q", ErrNotPatchable, k)
+		}
+		if err != nil {
+			return fmt.Errorf("field %q: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// patchValue decodes a merge patch member into dst; null sets the zero
+// value.
+func patchValue[T any](dst *T, v json.RawMessage) error {
+	var val T
+	if err := json.Unmarshal(v, &val); err != nil {
+		return err
+	}
+	*dst = val
+
+	return nil
+}
+
+// saveRecord writes the edited record. A changed URL is cleaned by the URL
+// rules, so short links are not resolved on every edit, and must not be one
+// of another record.
+func (h *Handler) saveRecord(ctx context.Context, dbName string, repo models.Repo, oldB, newB *bookmark.Bookmark) error {
+	original := newB.URL
+	if newB.URL != oldB.URL {
+		newB.URL = h.cleanURL(ctx, dbName, newB.URL)
+		if err := duplicateOf(ctx, repo, newB.URL, newB.ID); err != nil {
+			return err
+		}
+	}
+
+	newB.GenChecksum()
+	if err := repo.UpdateOne(ctx, newB); err != nil {
+		return err
+	}
+	h.keepOriginalURL(ctx, repo, newB.ID, original, newB.URL)
+
+	return nil
+}
+
+// recordHistory returns the revisions of the record, newest first, each
+// with the changes made right after it.
+func (h *Handler) recordHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("record history", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	b, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("record history", "error", err, "id", bID)
+		responder.EncodeErrJSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	revs, err := repo.Revisions(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("record history", "error", err, "id", bID)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.HistoryResponse{
+		BookmarkID: bID,
+		Current:    models.NewRevision(b),
+		Revisions:  make([]*responder.HistoryEntry, 0, len(revs)),
+	}
+
+	next := res.Current
+	for _, rev := range revs {
+		res.Revisions = append(res.Revisions, &responder.HistoryEntry{
+			Revision: rev,
+			Changes:  rev.Diff(next),
+		})
+		next = rev
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// restoreRevision sets the record back to the given revision.
+func (h *Handler) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("restore revision", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	revID, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || revID < 1 {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid revision id")
+		return
+	}
+
+	if _, err := repo.RestoreRevision(r.Context(), bID, revID); err != nil {
+		h.logger.Error("restore revision", "error", err, "id", bID, "rev", revID)
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrRevisionNotFound) || errors.Is(err, bookmark.ErrBookmarkNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    "Bookmark restored to revision " + strconv.Itoa(revID),
+		StatusCode: http.StatusOK,
+	})
+}
+
 func (h *Handler) updateNotes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -511,6 +1046,7 @@ func (h *Handler) updateNotes(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	type notes struct {
 		Notes string `json:"notes"`
@@ -553,6 +1089,7 @@ func (h *Handler) deleteRecord(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	idStr := r.PathValue("id")
 	bID, _ := strconv.Atoi(idStr)
@@ -587,6 +1124,7 @@ func (h *Handler) addVisit(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	idStr := r.PathValue("id")
 	bID, _ := strconv.Atoi(idStr)
@@ -616,6 +1154,7 @@ func (h *Handler) toggleFavorite(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	idStr := r.PathValue("id")
 	bID, _ := strconv.Atoi(idStr)
@@ -658,6 +1197,7 @@ func (h *Handler) tagsList(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
 	tags, err := repo.CountTags(r.Context())
 	if err != nil {
@@ -668,53 +1208,1423 @@ func (h *Handler) tagsList(w http.ResponseWriter, r *http.Request) {
 	responder.WriteJSON(w, http.StatusOK, tags)
 }
 
-func (h *Handler) snapshotURL(w http.ResponseWriter, r *http.Request) {
-	responder.WriteJSON(w, http.StatusOK, []string{})
-}
-
-func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	res := &responder.ResponseData{
-		Message:    "health OK",
-		StatusCode: http.StatusOK,
-	}
-
-	responder.WriteJSON(w, http.StatusOK, res)
-}
-
-func (h *Handler) checkStatus(w http.ResponseWriter, r *http.Request) {
+// suggestTags proposes existing tags for the page being saved, given by
+// the url, title, desc and tags query parameters.
+func (h *Handler) suggestTags(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	dbName := r.PathValue("db")
 	repo, err := h.repoLoader(dbName)
 	if err != nil {
-		h.logger.Error("bookmark status", "error", err, "db", dbName)
+		h.logger.Error("suggest tags", "error", err, "db", dbName)
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
-	idStr := r.PathValue("id")
-	bID, _ := strconv.Atoi(idStr)
-	b, err := repo.ByID(r.Context(), bID)
-	if err != nil {
-		h.logger.Error("bookmark status", "error", err)
-		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+	q := r.URL.Query()
+	if q.Get("url") == "" {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, models.ErrURLEmpty.Error())
+		return
+	}
+
+	bs, err := repo.All(r.Context())
+	if err != nil {
+		h.logger.Error("suggest tags", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	suggestions := tags.Suggest(bs, &tags.Page{
+		URL:   q.Get("url"),
+		Title: q.Get("title"),
+		Desc:  q.Get("desc"),
+		Tags:  tags.Parse(q.Get("tags")),
+	}, limit)
+
+	responder.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// tagsRename renames a tag in every bookmark of the repo.
+func (h *Handler) tagsRename(w http.ResponseWriter, r *http.Request) {
+	h.tagsApply(w, r, "rename tag", func(req *responder.TagsRequest) (tags.Edit, []string, error) {
+		if len(req.Tags) != 1 {
+			return nil, nil, fmt.Errorf("%w: rename takes a single tag", ErrNoTags)
+		}
+		if err := tags.Validate(req.To); err != nil {
+			return nil, nil, err
+		}
+		return tags.Replace(req.Tags, req.To), append(req.Tags, req.To), nil
+	})
+}
+
+// tagsMerge replaces several tags with one in every bookmark of the repo.
+func (h *Handler) tagsMerge(w http.ResponseWriter, r *http.Request) {
+	h.tagsApply(w, r, "merge tags", func(req *responder.TagsRequest) (tags.Edit, []string, error) {
+		if len(req.Tags) == 0 {
+			return nil, nil, ErrNoTags
+		}
+		if err := tags.Validate(req.To); err != nil {
+			return nil, nil, err
+		}
+		return tags.Replace(req.Tags, req.To), append(req.Tags, req.To), nil
+	})
+}
+
+// tagsDelete removes tags from every bookmark of the repo.
+func (h *Handler) tagsDelete(w http.ResponseWriter, r *http.Request) {
+	h.tagsApply(w, r, "delete tags", func(req *responder.TagsRequest) (tags.Edit, []string, error) {
+		if len(req.Tags) == 0 {
+			return nil, nil, ErrNoTags
+		}
+		return tags.Remove(req.Tags...), req.Tags, nil
+	})
+}
+
+// tagsRetag adds and removes tags on the selected bookmarks.
+func (h *Handler) tagsRetag(w http.ResponseWriter, r *http.Request) {
+	h.tagsApply(w, r, "retag bookmarks", func(req *responder.TagsRequest) (tags.Edit, []string, error) {
+		if len(req.IDs) == 0 {
+			return nil, nil, ErrNoSelection
+		}
+		if len(req.Add) == 0 && len(req.Remove) == 0 {
+			return nil, nil, ErrNoTags
+		}
+		for _, t := range req.Add {
+			if err := tags.Validate(t); err != nil {
+				return nil, nil, err
+			}
+		}
+		return tags.Retag(req.Add, req.Remove), append(slices.Clone(req.Add), req.Remove...), nil
+	})
+}
+
+// Bulk operations.
+const (
+	bulkDelete     = "delete"
+	bulkFavorite   = "favorite"
+	bulkUnfavorite = "unfavorite"
+	bulkAddTags    = "add-tags"
+	bulkRemoveTags = "remove-tags"
+	bulkMove       = "move"
+	bulkCheck      = "check"
+	bulkMetadata   = "metadata"
+)
+
+// bulk runs an operation on the bookmarks selected by ID, or on the ones
+// matching the list filter, and reports its outcome on each of them.
+//
+// The writes are all-or-nothing: they are made in one batch per repository,
+// so a failure leaves every bookmark as it was. A move commits the copies
+// before deleting the originals. The checks and metadata scrapes record what
+// they fetched and are kept on each bookmark that succeeds.
+//
+//nolint:funlen //ignore
+func (h *Handler) bulk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("bulk", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	var req responder.BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// a filter without criteria would select every bookmark.
+	if f := req.Filter; f != nil && f.Tag == "" && f.Query == "" && f.Letter == "" {
+		req.Filter = nil
+	}
+	if len(req.IDs) == 0 && req.Filter == nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, ErrNoSelection.Error())
+		return
+	}
+
+	// dst is the batch of the target repository of a move.
+	var (
+		dst models.Batch
+		fn  bulkFn
+	)
+	switch req.Op {
+	case bulkDelete:
+		fn = func(ctx context.Context, tx models.Batch, b *bookmark.Bookmark, _ *responder.BulkItem) error {
+			return tx.DeleteMany(ctx, []*bookmark.Bookmark{b})
+		}
+
+	case bulkFavorite, bulkUnfavorite:
+		fn = func(ctx context.Context, tx models.Batch, b *bookmark.Bookmark, _ *responder.BulkItem) error {
+			b.Favorite = req.Op == bulkFavorite
+			return tx.SetFavorite(ctx, b)
+		}
+
+	case bulkAddTags, bulkRemoveTags:
+		if len(req.Tags) == 0 {
+			responder.EncodeErrJSON(w, http.StatusBadRequest, ErrNoTags.Error())
+			return
+		}
+		edit := tags.Retag(nil, req.Tags)
+		if req.Op == bulkAddTags {
+			for _, t := range req.Tags {
+				if err := tags.Validate(t); err != nil {
+					responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+					return
+				}
+			}
+			edit = tags.Retag(req.Tags, nil)
+		}
+		edit = database.TagNormalizer(dbName).Edit(edit)
+		fn = func(ctx context.Context, tx models.Batch, b *bookmark.Bookmark, _ *responder.BulkItem) error {
+			ts := tags.Parse(b.Tags)
+			next := edit(ts)
+			if tags.Join(next) == tags.Join(ts) {
+				return nil
+			}
+			b.Tags = tags.Join(next)
+			b.GenChecksum()
+			return tx.UpdateOne(ctx, b)
+		}
+
+	case bulkMove:
+		target, status, err := h.bulkTarget(dbName, req.Repo)
+		if err != nil {
+			responder.EncodeErrJSON(w, status, err.Error())
+			return
+		}
+		defer target.Close()
+		if dst, err = target.Begin(r.Context()); err != nil {
+			h.logger.Error("bulk: target repo", "error", err, "db", req.Repo)
+			responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer func() { _ = dst.Rollback() }()
+		n := database.TagNormalizer(req.Repo)
+		// the copies are not visible to the target until committed.
+		moving := make(map[string]int)
+		fn = func(ctx context.Context, tx models.Batch, b *bookmark.Bookmark, item *responder.BulkItem) error {
+			if err := duplicateOf(ctx, target, b.URL, 0); err != nil {
+				return err
+			}
+			k := urlclean.Canonical(b.URL)
+			if id, ok := moving[k]; ok {
+				return fmt.Errorf("%w: #%d moved along", models.ErrRecordDuplicate, id)
+			}
+			moving[k] = b.ID
+			moved := *b
+			moved.ID = 0
+			moved.Tags = n.Normalize(b.Tags)
+			id, err := dst.InsertOne(ctx, &moved)
+			if err != nil {
+				return err
+			}
+			if err := tx.DeleteMany(ctx, []*bookmark.Bookmark{b}); err != nil {
+				return err
+			}
+			item.NewID = int(id)
+			return nil
+		}
+
+	case bulkCheck:
+		if h.checker == nil {
+			responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "link checking disabled")
+			return
+		}
+		fn = func(ctx context.Context, _ models.Batch, b *bookmark.Bookmark, _ *responder.BulkItem) error {
+			return h.recheck(ctx, dbName, repo, b)
+		}
+
+	case bulkMetadata:
+		if h.archiver == nil {
+			responder.EncodeErrJSON(w, http.StatusServiceUnavailable, archive.ErrDisabled.Error())
+			return
+		}
+		fn = func(ctx context.Context, _ models.Batch, b *bookmark.Bookmark, _ *responder.BulkItem) error {
+			_, err := h.archiver.Metadata(ctx, dbName, b.ID)
+			return err
+		}
+
+	default:
+		responder.EncodeErrJSON(w, http.StatusBadRequest, fmt.Sprintf("%s: %q", ErrUnknownOp, req.Op))
+		return
+	}
+
+	items, found, err := h.bulkSelect(r.Context(), repo, &req)
+	if err != nil {
+		h.logger.Error("bulk", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if req.Op == bulkCheck || req.Op == bulkMetadata {
+		for _, item := range items {
+			if b := found[item.ID]; b != nil {
+				h.bulkItem(item, fn(r.Context(), nil, b, item), req.Op, dbName)
+			}
+		}
+	} else if err := h.bulkApply(r.Context(), repo, dst, items, found, fn); err != nil {
+		h.logger.Error("bulk", "op", req.Op, "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.BulkResult{Op: req.Op, Total: len(items), Items: items}
+	for _, item := range items {
+		if item.OK {
+			res.Succeeded++
+		} else {
+			res.Failed++
+		}
+	}
+	h.logger.Info("bulk", "op", req.Op, "db", dbName, "succeeded", res.Succeeded, "failed", res.Failed)
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// bulkFn applies a bulk operation to a bookmark, writing through tx.
+type bulkFn func(ctx context.Context, tx models.Batch, b *bookmark.Bookmark, item *responder.BulkItem) error
+
+// bulkApply runs fn on the found bookmarks in a batch of the repository,
+// committed along with dst, the batch of the target repository of a move,
+// when given. When fn fails on a bookmark nothing is committed and the other
+// items report ErrBulkAborted. The returned error is a batch failing to begin
+// or commit.
+func (h *Handler) bulkApply(
+	ctx context.Context,
+	repo models.Repo,
+	dst models.Batch,
+	items []*responder.BulkItem,
+	found map[int]*bookmark.Bookmark,
+	fn bulkFn,
+) error {
+	tx, err := repo.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, item := range items {
+		b := found[item.ID]
+		if b == nil {
+			continue
+		}
+		if err := fn(ctx, tx, b, item); err != nil {
+			item.Error = err.Error()
+			for _, other := range items {
+				if other != item && found[other.ID] != nil {
+					other.Error, other.NewID = ErrBulkAborted.Error(), 0
+				}
+			}
+			item.NewID = 0
+			return nil
+		}
+	}
+
+	// the copies of a move are committed first, so a failure to commit the
+	// deletes leaves duplicates instead of losing bookmarks.
+	if dst != nil {
+		if err := dst.Commit(); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		if dst != nil {
+			h.logger.Error("bulk: moved bookmarks left in the source", "error", err)
+		}
+		return err
+	}
+
+	for _, item := range items {
+		item.OK = found[item.ID] != nil
+	}
+
+	return nil
+}
+
+// bulkItem records the outcome of an operation on a single bookmark.
+func (h *Handler) bulkItem(item *responder.BulkItem, err error, op, dbName string) {
+	if err != nil {
+		h.logger.Warn("bulk", "op", op, "error", err, "db", dbName, "id", item.ID)
+		item.Error = err.Error()
+		return
+	}
+	item.OK = true
+}
+
+// bulkSelect returns an item for every bookmark selected by the request,
+// along with the bookmarks found by ID. Unknown IDs get a failed item.
+func (h *Handler) bulkSelect(
+	ctx context.Context,
+	repo models.Repo,
+	req *responder.BulkRequest,
+) ([]*responder.BulkItem, map[int]*bookmark.Bookmark, error) {
+	var bs []*bookmark.Bookmark
+	if req.Filter != nil {
+		all, err := repo.All(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		f := req.Filter
+		bs = helpers.ApplyFiltersAndSorting(f.Tag, f.Query, f.Letter, f.FilterBy, all)
+	}
+
+	items := make([]*responder.BulkItem, 0, len(req.IDs)+len(bs))
+	found := make(map[int]*bookmark.Bookmark, len(items))
+	for _, id := range req.IDs {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		item := &responder.BulkItem{ID: id}
+		b, err := repo.ByID(ctx, id)
+		if err != nil {
+			item.Error = err.Error()
+		}
+		found[id] = b
+		items = append(items, item)
+	}
+	for _, b := range bs {
+		if _, ok := found[b.ID]; !ok {
+			found[b.ID] = b
+			items = append(items, &responder.BulkItem{ID: b.ID})
+		}
+	}
+
+	return items, found, nil
+}
+
+// bulkTarget loads the repository the bookmarks are moved to. The caller
+// closes it.
+func (h *Handler) bulkTarget(dbName, target string) (models.Repo, int, error) {
+	switch {
+	case target == "":
+		return nil, http.StatusBadRequest, ErrNoTargetRepo
+	case target == dbName:
+		return nil, http.StatusBadRequest, fmt.Errorf("bookmarks already in %q", target)
+	case database.IsReadOnly(target):
+		return nil, http.StatusForbidden, fmt.Errorf("repository %q is read-only", target)
+	}
+
+	repo, err := h.repoLoader(target)
+	if err != nil {
+		h.logger.Error("bulk: target repo", "error", err, "db", target)
+		return nil, http.StatusNotFound, err
+	}
+
+	return repo, http.StatusOK, nil
+}
+
+// tagsNormalize rewrites the tags of every bookmark of the repo with its
+// tag rules and aliases.
+func (h *Handler) tagsNormalize(w http.ResponseWriter, r *http.Request) {
+	n := database.TagNormalizer(r.PathValue("db"))
+	h.tagsApply(w, r, "normalize tags", func(*responder.TagsRequest) (tags.Edit, []string, error) {
+		return n.All(), nil, nil
+	})
+}
+
+// tagsApply plans the edit built from the request body over the bookmarks
+// of the repo, or over the selected ones, and saves it unless a preview
+// was asked for. The changed tags go through the repo normalization and
+// each changed bookmark gets a revision.
+func (h *Handler) tagsApply(
+	w http.ResponseWriter,
+	r *http.Request,
+	action string,
+	build func(req *responder.TagsRequest) (tags.Edit, []string, error),
+) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error(action, "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	var req responder.TagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	edit, involved, err := build(&req)
+	if err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	all, err := repo.All(r.Context())
+	if err != nil {
+		h.logger.Error(action, "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	targets := all
+	if len(req.IDs) > 0 {
+		targets = make([]*bookmark.Bookmark, 0, len(req.IDs))
+		for _, b := range all {
+			if slices.Contains(req.IDs, b.ID) {
+				targets = append(targets, b)
+			}
+		}
+	}
+
+	changes := tags.Plan(targets, database.TagNormalizer(dbName).Edit(edit))
+	res := &responder.TagsResult{
+		Preview:  req.Preview,
+		Affected: len(changes),
+		Changes:  changes,
+	}
+	res.Before, res.After = tags.Count(changes, involved, all)
+
+	if !req.Preview {
+		if err := applyTagChanges(r.Context(), repo, changes, res); err != nil {
+			h.logger.Error(action, "error", err, "db", dbName)
+			responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if res.Failed != nil {
+			h.logger.Warn(action+": rolled back", "db", dbName, "failed", res.Failed)
+		} else {
+			h.logger.Info(action, "db", dbName, "changed", len(changes))
+		}
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// applyTagChanges writes the changes in a batch of the repository, all or
+// nothing: the first failed update rolls the batch back and is reported in
+// res.Failed, along with the changes it aborted.
+func applyTagChanges(ctx context.Context, repo models.Repo, changes []*tags.Change, res *responder.TagsResult) error {
+	tx, err := repo.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, c := range changes {
+		b := c.Bookmark
+		b.Tags = c.After
+		b.GenChecksum()
+		if err := tx.UpdateOne(ctx, b); err != nil {
+			res.Failed = make(map[int]string, len(changes))
+			for _, other := range changes {
+				res.Failed[other.Bookmark.ID] = ErrBulkAborted.Error()
+			}
+			res.Failed[b.ID] = err.Error()
+			return nil
+		}
+	}
+
+	return tx.Commit()
+}
+
+// tagAliases returns the tag alias rules of the repo.
+func (h *Handler) tagAliases(w http.ResponseWriter, r *http.Request) {
+	aliases := database.TagAliases(r.PathValue("db"))
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+
+	responder.WriteJSON(w, http.StatusOK, map[string]map[string]string{"aliases": aliases})
+}
+
+// tagAliasesSet replaces the tag alias rules of the repo. They apply to the
+// bookmarks saved or imported from now on.
+func (h *Handler) tagAliasesSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Aliases map[string]string `json:"aliases"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid request: expected {\"aliases\": {\"from\": \"to\"}}")
+		return
+	}
+
+	aliases, err := tags.NewAliases(req.Aliases)
+	if err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.UpdateSettings(dbName, func(s *database.Settings) { s.TagAliases = aliases }); err != nil {
+		h.logger.Error("tag aliases", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.logger.Info("tag aliases", "repo", dbName, "rules", len(aliases))
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    fmt.Sprintf("%d tag aliases saved", len(aliases)),
+		StatusCode: http.StatusOK,
+	})
+}
+
+// tagRules returns the tag normalization rules of the repo.
+func (h *Handler) tagRules(w http.ResponseWriter, r *http.Request) {
+	s, _ := database.GetSettings(r.PathValue("db"))
+	responder.WriteJSON(w, http.StatusOK, s.TagRules)
+}
+
+// tagRulesSet replaces the tag normalization rules of the repo. They apply
+// to the tags written from now on; tagsNormalize rewrites the stored ones.
+func (h *Handler) tagRulesSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rules tags.Rules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.UpdateSettings(dbName, func(s *database.Settings) { s.TagRules = rules }); err != nil {
+		h.logger.Error("tag rules", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.logger.Info("tag rules", "repo", dbName, "rules", rules)
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    "tag rules saved",
+		StatusCode: http.StatusOK,
+	})
+}
+
+// snapshotURL looks up the closest Wayback Machine snapshot of a URL. With
+// save=true the service is asked to capture the page first.
+func (h *Handler) snapshotURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	u := r.URL.Query().Get("url")
+	if u == "" {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "empty URL")
+		return
+	}
+
+	s, status, err := h.waybackSnapshot(w, r, u)
+	if err != nil {
+		h.logger.Error("wayback", "error", err, "url", u)
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.FetchSnapshotResponse{
+		URL:              u,
+		ArchiveURL:       s.URL,
+		ArchiveTimestamp: s.Timestamp,
+	})
+}
+
+// recordWayback looks up, or saves with save=true, the Wayback Machine
+// snapshot of the record and stores it.
+func (h *Handler) recordWayback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("wayback", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	b, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("wayback", "error", err, "db", dbName, "id", bID)
+		responder.EncodeErrJSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s, status, err := h.waybackSnapshot(w, r, b.URL)
+	if err != nil {
+		h.logger.Error("wayback", "error", err, "db", dbName, "id", bID)
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	b.ArchiveURL = s.URL
+	b.ArchiveTimestamp = s.Timestamp
+	if err := repo.UpdateOne(r.Context(), b); err != nil {
+		h.logger.Error("wayback", "error", err, "db", dbName, "id", bID)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.FetchSnapshotResponse{
+		URL:              b.URL,
+		ArchiveURL:       s.URL,
+		ArchiveTimestamp: s.Timestamp,
+	})
+}
+
+// waybackSnapshot returns the snapshot of u and, on failure, the status to
+// report.
+func (h *Handler) waybackSnapshot(w http.ResponseWriter, r *http.Request, u string) (*wayback.Snapshot, int, error) {
+	if h.wayback == nil {
+		return nil, http.StatusServiceUnavailable, ErrWaybackDisabled
+	}
+
+	var (
+		s   *wayback.Snapshot
+		err error
+		ctx = fetch.WithRepo(r.Context(), r.PathValue("db"))
+	)
+	if save, _ := strconv.ParseBool(r.URL.Query().Get("save")); save {
+		// saving a page takes longer than the server write timeout.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wayback.DefaultTimeout))
+		s, err = h.wayback.Save(ctx, u)
+	} else {
+		s, err = h.wayback.Closest(ctx, u)
+	}
+
+	switch {
+	case err == nil:
+		return s, http.StatusOK, nil
+	case errors.Is(err, wayback.ErrNotFound):
+		return nil, http.StatusNotFound, err
+	case errors.Is(err, wayback.ErrRateLimited):
+		return nil, http.StatusTooManyRequests, err
+	case errors.Is(err, wayback.ErrUnavailable):
+		return nil, http.StatusServiceUnavailable, err
+	default:
+		return nil, http.StatusBadGateway, err
+	}
+}
+
+func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	res := &responder.ResponseData{
+		Message:    "health OK",
+		StatusCode: http.StatusOK,
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// deadLinks returns the checked records that are no longer reachable.
+func (h *Handler) deadLinks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("dead links", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(r.Context())
+	if err != nil {
+		h.logger.Error("dead links", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.DeadLinksResponse{
+		Repo:  dbName,
+		Total: len(bs),
+		Dead:  linkcheck.Dead(bs),
+	}
+	if h.checker != nil {
+		st := h.checker.Status(dbName)
+		res.LastRun = &st
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// snapshotList returns the local snapshots of the record.
+func (h *Handler) snapshotList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("snapshots", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	idStr := r.PathValue("id")
+	bID, _ := strconv.Atoi(idStr)
+	ss, err := repo.Snapshots(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("snapshots", "error", err, "db", dbName, "id", bID)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.SnapshotsResponse{
+		BookmarkID: bID,
+		ViewURL:    router.New(dbName).Web.View(idStr),
+		Snapshots:  ss,
+	})
+}
+
+// snapshotCreate saves a local snapshot of the record page.
+func (h *Handler) snapshotCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.archiver == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, archive.ErrDisabled.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	s, err := h.archiver.Archive(r.Context(), dbName, bID)
+	if err != nil {
+		h.logger.Error("snapshot", "error", err, "db", dbName, "id", bID)
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, bookmark.ErrBookmarkNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, archive.ErrQuotaExceeded):
+			status = http.StatusInsufficientStorage
+		case errors.Is(err, fetch.ErrForbiddenAddress):
+			status = http.StatusForbidden
+		case errors.Is(err, archive.ErrNotHTML):
+			status = http.StatusUnsupportedMediaType
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusCreated, s)
+}
+
+// snapshotDelete removes a local snapshot of the record.
+func (h *Handler) snapshotDelete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.archiver == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, archive.ErrDisabled.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	sID, err := strconv.Atoi(r.PathValue("sid"))
+	if err != nil || sID < 1 {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid snapshot id")
+		return
+	}
+
+	if err := h.archiver.Delete(r.Context(), dbName, bID, sID); err != nil {
+		h.logger.Error("delete snapshot", "error", err, "db", dbName, "id", bID, "snapshot", sID)
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrSnapshotNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    "snapshot deleted",
+		StatusCode: http.StatusOK,
+	})
+}
+
+// articleGet returns the article extracted from the record page.
+func (h *Handler) articleGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("article", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	a, err := repo.Article(r.Context(), bID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrArticleNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, a)
+}
+
+// articleExtract fetches the record page and stores its readable article.
+func (h *Handler) articleExtract(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.archiver == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, archive.ErrDisabled.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	a, err := h.archiver.Extract(r.Context(), dbName, bID)
+	if err != nil {
+		h.logger.Error("article", "error", err, "db", dbName, "id", bID)
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, bookmark.ErrBookmarkNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, fetch.ErrForbiddenAddress):
+			status = http.StatusForbidden
+		case errors.Is(err, archive.ErrNotHTML):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, reader.ErrNoContent):
+			status = http.StatusUnprocessableEntity
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusCreated, a)
+}
+
+// metadataGet returns the metadata saved for the record.
+func (h *Handler) metadataGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("metadata", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	m, err := repo.Metadata(r.Context(), bID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrMetadataNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, m)
+}
+
+// metadataScrape scrapes the record page and saves its metadata.
+func (h *Handler) metadataScrape(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.archiver == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, archive.ErrDisabled.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	m, err := h.archiver.Metadata(r.Context(), dbName, bID)
+	if err != nil {
+		h.logger.Error("metadata", "error", err, "db", dbName, "id", bID)
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, bookmark.ErrBookmarkNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, fetch.ErrForbiddenAddress):
+			status = http.StatusForbidden
+		case errors.Is(err, archive.ErrNotHTML):
+			status = http.StatusUnsupportedMediaType
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusCreated, m)
+}
+
+// faviconRefresh discards the cached favicon of the record's domain and
+// fetches it again.
+func (h *Handler) faviconRefresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.favicons == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "favicon cache disabled")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	b, err := h.favicons.Refresh(r.Context(), dbName, bID)
+	if err != nil {
+		h.logger.Error("favicon refresh", "error", err, "db", dbName, "id", bID)
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, bookmark.ErrBookmarkNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, fetch.ErrForbiddenAddress):
+			status = http.StatusForbidden
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
 		return
 	}
 
-	if err := b.CheckStatus(r.Context()); err != nil {
-		h.logger.Error("bookmark status", "error", err)
+	responder.WriteJSON(w, http.StatusOK, b)
+}
+
+// faviconsRefresh refreshes the favicons of every record of the repo in the
+// background.
+func (h *Handler) faviconsRefresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.favicons == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "favicon cache disabled")
+		return
 	}
 
-	if b.FaviconURL == "" {
-		sc := scraper.New(b.URL)
-		_ = sc.Start()
-		b.FaviconURL, _ = sc.Favicon()
+	dbName := r.PathValue("db")
+	// the refresh outlives the request.
+	if err := h.favicons.Trigger(context.WithoutCancel(r.Context()), dbName); err != nil {
+		h.logger.Warn("favicons refresh", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusConflict, err.Error())
+		return
 	}
 
-	if err := repo.UpdateOne(r.Context(), b); err != nil {
+	responder.WriteJSON(w, http.StatusAccepted, &responder.ResponseData{
+		Message:    "favicon refresh started: " + dbName,
+		StatusCode: http.StatusAccepted,
+	})
+}
+
+// faviconMetrics returns the state of the favicon workers.
+func (h *Handler) faviconMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.favicons == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "favicon cache disabled")
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, h.favicons.Metrics())
+}
+
+// archiveUsage returns the space used by the snapshots of the repo.
+func (h *Handler) archiveUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("archive usage", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	used, err := repo.ArchiveSize(r.Context())
+	if err != nil {
+		h.logger.Error("archive usage", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.ArchiveResponse{Repo: dbName, Used: used}
+	if h.archiver != nil {
+		res.Quota = h.archiver.Quota(dbName)
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// archiveQuota sets the snapshot space of the repo.
+func (h *Handler) archiveQuota(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		QuotaMB *int `json:"quota_mb"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.QuotaMB == nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "invalid request: expected {\"quota_mb\": int}")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.UpdateSettings(dbName, func(s *database.Settings) { s.ArchiveQuotaMB = *req.QuotaMB }); err != nil {
+		h.logger.Error("archive quota", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.archiveUsage(w, r)
+}
+
+// redirectsList returns the records whose URL redirects to a new location.
+func (h *Handler) redirectsList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("redirects", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	rs, err := repo.Redirects(r.Context())
+	if err != nil {
+		h.logger.Error("redirects", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.RedirectsResponse{Repo: dbName, Total: len(rs), Redirects: rs}
+	for _, rd := range rs {
+		if rd.Permanent {
+			res.Permanent++
+		}
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// redirectsAccept moves the selected records to their final URL.
+func (h *Handler) redirectsAccept(w http.ResponseWriter, r *http.Request) {
+	h.redirectsApply(w, r, "accept redirects", func(ctx context.Context, repo models.Repo, bID int) error {
+		_, err := repo.AcceptRedirect(ctx, bID)
+		return err
+	}, func(res *responder.RedirectsResult, bID int) {
+		res.Accepted = append(res.Accepted, bID)
+	})
+}
+
+// redirectsDismiss discards the selected redirects, keeping the stored URL.
+func (h *Handler) redirectsDismiss(w http.ResponseWriter, r *http.Request) {
+	h.redirectsApply(w, r, "dismiss redirects", func(ctx context.Context, repo models.Repo, bID int) error {
+		return repo.ClearRedirect(ctx, bID)
+	}, func(res *responder.RedirectsResult, bID int) {
+		res.Dismissed = append(res.Dismissed, bID)
+	})
+}
+
+// redirectsApply runs fn on every redirect selected by the request body.
+func (h *Handler) redirectsApply(
+	w http.ResponseWriter,
+	r *http.Request,
+	action string,
+	fn func(ctx context.Context, repo models.Repo, bID int) error,
+	done func(res *responder.RedirectsResult, bID int),
+) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error(action, "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	var req responder.RedirectsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !req.All && len(req.IDs) == 0 {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "no redirects selected")
+		return
+	}
+
+	ids := req.IDs
+	if req.All {
+		rs, err := repo.Redirects(r.Context())
+		if err != nil {
+			h.logger.Error(action, "error", err, "db", dbName)
+			responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		ids = ids[:0]
+		for _, rd := range rs {
+			if rd.Permanent || !req.PermanentOnly {
+				ids = append(ids, rd.BookmarkID)
+			}
+		}
+	}
+
+	res := &responder.RedirectsResult{}
+	for _, bID := range ids {
+		if err := fn(r.Context(), repo, bID); err != nil {
+			h.logger.Warn(action, "error", err, "db", dbName, "id", bID)
+			if res.Failed == nil {
+				res.Failed = make(map[int]string)
+			}
+			res.Failed[bID] = err.Error()
+			continue
+		}
+		done(res, bID)
+	}
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// duplicates lists the bookmarks of the repo saved more than once.
+func (h *Handler) duplicates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("duplicates", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(r.Context())
+	if err != nil {
+		h.logger.Error("duplicates", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	groups := dupes.Find(bs)
+	if groups == nil {
+		groups = []*dupes.Group{}
+	}
+
+	responder.WriteJSON(w, http.StatusOK, &responder.DuplicatesResponse{Groups: groups})
+}
+
+// duplicatesMerge merges the selected duplicates into the one kept, the
+// richest unless given, and deletes the others.
+func (h *Handler) duplicatesMerge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("merge duplicates", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	var req responder.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.IDs) < 2 {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, "select at least two bookmarks to merge")
+		return
+	}
+	if req.Keep != 0 && !slices.Contains(req.IDs, req.Keep) {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, fmt.Sprintf("bookmark #%d is not selected", req.Keep))
+		return
+	}
+
+	bs := make([]*bookmark.Bookmark, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		if slices.ContainsFunc(bs, func(b *bookmark.Bookmark) bool { return b.ID == id }) {
+			continue
+		}
+		b, err := repo.ByID(r.Context(), id)
+		if err != nil {
+			responder.EncodeErrJSON(w, http.StatusNotFound, fmt.Sprintf("bookmark #%d: %s", id, err))
+			return
+		}
+		bs = append(bs, b)
+	}
+
+	keep := dupes.Richest(bs)
+	if req.Keep != 0 {
+		keep = bs[slices.IndexFunc(bs, func(b *bookmark.Bookmark) bool { return b.ID == req.Keep })]
+	}
+	others := slices.DeleteFunc(slices.Clone(bs), func(b *bookmark.Bookmark) bool { return b == keep })
+
+	dupes.Merge(keep, others)
+	keep.Tags = database.TagNormalizer(dbName).Normalize(keep.Tags)
+	keep.GenChecksum()
+	if err := mergeInto(r.Context(), repo, keep, others); err != nil {
+		h.logger.Error("merge duplicates", "error", err, "db", dbName, "id", keep.ID)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := &responder.MergeResult{Bookmark: keep}
+	for _, b := range others {
+		res.Deleted = append(res.Deleted, b.ID)
+	}
+	h.logger.Info("merge duplicates", "db", dbName, "kept", keep.ID, "deleted", res.Deleted)
+
+	responder.WriteJSON(w, http.StatusOK, res)
+}
+
+// mergeInto updates keep and merges the others into it in one batch, so a
+// failure leaves every bookmark as it was.
+func mergeInto(ctx context.Context, repo models.Repo, keep *bookmark.Bookmark, others []*bookmark.Bookmark) error {
+	tx, err := repo.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := tx.UpdateOne(ctx, keep); err != nil {
+		return err
+	}
+	if err := tx.Merge(ctx, keep, others); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// urlRules returns the rules cleaning the URLs saved to the repo.
+func (h *Handler) urlRules(w http.ResponseWriter, r *http.Request) {
+	responder.WriteJSON(w, http.StatusOK, database.URLRules(r.PathValue("db")))
+}
+
+// urlRulesSet replaces the rules cleaning the URLs saved to the repo. The
+// URLs already saved are left as they are.
+func (h *Handler) urlRulesSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rules urlclean.Rules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		responder.EncodeErrJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbName := r.PathValue("db")
+	if err := database.UpdateSettings(dbName, func(s *database.Settings) { s.URLRules = rules }); err != nil {
+		h.logger.Error("url rules", "error", err, "repo", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.logger.Info("url rules", "repo", dbName, "rules", rules)
+	responder.WriteJSON(w, http.StatusOK, &responder.ResponseData{
+		Message:    "url rules saved",
+		StatusCode: http.StatusOK,
+	})
+}
+
+// originalURL returns the URL the record was saved with before the URL
+// rules cleaned it.
+func (h *Handler) originalURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("original url", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	o, err := repo.OriginalURL(r.Context(), bID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrOriginalURLNotFound) {
+			status = http.StatusNotFound
+		}
+		responder.EncodeErrJSON(w, status, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, o)
+}
+
+// linkCheckStatus returns the state of the last link check of the repo.
+func (h *Handler) linkCheckStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.checker == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "link checker disabled")
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusOK, h.checker.Status(r.PathValue("db")))
+}
+
+// linkCheckRun starts checking every record of the repo in the background.
+func (h *Handler) linkCheckRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.checker == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "link checker disabled")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	// the check outlives the request.
+	if err := h.checker.Trigger(context.WithoutCancel(r.Context()), dbName); err != nil {
+		h.logger.Warn("link check", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	responder.WriteJSON(w, http.StatusAccepted, &responder.ResponseData{
+		Message:    "link check started: " + dbName,
+		StatusCode: http.StatusAccepted,
+	})
+}
+
+func (h *Handler) checkStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.checker == nil {
+		responder.EncodeErrJSON(w, http.StatusServiceUnavailable, "link checking disabled")
+		return
+	}
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		h.logger.Error("bookmark status", "error", err, "db", dbName)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer repo.Close()
+
+	idStr := r.PathValue("id")
+	bID, _ := strconv.Atoi(idStr)
+	b, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		h.logger.Error("bookmark status", "error", err)
+		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.recheck(r.Context(), dbName, repo, b); err != nil {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -722,6 +2632,25 @@ func (h *Handler) checkStatus(w http.ResponseWriter, r *http.Request) {
 	responder.WriteJSON(w, http.StatusOK, b)
 }
 
+// recheck checks the URL of the record, saving only its status and its
+// redirect, and fills in a missing favicon. A failed check is recorded in
+// the status text, only a failed save is returned.
+func (h *Handler) recheck(ctx context.Context, dbName string, repo models.Repo, b *bookmark.Bookmark) error {
+	ctx = fetch.WithRepo(ctx, dbName)
+	if _, err := h.checker.CheckBookmark(ctx, repo, b); err != nil {
+		return err
+	}
+
+	if b.FaviconURL == "" && h.scraper != nil {
+		if m, err := h.scraper.Scrape(ctx, b.URL); err == nil && m.FaviconURL != "" {
+			b.FaviconURL = m.FaviconURL
+			return repo.SetFavicon(ctx, b.ID, b.FaviconURL, b.FaviconLocal)
+		}
+	}
+
+	return nil
+}
+
 //nolint:funlen //ignore
 func (h *Handler) importHTML(w http.ResponseWriter, r *http.Request) {
 	const maxSize = 5 << 20 // 5 MB
@@ -775,15 +2704,21 @@ func (h *Handler) importHTML(w http.ResponseWriter, r *http.Request) {
 		responder.EncodeErrJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer repo.Close()
 
+	normalizer := database.TagNormalizer(dbName)
 	bs := make([]*bookmark.Bookmark, 0, len(bns))
+	seen := make(map[string]bool, len(bns))
 	duplicated := 0
 	for i := range bns {
 		b := bookio.FromNetscape(&bns[i])
-		if _, exists := repo.Has(r.Context(), b.URL); exists {
+		k := urlclean.Canonical(b.URL)
+		if _, exists := repo.ByCanonicalURL(r.Context(), b.URL); exists || seen[k] {
 			duplicated++
 			continue
 		}
+		seen[k] = true
+		b.Tags = normalizer.Normalize(b.Tags)
 
 		bs = append(bs, b)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 )
 
 type App struct {
@@ -34,8 +35,21 @@ func New(ver string) *App {
 			Addr: ":8080",
 		},
 		Server: &Server{
-			QRImgSize:    512,
-			ItemsPerPage: 32,
+			QRImgSize:       512,
+			ItemsPerPage:    32,
+			RepoIdleTimeout: 15 * time.Minute,
+			CheckInterval:   24 * time.Hour,
+			CheckWorkers:    8,
+			CheckHostDelay:  2 * time.Second,
+			ArchiveNew:      true,
+			ArchiveQuotaMB:  512,
+			WaybackURL:      "https://web.archive.org",
+			FetchTimeout:    30 * time.Second,
+			FetchMaxSizeMB:  10,
+			UserAgent:       "gmweb (+https://github.com/mateconpizza/gmweb)",
+			FaviconGC:       24 * time.Hour,
+			FaviconBackoff:  time.Hour,
+			FaviconWorkers:  4,
 		},
 	}
 }
@@ -50,8 +64,29 @@ Usage:
 Options:
   -p, --path <path>	Path to store data (default: %s)
   -a, --addr <addr>	Address to listen on (default: %s)
+      --idle-timeout <d>	Close repositories unused for <d> (default: %s)
+      --read-only <repos>	Comma-separated repositories served read-only
+      --check-interval <d>	Re-check all links every <d>, 0 disables (default: %s)
+      --check-workers <n>	Concurrent link checks per repository (default: %d)
+      --check-host-delay <d>	Minimum delay between requests to a host (default: %s)
+      --archive-new		Save a snapshot of new bookmarks (default: %t)
+      --archive-quota <MiB>	Snapshot space per repository, -1 unlimited (default: %d)
+      --wayback-url <url>	Wayback Machine base URL, empty disables (default: %s)
+      --fetch-timeout <d>	Timeout of outbound requests (default: %s)
+      --fetch-max-size <MiB>	Largest outbound response body (default: %d)
+      --user-agent <ua>	User agent of outbound requests (default: %s)
+      --allow-net <cidrs>	Comma-separated private networks outbound requests may reach
+      --proxy <url>		Proxy for outbound requests (http://, https:// or socks5://)
+      --no-proxy <hosts>	Comma-separated hosts, domains or CIDRs reached without the proxy
+      --favicon-gc-interval <d>	Remove unreferenced favicons every <d>, 0 disables (default: %s)
+      --favicon-backoff <d>	Wait <d> before retrying a failed favicon, doubled each time (default: %s)
+      --favicon-workers <n>	Concurrent favicon fetches (default: %d)
   -v, --verbose		Increase verbosity (-v, -vv, -vvv)
   -V, --version		Show version
   -h, --help		Show this help
-`, a.Cfg.String(), a.Cfg.Info.Title, a.Cfg.Name, a.Flags.Path, a.Flags.Addr)
+`, a.Cfg.String(), a.Cfg.Info.Title, a.Cfg.Name, a.Flags.Path, a.Flags.Addr, a.Server.RepoIdleTimeout,
+		a.Server.CheckInterval, a.Server.CheckWorkers, a.Server.CheckHostDelay,
+		a.Server.ArchiveNew, a.Server.ArchiveQuotaMB, a.Server.WaybackURL,
+		a.Server.FetchTimeout, a.Server.FetchMaxSizeMB, a.Server.UserAgent,
+		a.Server.FaviconGC, a.Server.FaviconBackoff, a.Server.FaviconWorkers)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/mateconpizza/gm/pkg/files"
 	gap "github.com/muesli/go-app-paths"
@@ -27,10 +28,27 @@ type (
 
 	// Server holds configuration specific to the web server.
 	Server struct {
-		QRImgSize    int    // QR image size
-		ItemsPerPage int    // ItemsPerPage
-		CertFile     string // Certificate file path for HTTPS
-		KeyFile      string // Key file path for HTTPS
+		QRImgSize       int           // QR image size
+		ItemsPerPage    int           // ItemsPerPage
+		CertFile        string        // Certificate file path for HTTPS
+		KeyFile         string        // Key file path for HTTPS
+		RepoIdleTimeout time.Duration // Time an unused repository connection stays open
+		ReadOnlyRepos   []string      // Repositories served in read-only mode
+		CheckInterval   time.Duration // Time between link checks, 0 disables them
+		CheckWorkers    int           // Concurrent link checks per repository
+		CheckHostDelay  time.Duration // Minimum time between requests to the same host
+		ArchiveNew      bool          // Archive the new bookmarks automatically
+		ArchiveQuotaMB  int           // Default space for the page snapshots of a repository
+		WaybackURL      string        // Base URL of the Wayback Machine, empty disables it
+		FetchTimeout    time.Duration // Timeout of an outbound request
+		FetchMaxSizeMB  int           // Maximum size of an outbound response body
+		UserAgent       string        // User agent of the outbound requests
+		AllowNets       []string      // Private networks the outbound requests may reach
+		Proxy           string        // Proxy of the outbound requests, http, https or socks5
+		NoProxy         []string      // Hosts reached without the proxy
+		FaviconGC       time.Duration // Time between removals of unreferenced favicons, 0 disables them
+		FaviconBackoff  time.Duration // Delay before retrying a failed favicon, doubled on each failure
+		FaviconWorkers  int           // Number of favicons fetched at the same time
 	}
 
 	// Flags holds command-line interface flags.
@@ -89,6 +107,23 @@ func (a *App) Parse() error {
 	flag.StringVarP(&a.Flags.Path, "path", "p", a.Cfg.DataDir, "")
 	flag.StringVarP(&a.Flags.Addr, "addr", "a", a.Flags.Addr, "")
 	flag.BoolVarP(&a.Flags.DevMode, "dev", "d", false, "")
+	flag.DurationVar(&a.Server.RepoIdleTimeout, "idle-timeout", a.Server.RepoIdleTimeout, "")
+	flag.StringSliceVar(&a.Server.ReadOnlyRepos, "read-only", nil, "")
+	flag.DurationVar(&a.Server.CheckInterval, "check-interval", a.Server.CheckInterval, "")
+	flag.IntVar(&a.Server.CheckWorkers, "check-workers", a.Server.CheckWorkers, "")
+	flag.DurationVar(&a.Server.CheckHostDelay, "check-host-delay", a.Server.CheckHostDelay, "")
+	flag.BoolVar(&a.Server.ArchiveNew, "archive-new", a.Server.ArchiveNew, "")
+	flag.IntVar(&a.Server.ArchiveQuotaMB, "archive-quota", a.Server.ArchiveQuotaMB, "")
+	flag.StringVar(&a.Server.WaybackURL, "wayback-url", a.Server.WaybackURL, "")
+	flag.DurationVar(&a.Server.FetchTimeout, "fetch-timeout", a.Server.FetchTimeout, "")
+	flag.IntVar(&a.Server.FetchMaxSizeMB, "fetch-max-size", a.Server.FetchMaxSizeMB, "")
+	flag.StringVar(&a.Server.UserAgent, "user-agent", a.Server.UserAgent, "")
+	flag.StringSliceVar(&a.Server.AllowNets, "allow-net", nil, "")
+	flag.StringVar(&a.Server.Proxy, "proxy", "", "")
+	flag.StringSliceVar(&a.Server.NoProxy, "no-proxy", nil, "")
+	flag.DurationVar(&a.Server.FaviconGC, "favicon-gc-interval", a.Server.FaviconGC, "")
+	flag.DurationVar(&a.Server.FaviconBackoff, "favicon-backoff", a.Server.FaviconBackoff, "")
+	flag.IntVar(&a.Server.FaviconWorkers, "favicon-workers", a.Server.FaviconWorkers, "")
 	flag.CountVarP(&a.Flags.Verbose, "verbose", "v", "Increase verbosity (-v, -vv, -vvv)")
 	flag.BoolVarP(&a.Flags.Version, "version", "V", false, "")
 	flag.BoolVarP(&a.Flags.Help, "help", "h", false, "")
@@ -0,0 +1,404 @@
+// Package archive saves self-contained copies of the bookmarked pages in a
+// content-addressed store, one per repository.
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/fetch"
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/preview"
+	"github.com/mateconpizza/gmweb/internal/reader"
+)
+
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultConcurrency = 2
+	DefaultQuota       = 512 << 20 // bytes per repository
+	MaxPageSize        = 10 << 20  // bytes of the fetched HTML
+	MaxAssetSize       = 5 << 20   // bytes of a single inlined asset
+	MaxSnapshotSize    = 32 << 20  // bytes of the resulting snapshot
+	MaxAssets          = 200
+)
+
+var (
+	ErrDisabled      = errors.New("archiving disabled")
+	ErrQuotaExceeded = errors.New("archive quota exceeded")
+	ErrTooLarge      = errors.New("page too large to archive")
+	ErrNotHTML       = errors.New("page is not HTML")
+)
+
+// Loader returns the repository with the given name. It is closed once
+// used, which releases it to the registry.
+type Loader func(name string) (models.Repo, error)
+
+type OptFn func(*Archiver)
+
+// Archiver captures pages and keeps their content under dir/<repo>/.
+type Archiver struct {
+	dir      string
+	load     Loader
+	client   *http.Client
+	quota    func(repo string) int64
+	logger   *slog.Logger
+	pending  chan struct{}
+	auto     bool
+	previews *preview.Store
+
+	// ctx is cancelled by Close, which waits for the jobs in wg.
+	mu   sync.Mutex
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func WithClient(c *http.Client) OptFn {
+	return func(a *Archiver) {
+		a.client = c
+	}
+}
+
+// WithQuota sets the function returning the storage quota, in bytes, of a
+// repository. A quota of zero or less means unlimited.
+func WithQuota(fn func(repo string) int64) OptFn {
+	return func(a *Archiver) {
+		a.quota = fn
+	}
+}
+
+func WithConcurrency(n int) OptFn {
+	return func(a *Archiver) {
+		a.pending = make(chan struct{}, max(n, 1))
+	}
+}
+
+// WithAutoArchive enables archiving the bookmarks as they are created.
+func WithAutoArchive(enabled bool) OptFn {
+	return func(a *Archiver) {
+		a.auto = enabled
+	}
+}
+
+// WithPreviews keeps thumbnails of the preview image of the pages whose
+// metadata is scraped.
+func WithPreviews(s *preview.Store) OptFn {
+	return func(a *Archiver) {
+		a.previews = s
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFn {
+	return func(a *Archiver) {
+		a.logger = l
+	}
+}
+
+// New creates an archiver storing the snapshots under dir.
+func New(dir string, load Loader, opts ...OptFn) *Archiver {
+	a := &Archiver{
+		dir:     dir,
+		load:    load,
+		client:  &http.Client{Timeout: DefaultTimeout},
+		quota:   func(string) int64 { return DefaultQuota },
+		logger:  slog.Default(),
+		pending: make(chan struct{}, DefaultConcurrency),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.ctx, a.stop = context.WithCancel(context.Background())
+
+	return a
+}
+
+// Archive captures the current page of the bookmark and records the
+// snapshot.
+func (a *Archiver) Archive(ctx context.Context, repoName string, bID int) (*models.Snapshot, error) {
+	ctx = fetch.WithRepo(ctx, repoName)
+
+	repo, err := a.load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	b, err := repo.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := a.capture(ctx, b.URL)
+	if err != nil {
+		return nil, fmt.Errorf("capturing %q: %w", b.URL, err)
+	}
+
+	sum := sha256.Sum256(data)
+	s := &models.Snapshot{
+		BookmarkID: b.ID,
+		Kind:       models.SnapshotPage,
+		Hash:       hex.EncodeToString(sum[:]),
+		URL:        b.URL,
+		Size:       int64(len(data)),
+	}
+
+	stored, err := repo.HasSnapshotContent(ctx, s.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		if err := a.checkQuota(ctx, repoName, repo, s.Size); err != nil {
+			return nil, err
+		}
+		if err := a.put(repoName, s.Hash, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repo.AddSnapshot(ctx, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Extract fetches the page of the bookmark and stores its readable article.
+func (a *Archiver) Extract(ctx context.Context, repoName string, bID int) (*models.Article, error) {
+	ctx = fetch.WithRepo(ctx, repoName)
+
+	repo, err := a.load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	b, err := repo.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, ctype, base, err := a.fetch(ctx, b.URL, MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", b.URL, err)
+	}
+	if !isHTML(ctype) {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, ctype)
+	}
+
+	art, err := reader.Extract(body, base)
+	if err != nil {
+		return nil, fmt.Errorf("extracting %q: %w", b.URL, err)
+	}
+	art.BookmarkID = b.ID
+
+	if err := repo.SaveArticle(ctx, art); err != nil {
+		return nil, err
+	}
+
+	return art, nil
+}
+
+// Metadata scrapes the page of the bookmark and stores its metadata.
+func (a *Archiver) Metadata(ctx context.Context, repoName string, bID int) (*models.Metadata, error) {
+	ctx = fetch.WithRepo(ctx, repoName)
+
+	repo, err := a.load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	b, err := repo.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewScraper(a.client).Scrape(ctx, b.URL)
+	if err != nil {
+		return nil, err
+	}
+	m.BookmarkID = b.ID
+
+	// a page without a usable preview image is not an error.
+	if a.previews != nil && m.Image != "" {
+		key, err := a.previews.Save(ctx, m.Image)
+		if err != nil {
+			a.logger.Warn("archive: preview failed", "repo", repoName, "id", bID, "error", err)
+		}
+		m.Preview = key
+	}
+
+	if err := repo.SaveMetadata(ctx, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Enqueue archives the bookmark, extracts its article and scrapes its
+// metadata in the background.
+// The request is dropped when too many snapshots are already being taken
+// or the archiver is closed.
+func (a *Archiver) Enqueue(repoName string, bID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.ctx.Err() != nil {
+		return
+	}
+
+	select {
+	case a.pending <- struct{}{}:
+	default:
+		a.logger.Warn("archive: queue full, skipping", "repo", repoName, "id", bID)
+		return
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() { <-a.pending }()
+
+		ctx, cancel := context.WithTimeout(a.ctx, 2*DefaultTimeout)
+		defer cancel()
+
+		if _, err := a.Archive(ctx, repoName, bID); err != nil {
+			a.logger.Warn("archive: snapshot failed", "repo", repoName, "id", bID, "error", err)
+		} else {
+			a.logger.Info("archive: snapshot saved", "repo", repoName, "id", bID)
+		}
+
+		if _, err := a.Extract(ctx, repoName, bID); err != nil {
+			a.logger.Warn("archive: article extraction failed", "repo", repoName, "id", bID, "error", err)
+		}
+
+		if _, err := a.Metadata(ctx, repoName, bID); err != nil {
+			a.logger.Warn("archive: metadata scrape failed", "repo", repoName, "id", bID, "error", err)
+		}
+	}()
+}
+
+// Close cancels the jobs in progress and waits for them to return.
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	a.stop()
+	a.mu.Unlock()
+	a.wg.Wait()
+
+	return nil
+}
+
+// ArchiveNew archives a new bookmark in the background when automatic
+// archiving is enabled.
+func (a *Archiver) ArchiveNew(repoName string, bID int) {
+	if a.auto {
+		a.Enqueue(repoName, bID)
+	}
+}
+
+// Open returns the content of the snapshot.
+func (a *Archiver) Open(repoName string, s *models.Snapshot) (io.ReadCloser, error) {
+	p, err := a.path(repoName, s.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(p)
+}
+
+// Delete removes the snapshot and, when no longer referenced, its content.
+func (a *Archiver) Delete(ctx context.Context, repoName string, bID, sID int) error {
+	repo, err := a.load(repoName)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	s, err := repo.SnapshotByID(ctx, bID, sID)
+	if err != nil {
+		return err
+	}
+
+	orphan, err := repo.DeleteSnapshot(ctx, bID, sID)
+	if err != nil || !orphan {
+		return err
+	}
+
+	p, err := a.path(repoName, s.Hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Quota returns the storage quota of the repository in bytes.
+func (a *Archiver) Quota(repoName string) int64 {
+	return a.quota(repoName)
+}
+
+func (a *Archiver) checkQuota(ctx context.Context, repoName string, repo models.Repo, size int64) error {
+	quota := a.quota(repoName)
+	if quota <= 0 {
+		return nil
+	}
+
+	used, err := repo.ArchiveSize(ctx)
+	if err != nil {
+		return err
+	}
+
+	if used+size > quota {
+		return fmt.Errorf("%w: %d of %d bytes used, snapshot needs %d", ErrQuotaExceeded, used, quota, size)
+	}
+
+	return nil
+}
+
+// put writes the content under its hash, unless it is already stored.
+func (a *Archiver) put(repoName, hash string, data []byte) error {
+	p, err := a.path(repoName, hash)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+
+	if err := files.MkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, files.FilePerm); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	return os.Rename(tmp, p)
+}
+
+// path returns the location of the content, fanned out by the first two
+// characters of the hash.
+func (a *Archiver) path(repoName, hash string) (string, error) {
+	if !filepath.IsLocal(repoName) || len(hash) < 3 || !filepath.IsLocal(hash) {
+		return "", fmt.Errorf("invalid snapshot location %q/%q", repoName, hash)
+	}
+
+	return filepath.Join(a.dir, repoName, hash[:2], hash+".html"), nil
+}
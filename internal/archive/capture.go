@@ -0,0 +1,247 @@
+package archive
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	reScript     = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>`)
+	reTag        = regexp.MustCompile(`(?s)<[a-zA-Z][^>]*>`)
+	reEventAttr  = regexp.MustCompile(`(?i)\s+on[a-z]+\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]+)`)
+	reLink       = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	reImg        = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	reStyleBlock = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style\s*>)`)
+	reCSSURL     = regexp.MustCompile(`(?i)url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+	reHead       = regexp.MustCompile(`(?i)<head\b[^>]*>`)
+	reBase       = regexp.MustCompile(`(?i)<base\b`)
+)
+
+// capture fetches the page and returns it with its stylesheets and images
+// inlined. Scripts and event handlers are removed.
+func (a *Archiver) capture(ctx context.Context, rawURL string) ([]byte, error) {
+	body, ctype, base, err := a.fetch(ctx, rawURL, MaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if !isHTML(ctype) {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, ctype)
+	}
+
+	c := &capturer{a: a, ctx: ctx, seen: make(map[string]string)}
+	page := string(body)
+
+	page = reScript.ReplaceAllString(page, "")
+	page = reTag.ReplaceAllStringFunc(page, func(tag string) string {
+		return reEventAttr.ReplaceAllString(tag, "")
+	})
+
+	page = reLink.ReplaceAllStringFunc(page, func(tag string) string {
+		rel, _ := attr(tag, "rel")
+		href, ok := attr(tag, "href")
+		if !ok || !strings.Contains(strings.ToLower(rel), "stylesheet") {
+			return tag
+		}
+
+		u, err := base.Parse(href)
+		if err != nil {
+			return tag
+		}
+
+		css, _, _, err := a.fetch(ctx, u.String(), MaxAssetSize)
+		if err != nil || !c.take(len(css)) {
+			return tag
+		}
+
+		open := "<style>"
+		if media, ok := attr(tag, "media"); ok {
+			open = `<style media="` + html.EscapeString(media) + `">`
+		}
+
+		return open + c.inlineCSS(string(css), u) + "</style>"
+	})
+
+	page = reStyleBlock.ReplaceAllStringFunc(page, func(block string) string {
+		m := reStyleBlock.FindStringSubmatch(block)
+		return m[1] + c.inlineCSS(m[2], base) + m[3]
+	})
+
+	page = reImg.ReplaceAllStringFunc(page, func(tag string) string {
+		src, ok := attr(tag, "src")
+		if lazy, ok2 := attr(tag, "data-src"); ok2 && (!ok || strings.HasPrefix(src, "data:")) {
+			src, ok = lazy, true
+		}
+		if !ok || strings.HasPrefix(src, "data:") {
+			return tag
+		}
+
+		tag = removeAttr(tag, "srcset")
+		if data, ok := c.dataURI(base, src); ok {
+			return setAttr(tag, "src", data)
+		}
+
+		return tag
+	})
+
+	if !reBase.MatchString(page) {
+		baseTag := `<base href="` + html.EscapeString(base.String()) + `">`
+		if loc := reHead.FindStringIndex(page); loc != nil {
+			page = page[:loc[1]] + baseTag + page[loc[1]:]
+		} else {
+			page = baseTag + page
+		}
+	}
+
+	if len(page) > MaxSnapshotSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, len(page))
+	}
+
+	return []byte(page), nil
+}
+
+func isHTML(ctype string) bool {
+	return strings.HasPrefix(ctype, "text/html") || strings.HasPrefix(ctype, "application/xhtml")
+}
+
+func (a *Archiver) fetch(ctx context.Context, rawURL string, limit int64) ([]byte, string, *url.URL, error) {
+	return fetchPage(ctx, a.client, rawURL, limit)
+}
+
+// fetchPage returns the body, media type and final URL of the resource,
+// reading at most limit bytes.
+func fetchPage(ctx context.Context, client *http.Client, rawURL string, limit int64) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, "", nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, "", nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
+	}
+
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ctype == "" {
+		ctype, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	return body, ctype, resp.Request.URL, nil
+}
+
+// capturer tracks the assets inlined in a single page.
+type capturer struct {
+	a      *Archiver
+	ctx    context.Context
+	seen   map[string]string // asset URL to data URI
+	assets int
+	size   int
+}
+
+// take reserves room for an asset of n bytes.
+func (c *capturer) take(n int) bool {
+	if c.assets >= MaxAssets || c.size+n > MaxSnapshotSize {
+		return false
+	}
+	c.assets++
+	c.size += n
+
+	return true
+}
+
+// dataURI fetches ref, relative to base, and returns it as a data URI.
+func (c *capturer) dataURI(base *url.URL, ref string) (string, bool) {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+
+	key := u.String()
+	if d, ok := c.seen[key]; ok {
+		return d, d != ""
+	}
+
+	body, ctype, _, err := c.a.fetch(c.ctx, key, MaxAssetSize)
+	if err != nil || !c.take(len(body)) {
+		c.seen[key] = ""
+		return "", false
+	}
+
+	d := "data:" + ctype + ";base64," + base64.StdEncoding.EncodeToString(body)
+	c.seen[key] = d
+
+	return d, true
+}
+
+// inlineCSS replaces the url() references of the stylesheet with data URIs.
+func (c *capturer) inlineCSS(css string, base *url.URL) string {
+	return reCSSURL.ReplaceAllStringFunc(css, func(m string) string {
+		ref := reCSSURL.FindStringSubmatch(m)[2]
+		if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		if d, ok := c.dataURI(base, ref); ok {
+			return `url("` + d + `")`
+		}
+
+		return m
+	})
+}
+
+func attrRegexp(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\s` + regexp.QuoteMeta(name) + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+}
+
+// attr returns the unescaped value of the attribute of an HTML tag.
+func attr(tag, name string) (string, bool) {
+	m := attrRegexp(name).FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+
+	return html.UnescapeString(m[1] + m[2] + m[3]), true
+}
+
+// setAttr sets the attribute of an HTML tag, adding it when missing.
+func setAttr(tag, name, value string) string {
+	kv := " " + name + `="` + html.EscapeString(value) + `"`
+	re := attrRegexp(name)
+	if re.MatchString(tag) {
+		done := false
+		return re.ReplaceAllStringFunc(tag, func(string) string {
+			if done {
+				return ""
+			}
+			done = true
+			return kv
+		})
+	}
+
+	end := strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "/")
+	return end + kv + tag[len(end):]
+}
+
+// removeAttr removes the attribute from an HTML tag.
+func removeAttr(tag, name string) string {
+	return attrRegexp(name).ReplaceAllString(tag, "")
+}
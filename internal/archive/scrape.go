@@ -0,0 +1,34 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/reader"
+)
+
+// Scraper reads the metadata declared by the pages. Unlike the Archiver it
+// stores nothing, so it is available with archiving disabled.
+type Scraper struct {
+	client *http.Client
+}
+
+// NewScraper creates a scraper fetching the pages with client.
+func NewScraper(client *http.Client) *Scraper {
+	return &Scraper{client: client}
+}
+
+// Scrape fetches the page and returns the metadata it declares.
+func (s *Scraper) Scrape(ctx context.Context, rawURL string) (*models.Metadata, error) {
+	body, ctype, base, err := fetchPage(ctx, s.client, rawURL, MaxPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", rawURL, err)
+	}
+	if !isHTML(ctype) {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, ctype)
+	}
+
+	return reader.Meta(body, base), nil
+}
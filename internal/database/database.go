@@ -1,10 +1,15 @@
+// Package database keeps track of the repositories served by the app and
+// the connections opened to them.
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/mateconpizza/gmweb/internal/models"
 )
@@ -14,75 +19,490 @@ var (
 	ErrDBNotFound   = errors.New("database not found")
 )
 
-var (
-	Valid       = make(map[string]string)
-	connections = make(map[string]models.Repo)
-	mu          sync.RWMutex
+const (
+	// DefaultIdleTimeout is the time an unused connection stays open.
+	DefaultIdleTimeout = 15 * time.Minute
+
+	// pingAfter is the idle time after which a cached handle is pinged
+	// before being reused.
+	pingAfter = 30 * time.Second
 )
 
-// Get returns a reusable connection to the requested base.
-func Get(dbKey string) (models.Repo, error) {
-	mu.RLock()
-	repo, exists := connections[dbKey]
-	mu.RUnlock()
+// Info holds the metadata of a registered repository.
+type Info struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Open     bool      `json:"open"`
+	ReadOnly bool      `json:"read_only"`
+	OpenedAt time.Time `json:"opened_at,omitzero"`
+	LastUsed time.Time `json:"last_used,omitzero"`
+}
+
+type entry struct {
+	path     string
+	handle   *handle
+	openedAt time.Time
+	lastUsed time.Time
+	settings Settings
+	lockedRO bool // read-only set by the configuration
+
+	// openMu serializes the opening and pinging of the repository, so a
+	// slow one does not hold the registry lock.
+	openMu sync.Mutex
+	// gen changes each time the handle is retired, to discard a connection
+	// opened with stale settings.
+	gen int
+}
+
+// handle is an open connection shared by the leases handed out by Get.
+type handle struct {
+	repo    models.Repo
+	refs    int
+	retired bool
+}
+
+// readOnly reports whether the repository must be opened read-only.
+func (e *entry) readOnly() bool {
+	return e.settings.ReadOnly || e.lockedRO
+}
+
+// retire detaches the connection from the entry, so the next use opens a
+// new one. The connection is closed now if unused, otherwise when its last
+// lease is released. The caller must hold the registry lock.
+func (e *entry) retire() {
+	e.gen++
+	h := e.handle
+	if h == nil {
+		return
+	}
+
+	e.handle = nil
+	e.openedAt = time.Time{}
+	h.retired = true
+	if h.refs == 0 {
+		h.repo.Close()
+	}
+}
+
+// lease is the repository returned by Get. Close releases it instead of
+// closing the shared connection.
+type lease struct {
+	models.Repo
+	release func()
+	once    sync.Once
+}
+
+// Close releases the lease. It is safe to call more than once.
+func (l *lease) Close() { l.once.Do(l.release) }
+
+// Registry is a thread-safe set of repositories and their connections.
+//
+// Connections are opened lazily on first use and closed after being idle
+// for longer than the configured timeout. A connection in use is never
+// closed: callers get a lease, and must Close it when done.
+type Registry struct {
+	mu           sync.Mutex
+	entries      map[string]*entry
+	idleTimeout  time.Duration
+	open         OpenFn
+	now          func() time.Time
+	settingsPath string
+}
+
+// OpenFn opens the repository stored at path.
+type OpenFn func(path string, readOnly bool) (models.Repo, error)
 
-	if exists {
-		slog.Debug("database connection already open", "database", dbKey)
-		return repo, nil
+// NewRegistry creates a registry that uses fn to open new connections.
+func NewRegistry(fn OpenFn) *Registry {
+	return &Registry{
+		entries:     make(map[string]*entry),
+		idleTimeout: DefaultIdleTimeout,
+		open:        fn,
+		now:         time.Now,
 	}
+}
 
-	path, ok := Path(dbKey)
+// Get leases a reusable connection to the requested repository. The
+// caller must Close the returned repository when done with it.
+//
+// The connection is opened, or pinged when it was idle, outside the
+// registry lock, so a slow repository only delays its own users.
+func (r *Registry) Get(dbKey string) (models.Repo, error) {
+	r.mu.Lock()
+	e, ok := r.entries[dbKey]
+	r.mu.Unlock()
 	if !ok {
 		return nil, fmt.Errorf("%w: %q", ErrDBNotAllowed, dbKey)
 	}
 
-	dsn := path
-	newDB, err := models.New(dsn)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database %s: %w", dbKey, err)
+	e.openMu.Lock()
+	defer e.openMu.Unlock()
+
+	for {
+		r.mu.Lock()
+		if r.entries[dbKey] != e {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("%w: %q", ErrDBNotAllowed, dbKey)
+		}
+		now := r.now()
+		if h := e.handle; h != nil {
+			h.refs++
+			idle := now.Sub(e.lastUsed)
+			e.lastUsed = now
+			r.mu.Unlock()
+
+			if idle <= pingAfter || r.ping(e, h) {
+				slog.Debug("database connection already open", "database", dbKey)
+				return r.lease(e, h), nil
+			}
+			continue
+		}
+		path, readOnly, gen := e.path, e.readOnly(), e.gen
+		r.mu.Unlock()
+
+		repo, err := r.open(path, readOnly)
+		if err != nil {
+			return nil, fmt.Errorf("error opening database %s: %w", dbKey, err)
+		}
+
+		r.mu.Lock()
+		if r.entries[dbKey] != e || e.gen != gen {
+			// forgotten, or the settings changed while opening.
+			r.mu.Unlock()
+			repo.Close()
+			continue
+		}
+		h := &handle{repo: repo, refs: 1}
+		e.handle = h
+		e.openedAt = now
+		e.lastUsed = now
+		r.mu.Unlock()
+
+		return r.lease(e, h), nil
+	}
+}
+
+// ping checks a connection that was idle, retiring it when broken. It
+// reports whether the connection can be used; if not, the reference taken
+// by the caller is dropped.
+func (r *Registry) ping(e *entry, h *handle) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	err := h.repo.Ping(ctx)
+	cancel()
+	if err == nil {
+		return true
 	}
 
-	mu.Lock()
-	connections[dbKey] = newDB
-	mu.Unlock()
+	slog.Warn("database: dropping broken connection", "database", e.path, "error", err)
+	r.mu.Lock()
+	if e.handle == h {
+		e.retire()
+	}
+	r.mu.Unlock()
+	r.release(e, h)
 
-	return newDB, nil
+	return false
 }
 
-// CloseAll closes all connections.
-func CloseAll() {
-	mu.Lock()
-	defer mu.Unlock()
+// lease wraps a referenced handle in a repository whose Close releases it.
+func (r *Registry) lease(e *entry, h *handle) models.Repo {
+	return &lease{Repo: h.repo, release: func() { r.release(e, h) }}
+}
 
-	slog.Info("database: closing connections")
-	if len(connections) == 0 {
-		slog.Info("database: no connections found")
-		return
+// release drops a reference to the handle, closing its connection when it
+// was retired and this was the last one. The idle time counts from the
+// last release.
+func (r *Registry) release(e *entry, h *handle) {
+	r.mu.Lock()
+	h.refs--
+	if e.handle == h {
+		e.lastUsed = r.now()
+	}
+	closeNow := h.retired && h.refs == 0
+	r.mu.Unlock()
+
+	if closeNow {
+		h.repo.Close()
+	}
+}
+
+// Register adds a repository to the registry.
+//
+// If the key is already registered with a different path, its connection
+// is closed.
+func (r *Registry) Register(dbKey, dbPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[dbKey]; ok {
+		if e.path == dbPath {
+			return
+		}
+		e.retire()
 	}
 
-	for key, db := range connections {
-		db.Close()
-		delete(connections, key)
+	r.entries[dbKey] = &entry{path: dbPath}
+}
+
+// Forget closes the repository connection and removes it from the registry.
+func (r *Registry) Forget(dbKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[dbKey]; ok {
+		e.retire()
+		delete(r.entries, dbKey)
 	}
 }
 
 // IsValid verify if an identifier is allowed.
-func IsValid(dbKey string) bool {
-	_, ok := Valid[dbKey]
+func (r *Registry) IsValid(dbKey string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.entries[dbKey]
 	return ok
 }
 
 // Path returns the database file route according to the identifier.
-func Path(dbKey string) (string, bool) {
-	path, ok := Valid[dbKey]
-	return path, ok
+func (r *Registry) Path(dbKey string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[dbKey]
+	if !ok {
+		return "", false
+	}
+
+	return e.path, true
+}
+
+// Names returns the sorted names of the registered repositories.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.entries))
+	for k := range r.entries {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// List returns the metadata of all registered repositories.
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]Info, 0, len(r.entries))
+	for k, e := range r.entries {
+		infos = append(infos, Info{
+			Name:     k,
+			Path:     e.path,
+			Open:     e.handle != nil,
+			ReadOnly: e.readOnly(),
+			OpenedAt: e.openedAt,
+			LastUsed: e.lastUsed,
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b Info) int {
+		if a.Name < b.Name {
+			return -1
+		}
+		if a.Name > b.Name {
+			return 1
+		}
+		return 0
+	})
+
+	return infos
+}
+
+// SetIdleTimeout sets the time an unused connection stays open. A
+// non-positive value disables idle eviction.
+func (r *Registry) SetIdleTimeout(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idleTimeout = d
+}
+
+// CloseIdle closes the connections unused for longer than the idle timeout
+// and returns how many were closed. A connection with a lease out is kept.
+func (r *Registry) CloseIdle() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.idleTimeout <= 0 {
+		return 0
+	}
+
+	n := 0
+	now := r.now()
+	for k, e := range r.entries {
+		if e.handle == nil || e.handle.refs > 0 || now.Sub(e.lastUsed) < r.idleTimeout {
+			continue
+		}
+
+		slog.Debug("database: closing idle connection", "database", k, "idle", now.Sub(e.lastUsed))
+		e.retire()
+		n++
+	}
+
+	return n
+}
+
+// Watch periodically closes idle connections until ctx is done.
+func (r *Registry) Watch(ctx context.Context) {
+	r.mu.Lock()
+	interval := r.idleTimeout / 2
+	r.mu.Unlock()
+
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.CloseIdle()
+		}
+	}
+}
+
+// CloseAll closes all connections. The ones with a lease out are closed
+// when their last lease is released.
+func (r *Registry) CloseAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slog.Info("database: closing connections")
+	n := 0
+	for _, e := range r.entries {
+		if e.handle != nil {
+			e.retire()
+			n++
+		}
+	}
+
+	if n == 0 {
+		slog.Info("database: no connections found")
+	}
 }
 
-func Register(dbKey, dbPath string) {
-	Valid[dbKey] = dbPath
+// repos is the registry used by the package-level functions.
+var repos = NewRegistry(open)
+
+// open opens the repository at path and applies its pending migrations.
+//
+// Read-only repositories are opened with a read-only DSN and every write
+// is rejected. Their pending migrations are applied first, once, with a
+// writable handle, so their reads find the gmweb tables.
+func open(path string, readOnly bool) (models.Repo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if readOnly {
+		if err := upgrade(ctx, path); err != nil {
+			slog.Warn("database: read-only repository not migrated", "path", path, "error", err)
+		}
+
+		m, err := models.New(readOnlyDSN(path))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := m.CheckSchema(ctx); err != nil {
+			m.Close()
+			return nil, err
+		}
+
+		return models.ReadOnly(m), nil
+	}
+
+	m, err := models.New(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := m.Migrate(ctx)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	slog.Debug("database: schema up to date", "path", path, "version", v)
+
+	// the bookmarks saved by other tools are not indexed yet.
+	if err := m.SyncCanonical(ctx); err != nil {
+		slog.Warn("database: syncing canonical urls", "path", path, "error", err)
+	}
+
+	return m, nil
 }
 
-func Forget(dbKey string) {
-	delete(connections, dbKey)
-	delete(Valid, dbKey)
+// upgrade applies the pending migrations of a repository about to be
+// opened read-only. Only the schema is written, the bookmarks are left
+// untouched.
+func upgrade(ctx context.Context, path string) error {
+	ro, err := models.New(readOnlyDSN(path))
+	if err != nil {
+		return err
+	}
+	v, err := ro.SchemaVersion(ctx)
+	ro.Close()
+	if err != nil || v >= models.SchemaLatest() {
+		return err
+	}
+
+	m, err := models.New(path)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	v, err = m.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("database: read-only repository migrated", "path", path, "version", v)
+
+	return nil
 }
+
+// Get leases a connection to the requested base; Close releases it.
+func Get(dbKey string) (models.Repo, error) { return repos.Get(dbKey) }
+
+// CloseAll closes all connections.
+func CloseAll() { repos.CloseAll() }
+
+// IsValid verify if an identifier is allowed.
+func IsValid(dbKey string) bool { return repos.IsValid(dbKey) }
+
+// Path returns the database file route according to the identifier.
+func Path(dbKey string) (string, bool) { return repos.Path(dbKey) }
+
+// Names returns the sorted names of the registered repositories.
+func Names() []string { return repos.Names() }
+
+// List returns the metadata of all registered repositories.
+func List() []Info { return repos.List() }
+
+// Register adds a repository to the registry.
+func Register(dbKey, dbPath string) { repos.Register(dbKey, dbPath) }
+
+// Forget closes the repository connection and removes it from the registry.
+func Forget(dbKey string) { repos.Forget(dbKey) }
+
+// SetIdleTimeout sets the time an unused connection stays open.
+func SetIdleTimeout(d time.Duration) { repos.SetIdleTimeout(d) }
+
+// Watch periodically closes idle connections until ctx is done.
+func Watch(ctx context.Context) { repos.Watch(ctx) }
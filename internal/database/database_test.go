@@ -0,0 +1,231 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/models/mocks"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time      { return c.t }
+func (c *fakeClock) add(d time.Duration) { c.t = c.t.Add(d) }
+func newFakeClock() *fakeClock           { return &fakeClock{t: time.Unix(0, 0)} }
+func countOpens(n *int) OpenFn {
+	return func(string, bool) (models.Repo, error) {
+		*n++
+		return mocks.New(), nil
+	}
+}
+
+func TestRegistryGet(t *testing.T) {
+	t.Parallel()
+	opened := 0
+	r := NewRegistry(countOpens(&opened))
+	r.Register("main", "/tmp/main.db")
+
+	if _, err := r.Get("other"); !errors.Is(err, ErrDBNotAllowed) {
+		t.Fatalf("expected ErrDBNotAllowed, got %v", err)
+	}
+
+	for range 3 {
+		if _, err := r.Get("main"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if opened != 1 {
+		t.Fatalf("expected 1 open, got %d", opened)
+	}
+
+	info := r.List()
+	if len(info) != 1 || !info[0].Open || info[0].Path != "/tmp/main.db" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestRegistryCloseIdle(t *testing.T) {
+	t.Parallel()
+	opened := 0
+	clock := newFakeClock()
+	r := NewRegistry(countOpens(&opened))
+	r.now = clock.now
+	r.SetIdleTimeout(time.Minute)
+	r.Register("main", "/tmp/main.db")
+
+	repo, err := r.Get("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo.Close()
+
+	clock.add(30 * time.Second)
+	if n := r.CloseIdle(); n != 0 {
+		t.Fatalf("expected no idle connections, got %d", n)
+	}
+
+	clock.add(time.Minute)
+	if n := r.CloseIdle(); n != 1 {
+		t.Fatalf("expected 1 idle connection, got %d", n)
+	}
+
+	// reopened lazily
+	if _, err := r.Get("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opened != 2 {
+		t.Fatalf("expected 2 opens, got %d", opened)
+	}
+}
+
+func TestRegistryDropsBrokenHandle(t *testing.T) {
+	t.Parallel()
+	clock := newFakeClock()
+	broken := mocks.New()
+	broken.Fail = true
+
+	opened := 0
+	r := NewRegistry(func(string, bool) (models.Repo, error) {
+		opened++
+		if opened == 1 {
+			return broken, nil
+		}
+		return mocks.New(), nil
+	})
+	r.now = clock.now
+	r.Register("main", "/tmp/main.db")
+
+	if _, err := r.Get("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.add(pingAfter + time.Second)
+	repo, err := r.Get("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.(*lease).Repo == broken {
+		t.Fatal("expected broken handle to be replaced")
+	}
+}
+
+// closeCounter counts the times the repository is closed.
+type closeCounter struct {
+	models.Repo
+	closed *int
+}
+
+func (c closeCounter) Close() { *c.closed++ }
+
+func TestRegistryLease(t *testing.T) {
+	t.Parallel()
+	closed := 0
+	clock := newFakeClock()
+	r := NewRegistry(func(string, bool) (models.Repo, error) {
+		return closeCounter{Repo: mocks.New(), closed: &closed}, nil
+	})
+	r.now = clock.now
+	r.SetIdleTimeout(time.Minute)
+	r.Register("main", "/tmp/main.db")
+
+	repo, err := r.Get("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock.add(2 * time.Minute)
+	if n := r.CloseIdle(); n != 0 || closed != 0 {
+		t.Fatalf("expected a leased connection kept open, closed %d", closed)
+	}
+	if err := r.UpdateSettings("main", func(s *Settings) { s.BypassProxy = true }); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 0 {
+		t.Fatal("expected the retired connection open until released")
+	}
+
+	repo.Close()
+	repo.Close()
+	if closed != 1 {
+		t.Fatalf("expected the connection closed once on release, got %d", closed)
+	}
+}
+
+func TestRegistryConcurrentAccess(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry(func(string, bool) (models.Repo, error) { return mocks.New(), nil })
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := string(rune('a' + i))
+			r.Register(name, "/tmp/"+name+".db")
+			_, _ = r.Get(name)
+			_ = r.Names()
+			r.CloseIdle()
+			r.Forget(name)
+		}()
+	}
+	wg.Wait()
+
+	if n := len(r.Names()); n != 0 {
+		t.Fatalf("expected empty registry, got %d", n)
+	}
+}
+
+func TestRegistryReadOnly(t *testing.T) {
+	t.Parallel()
+	var modes []bool
+	r := NewRegistry(func(_ string, readOnly bool) (models.Repo, error) {
+		modes = append(modes, readOnly)
+		return mocks.New(), nil
+	})
+	r.Register("main", "/tmp/main.db")
+	r.Register("other", "/tmp/other.db")
+
+	settings := filepath.Join(t.TempDir(), SettingsFile)
+	if err := r.LoadSettings(settings); err != nil {
+		t.Fatalf("loading missing settings: %v", err)
+	}
+
+	if _, err := r.Get("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetReadOnly("main", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get("main"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, true}; !slices.Equal(modes, want) {
+		t.Fatalf("expected opens %v, got %v", want, modes)
+	}
+
+	// settings survive a restart
+	r2 := NewRegistry(countOpens(new(int)))
+	r2.Register("main", "/tmp/main.db")
+	if err := r2.LoadSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.IsReadOnly("main") {
+		t.Fatal("expected main to be read-only after reload")
+	}
+
+	if err := r.LockReadOnly("other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetReadOnly("other", false); !errors.Is(err, ErrReadOnlyByConfig) {
+		t.Fatalf("expected ErrReadOnlyByConfig, got %v", err)
+	}
+	if !r.IsReadOnly("other") {
+		t.Fatal("expected other to stay read-only")
+	}
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/tags"
+	"github.com/mateconpizza/gmweb/internal/urlclean"
+)
+
+// SettingsFile is the name of the file, inside the data directory, holding
+// the per-repository settings.
+const SettingsFile = "repos.json"
+
+// Settings holds the per-repository options.
+type Settings struct {
+	// ReadOnly opens the repository with a read-only DSN and rejects every
+	// write.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// ArchiveQuotaMB limits the space used by the page snapshots. Zero uses
+	// the server default, a negative value removes the limit.
+	ArchiveQuotaMB int `json:"archive_quota_mb,omitempty"`
+
+	// BypassProxy sends the outbound requests made for the repository
+	// direct, for repositories of internal-only pages.
+	BypassProxy bool `json:"bypass_proxy,omitempty"`
+
+	// TagAliases maps tags to the one stored in their place when a
+	// bookmark is saved or imported, such as golang to go.
+	TagAliases map[string]string `json:"tag_aliases,omitempty"`
+
+	// TagRules normalizes the tags written to the repository.
+	TagRules tags.Rules `json:"tag_rules,omitzero"`
+
+	// URLRules cleans the URLs written to the repository.
+	URLRules urlclean.Rules `json:"url_rules,omitzero"`
+}
+
+// Settings returns the settings of the given repository.
+func (r *Registry) Settings(dbKey string) (Settings, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[dbKey]
+	if !ok {
+		return Settings{}, false
+	}
+
+	return e.settings, true
+}
+
+// UpdateSettings applies fn to the repository settings and persists them.
+//
+// The connection is retired so the next use reopens it with the new
+// settings; the leases out keep theirs until released.
+func (r *Registry) UpdateSettings(dbKey string, fn func(*Settings)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[dbKey]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrDBNotFound, dbKey)
+	}
+
+	fn(&e.settings)
+	e.retire()
+
+	return r.saveSettings()
+}
+
+// LoadSettings reads the settings file and applies its content to the
+// registered repositories. Later saves are written to the same file.
+func (r *Registry) LoadSettings(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settingsPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading settings: %w", err)
+	}
+
+	var stored map[string]Settings
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("parsing settings %q: %w", path, err)
+	}
+
+	for k, s := range stored {
+		if e, ok := r.entries[k]; ok {
+			e.settings = s
+		}
+	}
+
+	return nil
+}
+
+// saveSettings writes the settings of all repositories. The caller must
+// hold the lock.
+func (r *Registry) saveSettings() error {
+	if r.settingsPath == "" {
+		return nil
+	}
+
+	stored := make(map[string]Settings, len(r.entries))
+	for k, e := range r.entries {
+		stored[k] = e.settings
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := r.settingsPath + ".tmp"
+	if err := os.WriteFile(tmp, data, files.FilePerm); err != nil {
+		return fmt.Errorf("writing settings: %w", err)
+	}
+
+	return os.Rename(tmp, filepath.Clean(r.settingsPath))
+}
+
+// ErrReadOnlyByConfig is returned when trying to make writable a repository
+// marked as read-only in the configuration.
+var ErrReadOnlyByConfig = errors.New("repository is read-only by configuration")
+
+// BypassesProxy reports whether the outbound requests of the repository skip
+// the configured proxy.
+func BypassesProxy(dbKey string) bool {
+	s, ok := GetSettings(dbKey)
+	return ok && s.BypassProxy
+}
+
+// TagAliases returns the tag alias rules of the repository.
+func TagAliases(dbKey string) map[string]string {
+	s, _ := GetSettings(dbKey)
+	return s.TagAliases
+}
+
+// TagNormalizer returns the pipeline the tags written to the repository go
+// through, built from its tag rules and aliases.
+func TagNormalizer(dbKey string) *tags.Normalizer {
+	s, _ := GetSettings(dbKey)
+	return &tags.Normalizer{Rules: s.TagRules, Aliases: tags.Aliases(s.TagAliases)}
+}
+
+// URLRules returns the rules cleaning the URLs written to the repository.
+func URLRules(dbKey string) urlclean.Rules {
+	s, _ := GetSettings(dbKey)
+	return s.URLRules
+}
+
+// IsReadOnly reports whether the repository is marked as read-only, either
+// by its settings or by the configuration.
+func (r *Registry) IsReadOnly(dbKey string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[dbKey]
+	return ok && (e.settings.ReadOnly || e.lockedRO)
+}
+
+// SetReadOnly changes the persisted read-only setting of the repository.
+func (r *Registry) SetReadOnly(dbKey string, readOnly bool) error {
+	r.mu.Lock()
+	e, ok := r.entries[dbKey]
+	locked := ok && e.lockedRO
+	r.mu.Unlock()
+
+	if locked && !readOnly {
+		return fmt.Errorf("%w: %q", ErrReadOnlyByConfig, dbKey)
+	}
+
+	return r.UpdateSettings(dbKey, func(s *Settings) { s.ReadOnly = readOnly })
+}
+
+// LockReadOnly marks the repository as read-only for the lifetime of the
+// process, regardless of its persisted settings.
+func (r *Registry) LockReadOnly(dbKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[dbKey]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrDBNotFound, dbKey)
+	}
+
+	e.lockedRO = true
+	e.retire()
+
+	return nil
+}
+
+// readOnlyDSN returns a DSN that opens the SQLite file in read-only mode.
+func readOnlyDSN(path string) string {
+	return "file:" + filepath.ToSlash(path) + "?mode=ro"
+}
+
+// GetSettings returns the settings of the given repository.
+func GetSettings(dbKey string) (Settings, bool) { return repos.Settings(dbKey) }
+
+// UpdateSettings applies fn to the repository settings and persists them.
+func UpdateSettings(dbKey string, fn func(*Settings)) error { return repos.UpdateSettings(dbKey, fn) }
+
+// LoadSettings reads the settings file and applies it to the registered
+// repositories.
+func LoadSettings(path string) error { return repos.LoadSettings(path) }
+
+// IsReadOnly reports whether the repository is marked as read-only.
+func IsReadOnly(dbKey string) bool { return repos.IsReadOnly(dbKey) }
+
+// SetReadOnly changes the persisted read-only setting of the repository.
+func SetReadOnly(dbKey string, readOnly bool) error { return repos.SetReadOnly(dbKey, readOnly) }
+
+// LockReadOnly marks the repository as read-only for the process lifetime.
+func LockReadOnly(dbKey string) error { return repos.LockReadOnly(dbKey) }
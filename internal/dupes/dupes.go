@@ -0,0 +1,234 @@
+// Package dupes finds the bookmarks saved more than once, under URLs of the
+// same page or with nearly the same title, and merges them into one.
+package dupes
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
+	"github.com/mateconpizza/gmweb/internal/tags"
+	"github.com/mateconpizza/gmweb/internal/urlclean"
+)
+
+// Reasons for grouping bookmarks.
+const (
+	ReasonURL   = "url"
+	ReasonTitle = "title"
+)
+
+// Titles are similar when they share this share of their words, and have
+// at least minTitleWords of them; short titles such as "Home" are too
+// common to tell anything.
+const (
+	titleSimilarity = 0.8
+	minTitleWords   = 3
+)
+
+// Group is a set of bookmarks that look like the same page. Key is their
+// canonical URL, or the title of the first one.
+type Group struct {
+	Reason    string               `json:"reason"`
+	Key       string               `json:"key"`
+	Bookmarks []*bookmark.Bookmark `json:"bookmarks"`
+}
+
+// Find groups the bookmarks sharing a canonical URL, then the remaining
+// ones with similar titles. Each group lists the richest bookmark first.
+func Find(bs []*bookmark.Bookmark) []*Group {
+	var groups []*Group
+	grouped := make(map[int]bool)
+
+	byURL := make(map[string][]*bookmark.Bookmark)
+	var keys []string
+	for _, b := range bs {
+		k := urlclean.Canonical(b.URL)
+		if _, ok := byURL[k]; !ok {
+			keys = append(keys, k)
+		}
+		byURL[k] = append(byURL[k], b)
+	}
+	for _, k := range keys {
+		if g := byURL[k]; len(g) > 1 {
+			groups = append(groups, &Group{Reason: ReasonURL, Key: k, Bookmarks: g})
+			for _, b := range g {
+				grouped[b.ID] = true
+			}
+		}
+	}
+
+	rest := make([]*bookmark.Bookmark, 0, len(bs))
+	for _, b := range bs {
+		if !grouped[b.ID] {
+			rest = append(rest, b)
+		}
+	}
+	groups = append(groups, byTitle(rest)...)
+
+	for _, g := range groups {
+		slices.SortStableFunc(g.Bookmarks, func(a, b *bookmark.Bookmark) int {
+			return cmp.Compare(richness(b), richness(a))
+		})
+	}
+
+	return groups
+}
+
+// byTitle groups the bookmarks with similar titles. Only the bookmarks
+// sharing a word are compared.
+func byTitle(bs []*bookmark.Bookmark) []*Group {
+	words := make([]map[string]bool, len(bs))
+	index := make(map[string][]int)
+	for i, b := range bs {
+		words[i] = titleWords(b.Title)
+		if len(words[i]) < minTitleWords {
+			continue
+		}
+		for w := range words[i] {
+			index[w] = append(index[w], i)
+		}
+	}
+
+	// union-find over the similar pairs.
+	parent := make([]int, len(bs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range bs {
+		if len(words[i]) < minTitleWords {
+			continue
+		}
+		seen := make(map[int]bool)
+		for w := range words[i] {
+			for _, j := range index[w] {
+				if j <= i || seen[j] {
+					continue
+				}
+				seen[j] = true
+				if similarity(words[i], words[j]) >= titleSimilarity {
+					parent[root(j)] = root(i)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]*bookmark.Bookmark)
+	var roots []int
+	for i, b := range bs {
+		r := root(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], b)
+	}
+
+	var groups []*Group
+	for _, r := range roots {
+		if g := members[r]; len(g) > 1 {
+			groups = append(groups, &Group{Reason: ReasonTitle, Key: g[0].Title, Bookmarks: g})
+		}
+	}
+
+	return groups
+}
+
+// titleWords returns the lowercase words of the title.
+func titleWords(title string) map[string]bool {
+	words := make(map[string]bool)
+	for w := range strings.FieldsFuncSeq(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[w] = true
+	}
+
+	return words
+}
+
+// similarity is the share of words two titles have in common.
+func similarity(a, b map[string]bool) float64 {
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// richness scores the metadata of a bookmark, to keep the most complete
+// one when merging.
+func richness(b *bookmark.Bookmark) int {
+	n := len(tags.Parse(b.Tags)) + min(b.VisitCount, 10)
+	for _, s := range []string{b.Title, b.Desc, b.Notes, b.FaviconURL, b.ArchiveURL} {
+		if s != "" {
+			n += 2
+		}
+	}
+	if b.Favorite {
+		n += 2
+	}
+
+	return n
+}
+
+// Richest returns the bookmark with the most complete metadata, the first
+// one on a tie.
+func Richest(bs []*bookmark.Bookmark) *bookmark.Bookmark {
+	var best *bookmark.Bookmark
+	for _, b := range bs {
+		if best == nil || richness(b) > richness(best) {
+			best = b
+		}
+	}
+
+	return best
+}
+
+// Merge folds the others into keep: it takes the longest title and
+// description, every tag and note, the visits and the favorite mark, and
+// fills the fields keep lacks. keep retains its URL and ID.
+func Merge(keep *bookmark.Bookmark, others []*bookmark.Bookmark) *bookmark.Bookmark {
+	ts := tags.Parse(keep.Tags)
+	for _, b := range others {
+		if len(b.Title) > len(keep.Title) {
+			keep.Title = b.Title
+		}
+		if len(b.Desc) > len(keep.Desc) {
+			keep.Desc = b.Desc
+		}
+		if b.Notes != "" && !strings.Contains(keep.Notes, b.Notes) {
+			keep.Notes = strings.TrimSpace(keep.Notes + "\n\n" + b.Notes)
+		}
+		ts = append(ts, tags.Parse(b.Tags)...)
+
+		keep.Favorite = keep.Favorite || b.Favorite
+		keep.VisitCount += b.VisitCount
+		if b.LastVisit > keep.LastVisit {
+			keep.LastVisit = b.LastVisit
+		}
+		if b.CreatedAt != "" && (keep.CreatedAt == "" || b.CreatedAt < keep.CreatedAt) {
+			keep.CreatedAt = b.CreatedAt
+		}
+		if keep.FaviconURL == "" {
+			keep.FaviconURL, keep.FaviconLocal = b.FaviconURL, b.FaviconLocal
+		}
+		if keep.ArchiveURL == "" {
+			keep.ArchiveURL, keep.ArchiveTimestamp = b.ArchiveURL, b.ArchiveTimestamp
+		}
+	}
+	keep.Tags = tags.Join(tags.Parse(strings.Join(ts, ",")))
+
+	return keep
+}
@@ -0,0 +1,256 @@
+package favicon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/helpers"
+)
+
+// FailuresFile holds the failed domains. It is kept next to the cache
+// directory so it is not served along with the favicons.
+const FailuresFile = "favicon-failures.json"
+
+// Failure records the failed fetches of a domain.
+type Failure struct {
+	Count   int       `json:"count"`
+	Err     string    `json:"error"`
+	RetryAt time.Time `json:"retry_at"`
+}
+
+// Stats describes the cached favicons of a repository.
+type Stats struct {
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"`
+	Failures int   `json:"failures"` // domains backing off
+}
+
+// collect removes the unreferenced favicons every interval, starting one
+// interval from now, until ctx is done.
+func (c *Cache) collect(ctx context.Context) {
+	if c.interval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		n, err := c.GC(ctx)
+		if err != nil {
+			c.logger.Error("favicon: garbage collection", "error", err)
+		} else if n > 0 {
+			c.logger.Info("favicon: removed unreferenced favicons", "count", n)
+		}
+	}
+}
+
+// GC removes the favicons and failures of the domains no bookmark of any
+// repository points to, returning the number of files removed. Nothing is
+// removed when a repository cannot be read. The downloads in progress and
+// the files written since the collection started are kept, as their
+// bookmarks may not be saved yet.
+func (c *Cache) GC(ctx context.Context) (int, error) {
+	start := time.Now()
+	refs := make(map[string]bool)
+	for _, name := range c.repos() {
+		repo, err := c.load(name)
+		if err != nil {
+			return 0, fmt.Errorf("loading %q: %w", name, err)
+		}
+		bs, err := repo.All(ctx)
+		repo.Close()
+		if err != nil {
+			return 0, fmt.Errorf("reading %q: %w", name, err)
+		}
+		for _, b := range bs {
+			if key, err := helpers.HashDomain(b.URL); err == nil {
+				refs[key] = true
+			}
+			if b.FaviconLocal != "" {
+				refs[fileKey(b.FaviconLocal)] = true
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	var n int
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".tmp") || refs[fileKey(e.Name())] {
+			continue
+		}
+		if info, err := e.Info(); err != nil || info.ModTime().After(start) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	c.mu.Lock()
+	for key := range c.failures {
+		if !refs[key] {
+			delete(c.failures, key)
+		}
+	}
+	c.mu.Unlock()
+	c.saveFailures()
+
+	return n, nil
+}
+
+// Stats returns the favicons cached for the bookmarks of the repository.
+func (c *Cache) Stats(ctx context.Context, repoName string) (*Stats, error) {
+	repo, err := c.load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+	bs, err := repo.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, b := range bs {
+		if key, err := helpers.HashDomain(b.URL); err == nil {
+			keys[key] = true
+		}
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	st := &Stats{}
+	for _, e := range entries {
+		if e.IsDir() || !keys[fileKey(e.Name())] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		st.Files++
+		st.Bytes += info.Size()
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	for key, f := range c.failures {
+		if keys[key] && now.Before(f.RetryAt) {
+			st.Failures++
+		}
+	}
+	c.mu.Unlock()
+
+	return st, nil
+}
+
+// fileKey returns the domain hash of a favicon file name.
+func fileKey(name string) string {
+	name = filepath.Base(name)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// backingOff reports whether the domain failed recently.
+func (c *Cache) backingOff(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.failures[key]
+	return ok && time.Now().Before(f.RetryAt)
+}
+
+// fail records a failed fetch, doubling the delay before the next attempt.
+func (c *Cache) fail(key string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.failures[key]
+	if !ok {
+		f = &Failure{}
+		c.failures[key] = f
+	}
+	f.Count++
+	f.Err = err.Error()
+
+	delay := MaxBackoff
+	if f.Count <= 16 {
+		delay = min(c.backoff<<(f.Count-1), MaxBackoff)
+	}
+	f.RetryAt = time.Now().Add(delay).UTC()
+}
+
+func (c *Cache) succeed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.failures, key)
+}
+
+func (c *Cache) failuresPath() string {
+	return filepath.Join(filepath.Dir(c.dir), FailuresFile)
+}
+
+func (c *Cache) loadFailures() {
+	data, err := os.ReadFile(c.failuresPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("favicon: reading failures", "error", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &c.failures); err != nil {
+		c.logger.Warn("favicon: decoding failures", "error", err)
+		c.failures = make(map[string]*Failure)
+	}
+}
+
+// saveFailures writes the failures file, holding the lock so concurrent
+// saves do not share the temporary file.
+func (c *Cache) saveFailures() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.Marshal(c.failures)
+	if err != nil {
+		c.logger.Error("favicon: encoding failures", "error", err)
+		return
+	}
+
+	p := c.failuresPath()
+	if err := files.MkdirAll(filepath.Dir(p)); err != nil {
+		c.logger.Error("favicon: saving failures", "error", err)
+		return
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, files.FilePerm); err != nil {
+		c.logger.Error("favicon: saving failures", "error", err)
+		return
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		c.logger.Error("favicon: saving failures", "error", err)
+	}
+}
@@ -0,0 +1,508 @@
+// Package favicon keeps the favicons of the bookmarked sites in a cache
+// directory shared by every repository, one file per domain.
+package favicon
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/fetch"
+	"github.com/mateconpizza/gmweb/internal/helpers"
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/reader"
+)
+
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultGCInterval  = 24 * time.Hour
+	DefaultBackoff     = time.Hour // delay after the first failure, doubled after each one
+	DefaultConcurrency = 4         // workers fetching favicons
+	DefaultQueueSize   = 256       // domains waiting for a worker
+	MaxBackoff         = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidURLFormat = errors.New("invalid data URL format")
+	ErrNonOKStatus      = errors.New("non-OK HTTP status")
+	ErrAlreadyRunning   = errors.New("favicon refresh already running")
+)
+
+// Loader returns the repository with the given name. It is closed once
+// used, which releases it to the registry.
+type Loader func(name string) (models.Repo, error)
+
+type OptFn func(*Cache)
+
+// Cache downloads the favicons of the bookmarks into dir and serves them
+// under staticPath. Domains whose favicon cannot be fetched are retried
+// with an increasing delay instead of on every page view.
+type Cache struct {
+	dir        string
+	staticPath string
+	load       Loader
+	repos      func() []string
+	client     *http.Client
+	interval   time.Duration
+	backoff    time.Duration
+	workers    int
+	logger     *slog.Logger
+
+	queue   chan *job
+	wg      sync.WaitGroup
+	stop    context.CancelFunc
+	metrics metrics
+
+	mu       sync.Mutex
+	failures map[string]*Failure // by domain hash
+	running  map[string]bool     // repositories being refreshed
+	pending  map[string]*job     // queued or in progress, by repository and domain
+}
+
+func WithClient(c *http.Client) OptFn {
+	return func(fc *Cache) {
+		fc.client = c
+	}
+}
+
+// WithGCInterval sets the time between two removals of the unreferenced
+// favicons. A non-positive interval disables them.
+func WithGCInterval(d time.Duration) OptFn {
+	return func(fc *Cache) {
+		fc.interval = d
+	}
+}
+
+func WithBackoff(d time.Duration) OptFn {
+	return func(fc *Cache) {
+		fc.backoff = d
+	}
+}
+
+// WithWorkers sets the number of favicons fetched at the same time.
+func WithWorkers(n int) OptFn {
+	return func(fc *Cache) {
+		if n > 0 {
+			fc.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets the number of domains waiting for a worker; the
+// bookmarks of the domains queued beyond it are skipped until the next
+// page view.
+func WithQueueSize(n int) OptFn {
+	return func(fc *Cache) {
+		if n > 0 {
+			fc.queue = make(chan *job, n)
+		}
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFn {
+	return func(fc *Cache) {
+		fc.logger = l
+	}
+}
+
+// New creates a cache under dir for the bookmarks of the repositories
+// returned by repos, restoring the failures recorded by a previous run.
+// Nothing is fetched in the background until Run is called.
+func New(dir, staticPath string, load Loader, repos func() []string, opts ...OptFn) *Cache {
+	c := &Cache{
+		dir:        dir,
+		staticPath: staticPath,
+		load:       load,
+		repos:      repos,
+		client:     &http.Client{Timeout: DefaultTimeout},
+		interval:   DefaultGCInterval,
+		backoff:    DefaultBackoff,
+		workers:    DefaultConcurrency,
+		logger:     slog.Default(),
+		queue:      make(chan *job, DefaultQueueSize),
+		failures:   make(map[string]*Failure),
+		running:    make(map[string]bool),
+		pending:    make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.loadFailures()
+
+	return c
+}
+
+// Refresh discards the cached favicon of the bookmark's domain, along with
+// its failures, and fetches it again.
+func (c *Cache) Refresh(ctx context.Context, repoName string, bID int) (*bookmark.Bookmark, error) {
+	ctx = fetch.WithRepo(ctx, repoName)
+
+	repo, err := c.load(repoName)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	b, err := repo.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.forget(b.URL); err != nil {
+		return nil, err
+	}
+
+	err = c.update(ctx, repo, b, true)
+	c.saveFailures()
+
+	return b, err
+}
+
+// Trigger refreshes the favicons of every bookmark of the repository in the
+// background.
+func (c *Cache) Trigger(ctx context.Context, repoName string) error {
+	if !c.start(repoName) {
+		return fmt.Errorf("%w: %q", ErrAlreadyRunning, repoName)
+	}
+
+	go func() {
+		defer c.finish(repoName)
+		if err := c.refreshRepo(ctx, repoName); err != nil {
+			c.logger.Error("favicon: refreshing repo", "repo", repoName, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// refreshRepo refreshes one bookmark per domain and gives its favicon to
+// the other bookmarks of the domain.
+func (c *Cache) refreshRepo(ctx context.Context, repoName string) error {
+	ctx = fetch.WithRepo(ctx, repoName)
+
+	repo, err := c.load(repoName)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	domains := make(map[string][]*bookmark.Bookmark)
+	for _, b := range bs {
+		key, err := helpers.HashDomain(b.URL)
+		if err != nil {
+			continue
+		}
+		domains[key] = append(domains[key], b)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		pending = make(chan struct{}, c.workers)
+	)
+	for _, group := range domains {
+		if ctx.Err() != nil {
+			break
+		}
+		pending <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-pending; wg.Done() }()
+
+			first := group[0]
+			if err := c.forget(first.URL); err != nil {
+				c.logger.Warn("favicon: discarding", "url", first.URL, "err", err)
+				return
+			}
+			if err := c.update(ctx, repo, first, true); err != nil {
+				c.logger.Warn("favicon fetch failed", "url", first.URL, "err", err)
+			}
+			for _, b := range group[1:] {
+				c.persist(ctx, repo, b, first.FaviconURL, first.FaviconLocal)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.saveFailures()
+	c.logger.Info("favicon: repo refreshed", "repo", repoName, "domains", len(domains))
+
+	return ctx.Err()
+}
+
+// update sets the local favicon of the bookmark, fetching it when the
+// domain has none cached, and saves the bookmark when it changed. The
+// favicon URL is scraped again when missing or when rescrape is set.
+func (c *Cache) update(ctx context.Context, repo models.Repo, b *bookmark.Bookmark, rescrape bool) error {
+	key, err := helpers.HashDomain(b.URL)
+	if err != nil {
+		return err
+	}
+
+	if name := c.cached(key); name != "" {
+		c.persist(ctx, repo, b, b.FaviconURL, c.staticPath+name)
+		return nil
+	}
+	if c.backingOff(key) {
+		return nil
+	}
+
+	faviconURL := b.FaviconURL
+	if faviconURL == "" || rescrape {
+		if faviconURL, err = c.scrape(ctx, b.URL); err != nil {
+			c.fail(key, err)
+			c.persist(ctx, repo, b, b.FaviconURL, "")
+			return err
+		}
+	}
+
+	local, err := c.download(ctx, key, faviconURL)
+	if err != nil {
+		// the declared icon may be an SVG or WebP, which cannot be decoded;
+		// most sites still serve a classic icon at the root.
+		if root := rootIcon(b.URL); root != "" && root != faviconURL {
+			if l, rerr := c.download(ctx, key, root); rerr == nil {
+				local, err = l, nil
+			}
+		}
+	}
+	if err != nil {
+		c.fail(key, err)
+		c.persist(ctx, repo, b, faviconURL, "")
+		return err
+	}
+	c.succeed(key)
+
+	c.persist(ctx, repo, b, faviconURL, c.staticPath+filepath.Base(local))
+
+	return nil
+}
+
+// rootIcon returns the /favicon.ico URL of the page's origin.
+func rootIcon(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}).String()
+}
+
+// persist saves the favicon fields of the bookmark when they changed.
+func (c *Cache) persist(ctx context.Context, repo models.Repo, b *bookmark.Bookmark, faviconURL, local string) {
+	if b.FaviconURL == faviconURL && b.FaviconLocal == local {
+		return
+	}
+	b.FaviconURL, b.FaviconLocal = faviconURL, local
+
+	if err := repo.SetFavicon(ctx, b.ID, faviconURL, local); err != nil {
+		c.logger.Error("db update failed", "url", b.URL, "err", err)
+	}
+}
+
+// path returns the file of a local favicon URL.
+func (c *Cache) path(local string) string {
+	return filepath.Join(c.dir, filepath.Base(local))
+}
+
+// cached returns the name of the file cached for the domain hash, if any.
+// Icons kept in another format by older versions are fetched again.
+func (c *Cache) cached(key string) string {
+	name := key + ".png"
+	if files.SizeBytes(filepath.Join(c.dir, name)) > 0 {
+		return name
+	}
+
+	return ""
+}
+
+// forget removes the cached favicon and the failures of the URL's domain.
+func (c *Cache) forget(rawURL string) error {
+	key, err := helpers.HashDomain(rawURL)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.failures, key)
+	c.mu.Unlock()
+
+	matches, _ := filepath.Glob(filepath.Join(c.dir, key+".*"))
+	for _, m := range matches {
+		if err := os.Remove(m); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) start(repoName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running[repoName] {
+		return false
+	}
+	c.running[repoName] = true
+
+	return true
+}
+
+func (c *Cache) finish(repoName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.running, repoName)
+}
+
+// download fetches the favicon, normalises it and stores it locally,
+// returning its path.
+func (c *Cache) download(ctx context.Context, hashDomain, faviconURL string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.HasPrefix(faviconURL, "data:") {
+		data, err = decodeDataURL(faviconURL)
+	} else {
+		data, err = c.fetch(ctx, faviconURL)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	icon, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+
+	return c.write(hashDomain, icon)
+}
+
+// decodeDataURL returns the payload of a data: URL.
+func decodeDataURL(dataURL string) ([]byte, error) {
+	// data:[<mediatype>][;base64],<data>
+	header, data, ok := strings.Cut(dataURL, ",")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidURLFormat, helpers.ShortStr(dataURL, 40))
+	}
+
+	if strings.HasSuffix(header, ";base64") {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 data: %w", err)
+		}
+		return b, nil
+	}
+
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode URL data: %w", err)
+	}
+
+	return []byte(decoded), nil
+}
+
+// fetch downloads the favicon, up to MaxIconSize bytes.
+func (c *Cache) fetch(ctx context.Context, faviconURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, faviconURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req)
+
+	r, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			slog.Error("closing request body", "error", err)
+		}
+	}()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s: %w", r.StatusCode, r.Status, ErrNonOKStatus)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxIconSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxIconSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, MaxIconSize)
+	}
+
+	return data, nil
+}
+
+// write stores the normalised favicon of the domain and removes the files
+// left by older versions, which kept the icons in their original format.
+func (c *Cache) write(hashDomain string, icon []byte) (string, error) {
+	if err := files.MkdirAll(c.dir); err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(c.dir, hashDomain+".png")
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, icon, files.FilePerm); err != nil {
+		return "", fmt.Errorf("failed to write favicon file: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return "", err
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(c.dir, hashDomain+".*"))
+	for _, m := range matches {
+		if m != p {
+			_ = os.Remove(m)
+		}
+	}
+
+	return p, nil
+}
+
+// setHeaders configures HTTP request headers to mimic a real browser. The
+// user agent is left to the client.
+func setHeaders(r *http.Request) {
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	r.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	r.Header.Set("Connection", "keep-alive")
+	r.Header.Set("Upgrade-Insecure-Requests", "1")
+	r.Header.Set("Sec-Fetch-Dest", "document")
+	r.Header.Set("Sec-Fetch-Mode", "navigate")
+	r.Header.Set("Sec-Fetch-Site", "none")
+}
+
+// scrape extracts the favicon URL from a bookmark's webpage.
+func (c *Cache) scrape(ctx context.Context, rawURL string) (string, error) {
+	resp, err := fetch.Get(ctx, c.client, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return reader.Meta(page, resp.Request.URL).FaviconURL, nil
+}
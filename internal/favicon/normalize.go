@@ -0,0 +1,257 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	_ "image/gif"  // register decoder
+	_ "image/jpeg" // register decoder
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/mateconpizza/gmweb/internal/imgutil"
+)
+
+const (
+	Size         = 64      // width and height of the stored favicons
+	MaxIconSize  = 1 << 20 // bytes of a downloaded favicon
+	MaxPixels    = 16e6    // pixels of a decoded favicon
+	AvatarColors = 8       // colours of the generated avatars, see the avatar-N classes
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported favicon format")
+	ErrTooLarge    = errors.New("favicon too large")
+)
+
+// icoMagic starts every ICO file: reserved zero, then type 1 (icon).
+var icoMagic = []byte{0, 0, 1, 0}
+
+// normalize decodes the favicon, whatever its format, and encodes it as a
+// square PNG of Size pixels.
+func normalize(data []byte) ([]byte, error) {
+	src, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imgutil.Contain(src, Size, Size)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode sniffs the content of the favicon and decodes it, checking its
+// dimensions first so a small file cannot claim a huge canvas.
+func decode(data []byte) (image.Image, error) {
+	if bytes.HasPrefix(data, icoMagic) {
+		return decodeICO(data)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, http.DetectContentType(data))
+	}
+	if err := checkSize(cfg.Width, cfg.Height); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+
+	return img, nil
+}
+
+func checkSize(w, h int) error {
+	if w <= 0 || h <= 0 || float64(w)*float64(h) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooLarge, w, h)
+	}
+
+	return nil
+}
+
+// decodeICO decodes the largest image of an ICO file. Its entries hold
+// either a PNG or a headerless BMP followed by a transparency mask.
+func decodeICO(data []byte) (image.Image, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("%w: truncated ICO header", ErrUnsupported)
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 || len(data) < 6+16*count {
+		return nil, fmt.Errorf("%w: truncated ICO directory", ErrUnsupported)
+	}
+
+	var best []byte
+	bestArea, bestDepth := -1, -1
+	for i := range count {
+		e := data[6+16*i:]
+		w, h := int(e[0]), int(e[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		depth := int(binary.LittleEndian.Uint16(e[6:]))
+		size := int(binary.LittleEndian.Uint32(e[8:]))
+		offset := int(binary.LittleEndian.Uint32(e[12:]))
+		if size <= 0 || offset < 0 || offset > len(data) || size > len(data)-offset {
+			continue
+		}
+		if w*h > bestArea || (w*h == bestArea && depth > bestDepth) {
+			best, bestArea, bestDepth = data[offset:offset+size], w*h, depth
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: no valid ICO entry", ErrUnsupported)
+	}
+
+	if bytes.HasPrefix(best, []byte("\x89PNG")) {
+		return decode(best)
+	}
+
+	return decodeDIB(best)
+}
+
+// decodeDIB decodes the uncompressed bitmap of an ICO entry. Its height
+// covers both the colour pixels and the 1-bit mask that follows them, and
+// rows are stored bottom-up.
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("%w: truncated bitmap header", ErrUnsupported)
+	}
+	le := binary.LittleEndian
+	headerSize := int(le.Uint32(data))
+	w := int(int32(le.Uint32(data[4:])))
+	h := int(int32(le.Uint32(data[8:]))) / 2
+	depth := int(le.Uint16(data[14:]))
+	compression := le.Uint32(data[16:])
+	colorsUsed := int(le.Uint32(data[32:]))
+
+	if compression != 0 && !(compression == 3 && depth == 32) {
+		return nil, fmt.Errorf("%w: compressed bitmap", ErrUnsupported)
+	}
+	if err := checkSize(w, h); err != nil {
+		return nil, err
+	}
+	if headerSize < 40 || headerSize > len(data) {
+		return nil, fmt.Errorf("%w: bad bitmap header", ErrUnsupported)
+	}
+
+	var palette []color.RGBA
+	pos := headerSize
+	switch depth {
+	case 1, 4, 8:
+		n := colorsUsed
+		if n <= 0 || n > 1<<depth {
+			n = 1 << depth
+		}
+		if len(data) < pos+4*n {
+			return nil, fmt.Errorf("%w: truncated palette", ErrUnsupported)
+		}
+		palette = make([]color.RGBA, n)
+		for i := range palette {
+			p := data[pos+4*i:]
+			palette[i] = color.RGBA{R: p[2], G: p[1], B: p[0], A: 0xff}
+		}
+		pos += 4 * n
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("%w: %d-bit bitmap", ErrUnsupported, depth)
+	}
+
+	stride := (w*depth + 31) / 32 * 4
+	maskStride := (w + 31) / 32 * 4
+	if len(data) < pos+stride*h {
+		return nil, fmt.Errorf("%w: truncated bitmap", ErrUnsupported)
+	}
+	pixels := data[pos : pos+stride*h]
+	mask := data[pos+stride*h:]
+	if len(mask) < maskStride*h {
+		mask = nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	hasAlpha := false
+	for y := range h {
+		row := pixels[(h-1-y)*stride:]
+		for x := range w {
+			var c color.NRGBA
+			switch depth {
+			case 32:
+				c = color.NRGBA{R: row[4*x+2], G: row[4*x+1], B: row[4*x], A: row[4*x+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[3*x+2], G: row[3*x+1], B: row[3*x], A: 0xff}
+			default:
+				bit := x * depth
+				idx := int(row[bit/8]>>(8-depth-bit%8)) & (1<<depth - 1)
+				if idx < len(palette) {
+					p := palette[idx]
+					c = color.NRGBA{R: p.R, G: p.G, B: p.B, A: 0xff}
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// the mask only applies when the pixels carry no alpha of their own.
+	if depth == 32 && hasAlpha || mask == nil {
+		return img, nil
+	}
+	for y := range h {
+		row := mask[(h-1-y)*maskStride:]
+		for x := range w {
+			c := img.NRGBAAt(x, y)
+			c.A = 0xff
+			if row[x/8]&(0x80>>(x%8)) != 0 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img, nil
+}
+
+// Avatar is the tile shown in place of a missing favicon: the initial of the
+// domain on one of AvatarColors theme colours, both derived from the domain
+// so a site always gets the same tile.
+type Avatar struct {
+	Letter string
+	Color  int
+}
+
+// NewAvatar returns the avatar of the URL's domain.
+func NewAvatar(rawURL string) Avatar {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+
+	a := Avatar{Letter: "?"}
+	for _, r := range host {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			a.Letter = strings.ToUpper(string(r))
+			break
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	a.Color = int(h.Sum32() % AvatarColors)
+
+	return a
+}
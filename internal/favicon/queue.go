@@ -0,0 +1,181 @@
+package favicon
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/fetch"
+	"github.com/mateconpizza/gmweb/internal/helpers"
+)
+
+// job is the work pending for a domain of a repository: the bookmarks
+// waiting for its favicon. Bookmarks enqueued while the job is in progress
+// are added to it and handled by the same worker.
+type job struct {
+	id   string
+	repo string
+	ids  []int
+}
+
+// Metrics describes the work of the favicon workers since the start.
+type Metrics struct {
+	Workers  int    `json:"workers"`
+	Queued   int    `json:"queued"`       // domains waiting for a worker
+	Active   int64  `json:"active"`       // domains being fetched
+	Enqueued uint64 `json:"enqueued"`     // domains queued
+	Merged   uint64 `json:"deduplicated"` // bookmarks added to a pending domain
+	Dropped  uint64 `json:"dropped"`      // domains skipped as the queue was full
+	Done     uint64 `json:"done"`         // bookmarks updated
+	Failed   uint64 `json:"failed"`       // bookmarks whose favicon could not be fetched
+}
+
+type metrics struct {
+	active                                  atomic.Int64
+	enqueued, merged, dropped, done, failed atomic.Uint64
+}
+
+// Run starts the workers and the garbage collection of the cache, and
+// blocks until ctx is done or Close is called.
+func (c *Cache) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.mu.Lock()
+	c.stop = cancel
+	c.wg.Add(c.workers)
+	c.mu.Unlock()
+	for range c.workers {
+		go c.work(ctx)
+	}
+
+	c.collect(ctx)
+	c.wg.Wait()
+	c.saveFailures()
+}
+
+// Close stops the workers, cancelling the fetches in progress, and waits
+// for them to return.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	stop := c.stop
+	c.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	c.wg.Wait()
+
+	return nil
+}
+
+// Enqueue queues the bookmarks missing their local favicon, one job per
+// domain. It never blocks: when the queue is full the bookmarks are left
+// for a later call.
+func (c *Cache) Enqueue(repoName string, bs []*bookmark.Bookmark) {
+	for _, b := range bs {
+		if b.FaviconLocal != "" && files.Exists(c.path(b.FaviconLocal)) {
+			continue
+		}
+		key, err := helpers.HashDomain(b.URL)
+		if err != nil || c.backingOff(key) {
+			continue
+		}
+		c.add(repoName, key, b.ID)
+	}
+}
+
+func (c *Cache) add(repoName, key string, bID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := repoName + "/" + key
+	if j, ok := c.pending[id]; ok {
+		if !slices.Contains(j.ids, bID) {
+			j.ids = append(j.ids, bID)
+			c.metrics.merged.Add(1)
+		}
+		return
+	}
+
+	j := &job{id: id, repo: repoName, ids: []int{bID}}
+	select {
+	case c.queue <- j:
+		c.pending[id] = j
+		c.metrics.enqueued.Add(1)
+	default:
+		c.metrics.dropped.Add(1)
+	}
+}
+
+// Metrics returns the current state of the workers.
+func (c *Cache) Metrics() *Metrics {
+	return &Metrics{
+		Workers:  c.workers,
+		Queued:   len(c.queue),
+		Active:   c.metrics.active.Load(),
+		Enqueued: c.metrics.enqueued.Load(),
+		Merged:   c.metrics.merged.Load(),
+		Dropped:  c.metrics.dropped.Load(),
+		Done:     c.metrics.done.Load(),
+		Failed:   c.metrics.failed.Load(),
+	}
+}
+
+func (c *Cache) work(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-c.queue:
+			c.metrics.active.Add(1)
+			c.process(ctx, j)
+			c.metrics.active.Add(-1)
+		}
+	}
+}
+
+// process updates the bookmarks of the job until none is left. They are
+// read again from the repository, so the favicon is set on their current
+// state rather than on the copy of the page that queued them.
+func (c *Cache) process(ctx context.Context, j *job) {
+	ctx = fetch.WithRepo(ctx, j.repo)
+	defer c.saveFailures()
+
+	repo, err := c.load(j.repo)
+	if err != nil {
+		c.logger.Error("favicon: loading repo", "repo", j.repo, "error", err)
+	} else {
+		defer repo.Close()
+	}
+
+	for {
+		c.mu.Lock()
+		ids := j.ids
+		j.ids = nil
+		if len(ids) == 0 || err != nil || ctx.Err() != nil {
+			delete(c.pending, j.id)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		for _, id := range ids {
+			b, berr := repo.ByID(ctx, id)
+			if berr != nil {
+				continue // removed meanwhile
+			}
+			if err := c.update(ctx, repo, b, false); err != nil {
+				c.metrics.failed.Add(1)
+				c.logger.Warn("favicon fetch failed", "url", b.URL, "err", err)
+				continue
+			}
+			c.metrics.done.Add(1)
+		}
+	}
+}
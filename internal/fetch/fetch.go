@@ -0,0 +1,393 @@
+// Package fetch builds the HTTP client used for every outbound request, so
+// that a bookmarked URL cannot make the server reach its own network.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRedirects = 10
+	DefaultMaxBodySize  = 10 << 20 // bytes of a response body
+	DefaultUserAgent    = "gmweb (+https://github.com/mateconpizza/gmweb)"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("destination address not allowed")
+	ErrTooLarge         = errors.New("response body too large")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrScheme           = errors.New("unsupported URL scheme")
+	ErrProxy            = errors.New("invalid proxy URL")
+)
+
+// proxyPorts are the default ports of the supported proxy schemes.
+var proxyPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080"}
+
+// reserved are the ranges that are not covered by the netip helpers and are
+// never reachable on the public internet.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+type OptFn func(*options)
+
+type options struct {
+	timeout      time.Duration
+	maxRedirects int
+	maxBodySize  int64
+	userAgent    string
+	allow        []netip.Prefix
+	proxy        *url.URL
+	noProxy      []string
+	bypass       func(repo string) bool
+}
+
+func WithTimeout(d time.Duration) OptFn {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+func WithMaxRedirects(n int) OptFn {
+	return func(o *options) {
+		o.maxRedirects = n
+	}
+}
+
+// WithMaxBodySize sets the bytes a response body may hold. Zero or less
+// means unlimited.
+func WithMaxBodySize(n int64) OptFn {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+func WithUserAgent(s string) OptFn {
+	return func(o *options) {
+		if s != "" {
+			o.userAgent = s
+		}
+	}
+}
+
+// WithAllowedNets lets requests reach the given networks even when they are
+// private, loopback or otherwise reserved; useful on a homelab.
+func WithAllowedNets(nets ...netip.Prefix) OptFn {
+	return func(o *options) {
+		o.allow = append(o.allow, nets...)
+	}
+}
+
+// WithProxy sends the requests through the proxy, except those to the hosts
+// matching noProxy: a host name also matches its subdomains, a leading dot
+// matches the subdomains only, and addresses or CIDR ranges match the
+// literal IP hosts. "*" disables the proxy.
+func WithProxy(proxy *url.URL, noProxy ...string) OptFn {
+	return func(o *options) {
+		o.proxy = proxy
+		o.noProxy = append(o.noProxy, noProxy...)
+	}
+}
+
+// WithProxyBypass sets the function reporting whether the requests made for
+// a repository go direct. See WithRepo.
+func WithProxyBypass(fn func(repo string) bool) OptFn {
+	return func(o *options) {
+		o.bypass = fn
+	}
+}
+
+type repoKey struct{}
+
+// WithRepo returns a copy of ctx carrying the name of the repository the
+// requests are made for.
+func WithRepo(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, repoKey{}, name)
+}
+
+func repoFrom(ctx context.Context) string {
+	name, _ := ctx.Value(repoKey{}).(string)
+	return name
+}
+
+// ParseProxy parses an http, https or socks5 proxy URL, adding the default
+// port of its scheme when missing.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProxy, err)
+	}
+
+	port, ok := proxyPorts[u.Scheme]
+	if !ok || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %q, expected http://, https:// or socks5://host[:port]", ErrProxy, raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u, nil
+}
+
+// ParseNets parses a list of CIDR ranges or single addresses.
+func ParseNets(ss []string) ([]netip.Prefix, error) {
+	nets := make([]netip.Prefix, 0, len(ss))
+	for _, s := range ss {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			nets = append(nets, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("allowed network %q: not a CIDR range or address", s)
+		}
+		nets = append(nets, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return nets, nil
+}
+
+// New returns a client that refuses to connect to private, loopback and
+// reserved addresses, limits redirects and response sizes, and identifies
+// itself with the configured user agent.
+func New(opts ...OptFn) *http.Client {
+	o := &options{
+		timeout:      DefaultTimeout,
+		maxRedirects: DefaultMaxRedirects,
+		maxBodySize:  DefaultMaxBodySize,
+		userAgent:    DefaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// the address is checked once resolved, right before connecting, so a
+	// name cannot resolve to a public address first and a private one later.
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return o.check(address)
+		},
+	}
+
+	t, _ := http.DefaultTransport.(*http.Transport)
+	t = t.Clone()
+	t.DialContext = dialer.DialContext
+	t.Proxy = nil
+	if o.proxy != nil {
+		// the proxy itself may well sit on the private network.
+		direct := &net.Dialer{Timeout: dialer.Timeout, KeepAlive: dialer.KeepAlive}
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == o.proxy.Host {
+				return direct.DialContext(ctx, network, addr)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+		t.Proxy = o.proxyFor
+	}
+
+	return &http.Client{
+		Timeout:   o.timeout,
+		Transport: &transport{next: t, opts: o},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= o.maxRedirects {
+				return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, len(via))
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: %q", ErrScheme, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+func (o *options) check(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	if !o.allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+
+	return nil
+}
+
+func (o *options) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range o.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range reserved {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// proxyFor returns the proxy of the request, or nil to go direct. As the
+// proxy connects to the destination, its addresses are checked beforehand.
+func (o *options) proxyFor(req *http.Request) (*url.URL, error) {
+	if o.bypass != nil && o.bypass(repoFrom(req.Context())) {
+		return nil, nil //nolint:nilnil // no proxy for this request
+	}
+
+	host := req.URL.Hostname()
+	if o.skipProxy(host) {
+		return nil, nil //nolint:nilnil // no proxy for this request
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if !o.allowed(a) {
+			return nil, fmt.Errorf("%w: %s", ErrForbiddenAddress, a)
+		}
+	}
+
+	return o.proxy, nil
+}
+
+// skipProxy reports whether the host matches the no-proxy list.
+func (o *options) skipProxy(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	addr, addrErr := netip.ParseAddr(host)
+
+	for _, np := range o.noProxy {
+		np = strings.ToLower(strings.TrimSpace(np))
+		switch {
+		case np == "":
+			continue
+		case np == "*":
+			return true
+		case addrErr == nil:
+			if p, err := netip.ParsePrefix(np); err == nil && p.Contains(addr) {
+				return true
+			}
+			if a, err := netip.ParseAddr(np); err == nil && a == addr {
+				return true
+			}
+		case strings.HasPrefix(np, "."):
+			if strings.HasSuffix(host, np) {
+				return true
+			}
+		case host == np || strings.HasSuffix(host, "."+np):
+			return true
+		}
+	}
+
+	return false
+}
+
+// transport sets the user agent and caps the size of the response bodies.
+type transport struct {
+	next http.RoundTripper
+	opts *options
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %q", ErrScheme, req.URL.Scheme)
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.opts.userAgent)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := t.opts.maxBodySize
+	if limit <= 0 {
+		return resp, nil
+	}
+	if resp.ContentLength > limit {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, resp.ContentLength, limit)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, left: limit}
+
+	return resp, nil
+}
+
+// limitedBody fails once more than left bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n + int(b.left), ErrTooLarge
+	}
+
+	return n, err
+}
+
+// Get requests the URL with the client and returns the response once its
+// status is known to be a success.
+func Get(ctx context.Context, c *http.Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
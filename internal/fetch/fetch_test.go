@@ -0,0 +1,152 @@
+package fetch
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAllowed(t *testing.T) {
+	t.Parallel()
+
+	allow, err := ParseNets([]string{"10.0.0.0/8", "127.0.0.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr  string
+		allow bool // with the allow-list
+		want  bool // without
+	}{
+		{addr: "93.184.216.34", allow: true, want: true},
+		{addr: "2606:4700::1111", allow: true, want: true},
+		{addr: "::ffff:93.184.216.34", allow: true, want: true},
+		{addr: "127.0.0.1", allow: true},
+		{addr: "127.0.0.2"},
+		{addr: "::1"},
+		{addr: "10.1.2.3", allow: true},
+		{addr: "::ffff:10.1.2.3", allow: true},
+		{addr: "::ffff:127.0.0.1", allow: true},
+		{addr: "::ffff:192.168.1.1"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "fd12::1", allow: true},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "224.0.0.1"},
+		{addr: "ff02::1"},
+		{addr: "100.64.0.1"},
+		{addr: "192.0.0.8"},
+		{addr: "192.0.2.1"},
+		{addr: "198.18.0.1"},
+		{addr: "198.51.100.1"},
+		{addr: "203.0.113.1"},
+		{addr: "240.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "2001:db8::1"},
+	}
+
+	open, allowList := &options{}, &options{allow: allow}
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		if got := open.allowed(addr); got != tt.want {
+			t.Errorf("allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+		if got := allowList.allowed(addr); got != tt.allow {
+			t.Errorf("allowed(%s) with the allow-list = %v, want %v", tt.addr, got, tt.allow)
+		}
+	}
+}
+
+func TestSkipProxy(t *testing.T) {
+	t.Parallel()
+
+	o := &options{noProxy: []string{"Example.com", " .internal.lan", "", "10.0.0.0/8", "192.168.1.5", "::1"}}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.COM.", true},
+		{"www.example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.net", false},
+		{"internal.lan", false},
+		{"git.internal.lan", true},
+		{"a.b.internal.lan", true},
+		{"10.2.3.4", true},
+		{"11.2.3.4", false},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"::1", true},
+		{"::2", false},
+		{"golang.org", false},
+	}
+
+	for _, tt := range tests {
+		if got := o.skipProxy(tt.host); got != tt.want {
+			t.Errorf("skipProxy(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	all := &options{noProxy: []string{"example.com", "*"}}
+	for _, host := range []string{"golang.org", "10.2.3.4", "::1"} {
+		if !all.skipProxy(host) {
+			t.Errorf("skipProxy(%q) with * = false, want true", host)
+		}
+	}
+	if (&options{}).skipProxy("example.com") {
+		t.Error("expected the proxy used without a no-proxy list")
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		oneByte bool
+		wantErr error
+	}{
+		{name: "under the limit", body: "1234", limit: 8},
+		{name: "at the limit", body: "12345678", limit: 8},
+		{name: "at the limit by single bytes", body: "12345678", limit: 8, oneByte: true},
+		{name: "one byte over", body: "123456789", limit: 8, wantErr: ErrTooLarge},
+		{name: "one byte over by single bytes", body: "123456789", limit: 8, oneByte: true, wantErr: ErrTooLarge},
+		{name: "far over", body: strings.Repeat("x", 1<<16), limit: 8, wantErr: ErrTooLarge},
+		{name: "empty at a zero limit", body: "", limit: 0},
+		{name: "one byte at a zero limit", body: "x", limit: 0, wantErr: ErrTooLarge},
+	}
+
+	//nolint:paralleltest //test
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(tt.body)
+			if tt.oneByte {
+				r = iotest.OneByteReader(r)
+			}
+
+			got, err := io.ReadAll(&limitedBody{ReadCloser: io.NopCloser(r), left: tt.limit})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			want := tt.body
+			if tt.wantErr != nil {
+				want = tt.body[:tt.limit]
+			}
+			if string(got) != want {
+				t.Errorf("read %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
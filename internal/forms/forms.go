@@ -37,6 +37,7 @@ type AppSettings struct {
 	ThemeName    string `form:"theme"`
 	DarkMode     bool   `form:"dark_mode"`
 	CompactMode  bool   `form:"compact_mode"`
+	GridMode     bool   `form:"grid_mode"`
 	VimMode      bool   `form:"vim_mode"`
 	ItemsPerPage int    `form:"items_per_page"`
 	Validator    `form:"-"`
@@ -15,6 +15,8 @@ import (
 
 	"github.com/mateconpizza/gm/pkg/bookmark"
 	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/tags"
 )
 
 // ShortStr shortens a string to a maximum length.
@@ -160,18 +162,19 @@ func filterByQuery(bookmarks []*bookmark.Bookmark, query string) []*bookmark.Boo
 	return filtered
 }
 
-// filterByTag filters bookmarks by a specific tag.
+// filterByTag filters bookmarks by a specific tag, a parent tag including
+// its children.
 func filterByTag(bookmarks []*bookmark.Bookmark, tag string) []*bookmark.Bookmark {
 	if tag == "" {
 		return bookmarks
 	}
 
 	var filtered []*bookmark.Bookmark
-	normalizedTag := strings.ToLower(strings.TrimPrefix(tag, "#"))
+	normalizedTag := strings.TrimPrefix(tag, "#")
 
 	for _, b := range bookmarks {
-		for t := range strings.SplitSeq(b.Tags, ",") {
-			if strings.EqualFold(strings.TrimSpace(t), normalizedTag) {
+		for _, t := range tags.Parse(b.Tags) {
+			if tags.Matches(t, normalizedTag) {
 				filtered = append(filtered, b)
 				break
 			}
@@ -213,19 +216,6 @@ func filterByLetter(bs []*bookmark.Bookmark, letter string) []*bookmark.Bookmark
 	return f
 }
 
-func GroupTagsByLetter(tags []string) map[string][]string {
-	sort.Strings(tags)
-	grouped := make(map[string][]string)
-	for _, tag := range tags {
-		if tag == "" {
-			continue
-		}
-		first := strings.ToUpper(string(tag[0]))
-		grouped[first] = append(grouped[first], tag)
-	}
-	return grouped
-}
-
 func SortBy(s string, bs []*bookmark.Bookmark) []*bookmark.Bookmark {
 	switch s {
 	case "newest":
@@ -279,13 +269,8 @@ func SortBy(s string, bs []*bookmark.Bookmark) []*bookmark.Bookmark {
 func ExtractTags(bs []*bookmark.Bookmark) []string {
 	tagsMap := map[string]bool{}
 	for _, b := range bs {
-		for t := range strings.SplitSeq(b.Tags, ",") {
-			if t != "" {
-				if !tagsMap[t] {
-					tagsMap[t] = true
-				}
-				continue
-			}
+		for _, t := range tags.Parse(b.Tags) {
+			tagsMap[t] = true
 		}
 	}
 
@@ -312,14 +297,10 @@ func TagsWithPoundList(s string) []string {
 func TagsWithPound(s string) string {
 	var sb strings.Builder
 
-	tagsSplit := strings.Split(s, ",")
-	sort.Strings(tagsSplit)
-
-	for _, t := range tagsSplit {
-		if t == "" {
-			continue
-		}
+	ts := tags.Parse(s)
+	sort.Strings(ts)
 
+	for _, t := range ts {
 		sb.WriteString(t + " ")
 	}
 
@@ -0,0 +1,73 @@
+// Package imgutil scales the images cached by the server, the page previews
+// and the favicons, without any dependency outside the standard library.
+package imgutil
+
+import (
+	"image"
+	"image/color"
+)
+
+// Cover crops the centre of src to the aspect ratio of w×h and scales it to
+// fill the whole area. Images smaller than the area are scaled up.
+func Cover(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	crop := b
+	if b.Dx()*h > b.Dy()*w {
+		cw := b.Dy() * w / h
+		crop.Min.X = b.Min.X + (b.Dx()-cw)/2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		ch := b.Dx() * h / w
+		crop.Min.Y = b.Min.Y + (b.Dy()-ch)/2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	scale(dst, dst.Bounds(), src, crop)
+
+	return dst
+}
+
+// Contain scales src to fit inside w×h keeping its aspect ratio, centred on
+// a transparent background.
+func Contain(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	fw, fh := w, h
+	if b.Dx()*h > b.Dy()*w {
+		fh = max(b.Dy()*w/b.Dx(), 1)
+	} else {
+		fw = max(b.Dx()*h/b.Dy(), 1)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	x, y := (w-fw)/2, (h-fh)/2
+	scale(dst, image.Rect(x, y, x+fw, y+fh), src, b)
+
+	return dst
+}
+
+// scale draws the sr part of src into the r part of dst, averaging the
+// source pixels covered by each destination pixel.
+func scale(dst *image.RGBA, r image.Rectangle, src image.Image, sr image.Rectangle) {
+	w, h := r.Dx(), r.Dy()
+	for y := range h {
+		y0 := sr.Min.Y + y*sr.Dy()/h
+		y1 := max(sr.Min.Y+(y+1)*sr.Dy()/h, y0+1)
+		for x := range w {
+			x0 := sr.Min.X + x*sr.Dx()/w
+			x1 := max(sr.Min.X+(x+1)*sr.Dx()/w, x0+1)
+
+			var cr, cg, cb, ca, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					cr, cg, cb, ca = cr+uint64(pr), cg+uint64(pg), cb+uint64(pb), ca+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(r.Min.X+x, r.Min.Y+y, color.RGBA{
+				R: uint8(cr / n >> 8), G: uint8(cg / n >> 8), B: uint8(cb / n >> 8), A: uint8(ca / n >> 8),
+			})
+		}
+	}
+}
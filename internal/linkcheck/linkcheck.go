@@ -0,0 +1,530 @@
+// Package linkcheck periodically verifies that the bookmarked URLs are still
+// reachable and records their HTTP status.
+package linkcheck
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
+	"github.com/mateconpizza/gmweb/internal/fetch"
+	"github.com/mateconpizza/gmweb/internal/models"
+)
+
+// TimestampLayout is the layout of bookmark.LastStatusChecked.
+const TimestampLayout = "20060102150405"
+
+const (
+	DefaultInterval    = 24 * time.Hour
+	DefaultConcurrency = 8
+	DefaultHostDelay   = 2 * time.Second
+	DefaultTimeout     = 15 * time.Second
+	MaxRedirects       = 10
+)
+
+var (
+	ErrAlreadyRunning = errors.New("link check already running")
+	ErrClosed         = errors.New("link checker closed")
+)
+
+// Loader returns the repository with the given name. It is closed once
+// used, which releases it to the registry.
+type Loader func(name string) (models.Repo, error)
+
+// Status is the outcome of the last check of a repository.
+type Status struct {
+	Repo       string    `json:"repo"`
+	Running    bool      `json:"running"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Total      int       `json:"total"`
+	Checked    int       `json:"checked"`
+	Dead       int       `json:"dead"`
+	Moved      int       `json:"moved"`
+	Errors     int       `json:"errors"`
+	Err        string    `json:"error,omitempty"`
+}
+
+// Result is the outcome of checking a single URL.
+type Result struct {
+	StatusCode int
+	StatusText string
+	Active     bool
+	FinalURL   string       // URL of the last response
+	Redirects  []models.Hop // redirect responses followed, in order
+}
+
+// MovedFrom reports whether the URL checked, url, redirects to a live page
+// elsewhere.
+func (r Result) MovedFrom(url string) bool {
+	return len(r.Redirects) > 0 && r.FinalURL != "" && r.FinalURL != url && r.Active
+}
+
+// Permanent reports whether the URL moved permanently.
+func (r Result) Permanent() bool {
+	return models.IsPermanent(r.Redirects)
+}
+
+type OptFn func(*Checker)
+
+// Checker re-checks every bookmark of the repositories with a pool of
+// workers, waiting between requests to the same host.
+type Checker struct {
+	client      *http.Client
+	load        Loader
+	repos       func() []string
+	interval    time.Duration
+	concurrency int
+	hostDelay   time.Duration
+	logger      *slog.Logger
+	limiter     *hostLimiter
+
+	mu     sync.Mutex
+	status map[string]*Status
+
+	// ctx is cancelled by Close, which waits for the checks in wg.
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func WithClient(c *http.Client) OptFn {
+	return func(ch *Checker) {
+		ch.client = c
+	}
+}
+
+func WithInterval(d time.Duration) OptFn {
+	return func(ch *Checker) {
+		ch.interval = d
+	}
+}
+
+func WithConcurrency(n int) OptFn {
+	return func(ch *Checker) {
+		ch.concurrency = max(n, 1)
+	}
+}
+
+func WithHostDelay(d time.Duration) OptFn {
+	return func(ch *Checker) {
+		ch.hostDelay = d
+	}
+}
+
+func WithLogger(l *slog.Logger) OptFn {
+	return func(ch *Checker) {
+		ch.logger = l
+	}
+}
+
+// New creates a checker for the repositories returned by repos.
+func New(load Loader, repos func() []string, opts ...OptFn) *Checker {
+	c := &Checker{
+		client:      &http.Client{Timeout: DefaultTimeout},
+		load:        load,
+		repos:       repos,
+		interval:    DefaultInterval,
+		concurrency: DefaultConcurrency,
+		hostDelay:   DefaultHostDelay,
+		logger:      slog.Default(),
+		status:      make(map[string]*Status),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.limiter = newHostLimiter(c.hostDelay)
+	c.ctx, c.stop = context.WithCancel(context.Background())
+
+	return c
+}
+
+// Run checks all repositories every interval, starting one interval from
+// now, until ctx is done or the checker is closed. A non-positive interval
+// disables the periodic checks.
+func (c *Checker) Run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+
+	ctx, done, ok := c.track(ctx)
+	if !ok {
+		return
+	}
+	defer done()
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		for _, name := range c.repos() {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := c.CheckRepo(ctx, name); err != nil && !errors.Is(err, ErrAlreadyRunning) {
+				c.logger.Error("linkcheck: checking repo", "repo", name, "error", err)
+			}
+		}
+	}
+}
+
+// Trigger starts checking the repository in the background.
+func (c *Checker) Trigger(ctx context.Context, name string) error {
+	if c.Status(name).Running {
+		return fmt.Errorf("%w: %q", ErrAlreadyRunning, name)
+	}
+
+	ctx, done, ok := c.track(ctx)
+	if !ok {
+		return ErrClosed
+	}
+
+	go func() {
+		defer done()
+		if err := c.CheckRepo(ctx, name); err != nil && !errors.Is(err, ErrAlreadyRunning) {
+			c.logger.Error("linkcheck: checking repo", "repo", name, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close cancels the checks in progress and waits for them to return.
+func (c *Checker) Close() error {
+	c.mu.Lock()
+	c.stop()
+	c.mu.Unlock()
+	c.wg.Wait()
+
+	return nil
+}
+
+// track registers a background check, returning its context, cancelled
+// along with ctx or by Close. done is called once the check returns; ok is
+// false when the checker is closed.
+func (c *Checker) track(ctx context.Context) (_ context.Context, done func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ctx.Err() != nil {
+		return nil, nil, false
+	}
+	c.wg.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.ctx, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+		c.wg.Done()
+	}, true
+}
+
+// Status returns the state of the last check of the repository.
+func (c *Checker) Status(name string) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.status[name]; ok {
+		return *s
+	}
+
+	return Status{Repo: name}
+}
+
+// CheckRepo checks every bookmark of the repository and stores the results.
+func (c *Checker) CheckRepo(ctx context.Context, name string) error {
+	ctx = fetch.WithRepo(ctx, name)
+
+	st, err := c.start(name)
+	if err != nil {
+		return err
+	}
+	defer c.finish(st)
+
+	repo, err := c.load(name)
+	if err != nil {
+		c.setErr(st, err)
+		return err
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(ctx)
+	if err != nil {
+		c.setErr(st, err)
+		return err
+	}
+
+	c.mu.Lock()
+	st.Total = len(bs)
+	c.mu.Unlock()
+
+	jobs := make(chan *bookmark.Bookmark)
+	var wg sync.WaitGroup
+	for range c.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				c.checkOne(ctx, repo, st, b)
+			}
+		}()
+	}
+
+	for _, b := range bs {
+		select {
+		case jobs <- b:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	c.logger.Info("linkcheck: repo checked", "repo", name, "checked", st.Checked, "dead", st.Dead)
+
+	return ctx.Err()
+}
+
+func (c *Checker) checkOne(ctx context.Context, repo models.Repo, st *Status, b *bookmark.Bookmark) {
+	// reload the record so its current URL is checked.
+	cur, err := repo.ByID(ctx, b.ID)
+	if err != nil {
+		c.count(st, Result{}, err)
+		return
+	}
+
+	res, err := c.CheckBookmark(ctx, repo, cur)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if res.MovedFrom(cur.URL) {
+		c.mu.Lock()
+		st.Moved++
+		c.mu.Unlock()
+	}
+	c.count(st, res, err)
+}
+
+// CheckBookmark checks the URL of the bookmark and saves its status, then
+// records its redirect for review, or clears a stale one once the URL
+// resolves to itself. Only the status columns are written, so the changes
+// made to the bookmark meanwhile are kept. A failed check is recorded in
+// the status text, only a failed save is returned.
+func (c *Checker) CheckBookmark(ctx context.Context, repo models.Repo, b *bookmark.Bookmark) (Result, error) {
+	res, checkErr := c.Check(ctx, b.URL)
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	b.HTTPStatusCode = res.StatusCode
+	b.HTTPStatusText = res.StatusText
+	b.IsActive = res.Active
+	b.LastStatusChecked = time.Now().Format(TimestampLayout)
+	if checkErr != nil {
+		c.logger.Debug("linkcheck: checking url", "url", b.URL, "error", checkErr)
+		b.HTTPStatusText = checkErr.Error()
+	}
+
+	if err := repo.SetStatus(ctx, b); err != nil || checkErr != nil {
+		return res, err
+	}
+
+	if !res.MovedFrom(b.URL) {
+		return res, repo.ClearRedirect(ctx, b.ID)
+	}
+
+	return res, repo.SaveRedirect(ctx, &models.Redirect{
+		BookmarkID: b.ID,
+		URL:        b.URL,
+		FinalURL:   res.FinalURL,
+		Chain:      res.Redirects,
+		Permanent:  res.Permanent(),
+		CheckedAt:  time.Now(),
+	})
+}
+
+// Check requests the URL, waiting for its host slot first. A HEAD request
+// is tried first, falling back to GET when the server rejects it.
+func (c *Checker) Check(ctx context.Context, rawURL string) (Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err := c.limiter.wait(ctx, u.Host); err != nil {
+		return Result{}, err
+	}
+
+	res, err := c.do(ctx, http.MethodHead, rawURL)
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed ||
+		res.StatusCode == http.StatusNotImplemented || res.StatusCode == http.StatusForbidden) {
+		res, err = c.do(ctx, http.MethodGet, rawURL)
+	}
+
+	return res, err
+}
+
+func (c *Checker) do(ctx context.Context, method, rawURL string) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, http.NoBody)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// copy the client to record the redirects of this request only.
+	var hops []models.Hop
+	client := *c.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+		}
+		if c.client.CheckRedirect != nil {
+			if err := c.client.CheckRedirect(req, via); err != nil {
+				return err
+			}
+		}
+		hops = append(hops, models.Hop{URL: via[len(via)-1].URL.String(), StatusCode: req.Response.StatusCode})
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return Result{
+		StatusCode: resp.StatusCode,
+		StatusText: http.StatusText(resp.StatusCode),
+		Active:     resp.StatusCode < http.StatusBadRequest,
+		FinalURL:   resp.Request.URL.String(),
+		Redirects:  hops,
+	}, nil
+}
+
+func (c *Checker) start(name string) (*Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.status[name]; ok && s.Running {
+		return nil, fmt.Errorf("%w: %q", ErrAlreadyRunning, name)
+	}
+
+	s := &Status{Repo: name, Running: true, StartedAt: time.Now()}
+	c.status[name] = s
+
+	return s, nil
+}
+
+func (c *Checker) finish(st *Status) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st.Running = false
+	st.FinishedAt = time.Now()
+}
+
+func (c *Checker) setErr(st *Status, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st.Err = err.Error()
+}
+
+func (c *Checker) count(st *Status, res Result, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st.Checked++
+	if !res.Active {
+		st.Dead++
+	}
+	if err != nil {
+		st.Errors++
+		c.logger.Debug("linkcheck: saving status", "repo", st.Repo, "error", err)
+	}
+}
+
+// maxHosts is the number of tracked hosts above which expired entries are
+// pruned.
+const maxHosts = 1024
+
+// hostLimiter spaces out the requests made to the same host.
+type hostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	next  map[string]time.Time
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{delay: delay, next: make(map[string]time.Time)}
+}
+
+// wait blocks until a request to host is allowed or ctx is done.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.delay <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if len(l.next) > maxHosts {
+		for h, t := range l.next {
+			if t.Before(now) {
+				delete(l.next, h)
+			}
+		}
+	}
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.delay)
+	l.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Dead returns the checked bookmarks that are not reachable, grouped by
+// status code.
+func Dead(bs []*bookmark.Bookmark) []*bookmark.Bookmark {
+	dead := make([]*bookmark.Bookmark, 0)
+	for _, b := range bs {
+		if b.LastStatusChecked != "" && !b.IsActive {
+			dead = append(dead, b)
+		}
+	}
+
+	slices.SortStableFunc(dead, func(a, b *bookmark.Bookmark) int {
+		return cmp.Compare(a.HTTPStatusCode, b.HTTPStatusCode)
+	})
+
+	return dead
+}
@@ -199,3 +199,17 @@ func validateDBParam(dbParam string) error {
 
 	return nil
 }
+
+// RequireWritable rejects the request when the repository is read-only.
+func RequireWritable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbParam := r.PathValue("db")
+		if database.IsReadOnly(dbParam) {
+			responder.EncodeErrJSON(w, http.StatusForbidden,
+				fmt.Sprintf("repository %q is read-only", dbParam))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
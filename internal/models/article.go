@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrArticleNotFound is returned when no article was extracted for the
+// bookmark.
+var ErrArticleNotFound = errors.New("article not found")
+
+// Article is the readable content extracted from a bookmarked page.
+type Article struct {
+	BookmarkID  int       `json:"bookmark_id"`
+	Title       string    `json:"title"`
+	Byline      string    `json:"byline"`
+	Published   time.Time `json:"published,omitzero"`
+	LeadImage   string    `json:"lead_image"`
+	Excerpt     string    `json:"excerpt"`
+	Lang        string    `json:"lang"`
+	Content     string    `json:"content"` // sanitised HTML
+	Text        string    `json:"text"`
+	Words       int       `json:"words"`
+	ReadingTime int       `json:"reading_time"` // minutes
+	ExtractedAt time.Time `json:"extracted_at"`
+}
+
+// SaveArticle stores or replaces the article of a bookmark and indexes its
+// text for full-text search.
+func (bm *BookmarkModel) SaveArticle(ctx context.Context, a *Article) error {
+	if a.ExtractedAt.IsZero() {
+		a.ExtractedAt = time.Now().UTC()
+	}
+
+	var published string
+	if !a.Published.IsZero() {
+		published = a.Published.UTC().Format(time.RFC3339)
+	}
+
+	tx, err := bm.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO gmweb_articles (bookmark_id, title, byline, published, lead_image, excerpt,
+			lang, content, text, words, reading_time, extracted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET
+			title = excluded.title, byline = excluded.byline, published = excluded.published,
+			lead_image = excluded.lead_image, excerpt = excluded.excerpt, lang = excluded.lang,
+			content = excluded.content, text = excluded.text, words = excluded.words,
+			reading_time = excluded.reading_time, extracted_at = excluded.extracted_at`,
+		a.BookmarkID, a.Title, a.Byline, published, a.LeadImage, a.Excerpt,
+		a.Lang, a.Content, a.Text, a.Words, a.ReadingTime, a.ExtractedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("saving article: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM gmweb_articles_fts WHERE rowid = ?", a.BookmarkID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO gmweb_articles_fts (rowid, title, text) VALUES (?, ?, ?)",
+		a.BookmarkID, a.Title, a.Text,
+	)
+	if err != nil {
+		return fmt.Errorf("indexing article: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Article returns the article extracted for the bookmark.
+func (bm *BookmarkModel) Article(ctx context.Context, bID int) (*Article, error) {
+	var (
+		a                    Article
+		published, extracted string
+	)
+
+	err := bm.conn.QueryRowContext(ctx, `
+		SELECT bookmark_id, title, byline, published, lead_image, excerpt, lang,
+			content, text, words, reading_time, extracted_at
+		FROM gmweb_articles WHERE bookmark_id = ?`, bID,
+	).Scan(&a.BookmarkID, &a.Title, &a.Byline, &published, &a.LeadImage, &a.Excerpt, &a.Lang,
+		&a.Content, &a.Text, &a.Words, &a.ReadingTime, &extracted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrArticleNotFound, bID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.Published, _ = time.Parse(time.RFC3339, published)
+	a.ExtractedAt, _ = time.Parse(time.RFC3339, extracted)
+
+	return &a, nil
+}
+
+// SearchArticles returns the IDs of the bookmarks whose article contains
+// every word of the query, best matches first.
+func (bm *BookmarkModel) SearchArticles(ctx context.Context, query string) ([]int, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := bm.conn.QueryContext(ctx,
+		"SELECT rowid FROM gmweb_articles_fts WHERE gmweb_articles_fts MATCH ? ORDER BY rank", match)
+	if err != nil {
+		return nil, fmt.Errorf("searching articles: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ftsQuery turns the words of a query into prefix terms, so the user input
+// is never read as FTS syntax.
+func ftsQuery(query string) string {
+	var terms []string
+	for _, w := range strings.Fields(query) {
+		w = strings.ReplaceAll(w, `"`, "")
+		if w != "" {
+			terms = append(terms, `"`+w+`"*`)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
@@ -3,10 +3,15 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/mateconpizza/gm/pkg/bookmark"
 	"github.com/mateconpizza/gm/pkg/db"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
 )
 
 var (
@@ -17,22 +22,61 @@ var (
 
 type BookmarkModel struct {
 	store *db.SQLite
+	conn  *sql.DB // conn is used for the tables owned by gmweb.
 }
 
+// InsertOne inserts the bookmark and indexes its canonical URL.
 func (bm *BookmarkModel) InsertOne(ctx context.Context, b *bookmark.Bookmark) (int64, error) {
-	return bm.store.InsertOne(ctx, b)
+	id, err := bm.store.InsertOne(ctx, b)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, setCanonical(ctx, bm.conn, int(id), b.URL)
 }
 
+// InsertMany inserts the bookmarks and indexes their canonical URLs.
 func (bm *BookmarkModel) InsertMany(ctx context.Context, bs []*bookmark.Bookmark) error {
-	return bm.store.InsertMany(ctx, bs)
+	if err := bm.store.InsertMany(ctx, bs); err != nil {
+		return err
+	}
+
+	urls := make([]string, 0, len(bs))
+	for _, b := range bs {
+		urls = append(urls, b.URL)
+	}
+
+	return bm.indexCanonical(ctx, urls...)
 }
 
+// UpdateOne updates the bookmark and records its previous state as a
+// revision, both in the same transaction.
 func (bm *BookmarkModel) UpdateOne(ctx context.Context, b *bookmark.Bookmark) error {
-	return bm.store.UpdateOne(ctx, b)
+	return bm.withTx(ctx, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, b)
+	})
 }
 
+// UpdateNotes updates the bookmark notes and records the previous state as
+// a revision, both in the same transaction.
 func (bm *BookmarkModel) UpdateNotes(ctx context.Context, bID int, notes string) error {
-	return bm.store.UpdateNotes(ctx, bID, notes)
+	return bm.withTx(ctx, func(tx *sql.Tx) error {
+		prev, err := editableRow(ctx, tx, bID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE bookmarks SET notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", notes, bID)
+		if err != nil {
+			return fmt.Errorf("updating notes: %w", err)
+		}
+
+		next := *prev
+		next.Notes = notes
+
+		return recordRevision(ctx, tx, prev, &next)
+	})
 }
 
 func (bm *BookmarkModel) SetFavorite(ctx context.Context, b *bookmark.Bookmark) error {
@@ -43,14 +87,52 @@ func (bm *BookmarkModel) AddVisit(ctx context.Context, bID int) error {
 	return bm.store.AddVisit(ctx, bID)
 }
 
+// SetFavicon updates only the favicon columns, so a background fetch cannot
+// overwrite the changes made to the bookmark meanwhile. Favicons are not
+// part of the revision history.
+func (bm *BookmarkModel) SetFavicon(ctx context.Context, bID int, faviconURL, faviconLocal string) error {
+	res, err := bm.conn.ExecContext(ctx,
+		"UPDATE bookmarks SET favicon_url = ?, favicon_local = ? WHERE id = ?",
+		faviconURL, faviconLocal, bID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", bookmark.ErrBookmarkNotFound, bID)
+	}
+
+	return nil
+}
+
+// SetStatus updates only the link status columns, so a check running in the
+// background cannot overwrite the changes made to the bookmark meanwhile.
+func (bm *BookmarkModel) SetStatus(ctx context.Context, b *bookmark.Bookmark) error {
+	res, err := bm.conn.ExecContext(ctx, `
+		UPDATE bookmarks SET status_code = ?, status_text = ?, is_active = ?, last_status_checked = ?
+		WHERE id = ?`,
+		b.HTTPStatusCode, b.HTTPStatusText, b.IsActive, b.LastStatusChecked, b.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", bookmark.ErrBookmarkNotFound, b.ID)
+	}
+
+	return nil
+}
+
+// Has returns the bookmark stored under url, or the one that was moved away
+// from it by an accepted redirect.
 func (bm *BookmarkModel) Has(ctx context.Context, url string) (*bookmark.Bookmark, bool) {
 	b, ok := bm.store.Has(ctx, url)
 	if !ok {
-		return nil, ok
+		return bm.byAlias(ctx, url)
 	}
 	return b, ok
 }
 
+// DeleteMany deletes the bookmarks. The rows of the gmweb tables that refer
+// to them are removed by a trigger, in the same transaction.
 func (bm *BookmarkModel) DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error {
 	return bm.store.DeleteMany(ctx, bs)
 }
@@ -70,6 +152,19 @@ func (bm *BookmarkModel) All(ctx context.Context) ([]*bookmark.Bookmark, error)
 
 func (bm *BookmarkModel) Close() {
 	bm.store.Close()
+	_ = bm.conn.Close()
+}
+
+// Ping verifies the database file is still reachable and holds a bookmarks
+// table.
+func (bm *BookmarkModel) Ping(ctx context.Context) error {
+	var n int
+	err := bm.conn.QueryRowContext(ctx, "SELECT 1 FROM bookmarks LIMIT 1").Scan(&n)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return nil
 }
 
 func (bm *BookmarkModel) Name() string {
@@ -88,13 +183,41 @@ func (bm *BookmarkModel) CountFavorites(ctx context.Context) int {
 	return bm.store.CountFavorites(ctx)
 }
 
+// busyTimeout is how long, in milliseconds, a write waits for the lock held
+// by another connection before failing with SQLITE_BUSY.
+const busyTimeout = 5000
+
+// connDSN returns the DSN of the gmweb connection. Its writes wait for the
+// lock held by the gm store or another request instead of failing, and its
+// transactions take the write lock when they begin, so two of them reading
+// first cannot deadlock when both try to write.
+func connDSN(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	params := fmt.Sprintf("_pragma=busy_timeout(%d)", busyTimeout)
+	if !strings.Contains(dsn, "mode=ro") {
+		params += "&_txlock=immediate"
+	}
+
+	return dsn + sep + params
+}
+
 func New(dsn string) (*BookmarkModel, error) {
 	r, err := db.New(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	return &BookmarkModel{store: r}, nil
+	conn, err := sql.Open("sqlite", connDSN(dsn))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &BookmarkModel{store: r, conn: conn}, nil
 }
 
 func Initialize(ctx context.Context, dsn string) (*BookmarkModel, error) {
@@ -107,5 +230,11 @@ func Initialize(ctx context.Context, dsn string) (*BookmarkModel, error) {
 		return nil, err
 	}
 
-	return &BookmarkModel{store: r}, nil
+	conn, err := sql.Open("sqlite", connDSN(dsn))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &BookmarkModel{store: r, conn: conn}, nil
 }
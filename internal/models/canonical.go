@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
+	"github.com/mateconpizza/gmweb/internal/urlclean"
+)
+
+// execer runs a statement on the connection or within a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ByCanonicalURL returns the bookmark saved under a URL of the same page as
+// rawURL, found by the canonical URL index. Entries whose bookmark changed
+// its URL since they were indexed are ignored.
+func (bm *BookmarkModel) ByCanonicalURL(ctx context.Context, rawURL string) (*bookmark.Bookmark, bool) {
+	var bID int
+	err := bm.conn.QueryRowContext(ctx, `
+		SELECT c.bookmark_id FROM gmweb_canonical_urls c
+		JOIN bookmarks b ON b.id = c.bookmark_id AND b.url = c.url
+		WHERE c.canonical = ? LIMIT 1`, urlclean.Canonical(rawURL),
+	).Scan(&bID)
+	if err != nil {
+		return nil, false
+	}
+
+	b, err := bm.store.ByID(ctx, bID)
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// setCanonical indexes the canonical form of the bookmark URL.
+func setCanonical(ctx context.Context, db execer, bID int, url string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO gmweb_canonical_urls (bookmark_id, url, canonical) VALUES (?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET url = excluded.url, canonical = excluded.canonical`,
+		bID, url, urlclean.Canonical(url),
+	)
+	if err != nil {
+		return fmt.Errorf("indexing canonical url: %w", err)
+	}
+
+	return nil
+}
+
+// indexCanonical indexes the bookmarks saved under the given URLs.
+func (bm *BookmarkModel) indexCanonical(ctx context.Context, urls ...string) error {
+	for _, url := range urls {
+		var bID int
+		err := bm.conn.QueryRowContext(ctx, "SELECT id FROM bookmarks WHERE url = ?", url).Scan(&bID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := setCanonical(ctx, bm.conn, bID, url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncCanonical indexes the bookmarks missing from the canonical URL index,
+// or whose URL changed since, such as the ones written by other tools.
+func syncCanonical(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT b.id, b.url FROM bookmarks b
+		LEFT JOIN gmweb_canonical_urls c ON c.bookmark_id = b.id
+		WHERE c.url IS NULL OR c.url != b.url`)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		id  int
+		url string
+	}
+	var stale []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.url); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		stale = append(stale, e)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range stale {
+		if err := setCanonical(ctx, tx, e.id, e.url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncCanonical brings the canonical URL index up to date with the
+// bookmarks written since it was last synced.
+func (bm *BookmarkModel) SyncCanonical(ctx context.Context) error {
+	return bm.withTx(ctx, func(tx *sql.Tx) error {
+		return syncCanonical(ctx, tx)
+	})
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMetadataNotFound is returned when no metadata was saved for the
+// bookmark.
+var ErrMetadataNotFound = errors.New("metadata not found")
+
+// Metadata is what a page says about itself in its markup: OpenGraph and
+// Twitter card tags, JSON-LD, the canonical link and the document language.
+type Metadata struct {
+	BookmarkID int       `json:"bookmark_id,omitempty"`
+	Title      string    `json:"title"`
+	Desc       string    `json:"desc"`
+	Keywords   []string  `json:"keywords"`
+	FaviconURL string    `json:"favicon_url"`
+	Image      string    `json:"image"`
+	SiteName   string    `json:"site_name"`
+	Type       string    `json:"type"` // JSON-LD @type, or og:type
+	Author     string    `json:"author"`
+	Canonical  string    `json:"canonical"`
+	Lang       string    `json:"lang"`
+	Published  time.Time `json:"published,omitzero"`
+	Preview    string    `json:"preview,omitempty"` // key of the cached thumbnails
+	ScrapedAt  time.Time `json:"scraped_at,omitzero"`
+}
+
+// SaveMetadata stores or replaces the metadata of a bookmark.
+func (bm *BookmarkModel) SaveMetadata(ctx context.Context, m *Metadata) error {
+	if m.ScrapedAt.IsZero() {
+		m.ScrapedAt = time.Now().UTC()
+	}
+
+	var published string
+	if !m.Published.IsZero() {
+		published = m.Published.UTC().Format(time.RFC3339)
+	}
+
+	_, err := bm.conn.ExecContext(ctx, `
+		INSERT INTO gmweb_metadata (bookmark_id, canonical, lang, site_name, image, type,
+			author, published, preview, scraped_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET
+			canonical = excluded.canonical, lang = excluded.lang, site_name = excluded.site_name,
+			image = excluded.image, type = excluded.type, author = excluded.author,
+			published = excluded.published, preview = excluded.preview,
+			scraped_at = excluded.scraped_at`,
+		m.BookmarkID, m.Canonical, m.Lang, m.SiteName, m.Image, m.Type,
+		m.Author, published, m.Preview, m.ScrapedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Metadata returns the metadata saved for the bookmark. Title, description,
+// keywords and favicon live on the bookmark itself and are left empty.
+func (bm *BookmarkModel) Metadata(ctx context.Context, bID int) (*Metadata, error) {
+	var (
+		m                  Metadata
+		published, scraped string
+	)
+
+	err := bm.conn.QueryRowContext(ctx, `
+		SELECT bookmark_id, canonical, lang, site_name, image, type, author, published,
+			preview, scraped_at
+		FROM gmweb_metadata WHERE bookmark_id = ?`, bID,
+	).Scan(&m.BookmarkID, &m.Canonical, &m.Lang, &m.SiteName, &m.Image, &m.Type,
+		&m.Author, &published, &m.Preview, &scraped)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrMetadataNotFound, bID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.Published, _ = time.Parse(time.RFC3339, published)
+	m.ScrapedAt, _ = time.Parse(time.RFC3339, scraped)
+
+	return &m, nil
+}
+
+// Previews returns the preview keys of the bookmarks that have one, by
+// bookmark ID.
+func (bm *BookmarkModel) Previews(ctx context.Context) (map[int]string, error) {
+	rows, err := bm.conn.QueryContext(ctx,
+		"SELECT bookmark_id, preview FROM gmweb_metadata WHERE preview != ''")
+	if err != nil {
+		return nil, fmt.Errorf("listing previews: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	previews := make(map[int]string)
+	for rows.Next() {
+		var (
+			id  int
+			key string
+		)
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		previews[id] = key
+	}
+
+	return previews, rows.Err()
+}
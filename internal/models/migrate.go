@@ -0,0 +1,320 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when a database was written by a newer version
+// of the application.
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// migration is an ordered schema change applied to a repository.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations holds the schema changes in the order they must be applied.
+//
+// Append new entries at the end; never edit or reorder applied ones.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "index bookmarks url",
+		up:      execAll("CREATE INDEX IF NOT EXISTS idx_gmweb_bookmarks_url ON bookmarks(url)"),
+	},
+	{
+		version: 2,
+		name:    "bookmark revisions",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_revisions (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				bookmark_id INTEGER NOT NULL,
+				url         TEXT NOT NULL,
+				title       TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				tags        TEXT NOT NULL DEFAULT '',
+				notes       TEXT NOT NULL DEFAULT '',
+				user        TEXT NOT NULL DEFAULT '',
+				created_at  TEXT NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_gmweb_revisions_bookmark ON gmweb_revisions(bookmark_id)",
+		),
+	},
+	{
+		version: 3,
+		name:    "redirects and url aliases",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_redirects (
+				bookmark_id INTEGER PRIMARY KEY,
+				url         TEXT NOT NULL,
+				final_url   TEXT NOT NULL,
+				chain       TEXT NOT NULL DEFAULT '[]',
+				permanent   INTEGER NOT NULL DEFAULT 0,
+				checked_at  TEXT NOT NULL
+			)`, `
+			CREATE TABLE IF NOT EXISTS gmweb_url_aliases (
+				url         TEXT PRIMARY KEY,
+				bookmark_id INTEGER NOT NULL,
+				created_at  TEXT NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_gmweb_url_aliases_bookmark ON gmweb_url_aliases(bookmark_id)",
+		),
+	},
+	{
+		version: 4,
+		name:    "page snapshots",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_snapshots (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				bookmark_id INTEGER NOT NULL,
+				kind        TEXT NOT NULL,
+				hash        TEXT NOT NULL,
+				url         TEXT NOT NULL,
+				size        INTEGER NOT NULL,
+				created_at  TEXT NOT NULL
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_gmweb_snapshots_bookmark ON gmweb_snapshots(bookmark_id)",
+			"CREATE INDEX IF NOT EXISTS idx_gmweb_snapshots_hash ON gmweb_snapshots(hash)",
+		),
+	},
+	{
+		version: 5,
+		name:    "reader articles",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_articles (
+				bookmark_id  INTEGER PRIMARY KEY,
+				title        TEXT NOT NULL DEFAULT '',
+				byline       TEXT NOT NULL DEFAULT '',
+				published    TEXT NOT NULL DEFAULT '',
+				lead_image   TEXT NOT NULL DEFAULT '',
+				excerpt      TEXT NOT NULL DEFAULT '',
+				lang         TEXT NOT NULL DEFAULT '',
+				content      TEXT NOT NULL,
+				text         TEXT NOT NULL,
+				words        INTEGER NOT NULL DEFAULT 0,
+				reading_time INTEGER NOT NULL DEFAULT 0,
+				extracted_at TEXT NOT NULL
+			)`,
+			"CREATE VIRTUAL TABLE IF NOT EXISTS gmweb_articles_fts USING fts5(title, text)",
+		),
+	},
+	{
+		version: 6,
+		name:    "page metadata",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_metadata (
+				bookmark_id INTEGER PRIMARY KEY,
+				canonical   TEXT NOT NULL DEFAULT '',
+				lang        TEXT NOT NULL DEFAULT '',
+				site_name   TEXT NOT NULL DEFAULT '',
+				image       TEXT NOT NULL DEFAULT '',
+				type        TEXT NOT NULL DEFAULT '',
+				author      TEXT NOT NULL DEFAULT '',
+				published   TEXT NOT NULL DEFAULT '',
+				scraped_at  TEXT NOT NULL
+			)`,
+		),
+	},
+	{
+		version: 7,
+		name:    "page previews",
+		up:      execAll("ALTER TABLE gmweb_metadata ADD COLUMN preview TEXT NOT NULL DEFAULT ''"),
+	},
+	{
+		version: 8,
+		name:    "original urls",
+		up: execAll(`
+			CREATE TABLE IF NOT EXISTS gmweb_original_urls (
+				bookmark_id INTEGER PRIMARY KEY,
+				url         TEXT NOT NULL,
+				cleaned_at  TEXT NOT NULL
+			)`,
+		),
+	},
+	{
+		version: 9,
+		name:    "bookmark delete cleanup",
+		up: execAll(`
+			CREATE TRIGGER IF NOT EXISTS gmweb_bookmarks_delete AFTER DELETE ON bookmarks
+			BEGIN
+				DELETE FROM gmweb_revisions WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_redirects WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_url_aliases WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_snapshots WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_articles WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_articles_fts WHERE rowid = OLD.id;
+				DELETE FROM gmweb_metadata WHERE bookmark_id = OLD.id;
+				DELETE FROM gmweb_original_urls WHERE bookmark_id = OLD.id;
+			END`,
+		),
+	},
+	{
+		version: 10,
+		name:    "canonical urls",
+		up: func(ctx context.Context, tx *sql.Tx) error {
+			err := execAll(`
+				CREATE TABLE IF NOT EXISTS gmweb_canonical_urls (
+					bookmark_id INTEGER PRIMARY KEY,
+					url         TEXT NOT NULL,
+					canonical   TEXT NOT NULL
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_gmweb_canonical_urls ON gmweb_canonical_urls(canonical)", `
+				CREATE TRIGGER IF NOT EXISTS gmweb_canonical_urls_delete AFTER DELETE ON bookmarks
+				BEGIN
+					DELETE FROM gmweb_canonical_urls WHERE bookmark_id = OLD.id;
+				END`,
+			)(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			return syncCanonical(ctx, tx)
+		},
+	},
+}
+
+// SchemaLatest returns the highest schema version known by this build.
+func SchemaLatest() int {
+	return migrations[len(migrations)-1].version
+}
+
+// execAll returns a migration step that executes the given statements.
+func execAll(stmts ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, s := range stmts {
+			if _, err := tx.ExecContext(ctx, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (bm *BookmarkModel) ensureSchemaTable(ctx context.Context) error {
+	_, err := bm.conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS gmweb_schema (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)`)
+	return err
+}
+
+// SchemaVersion returns the schema version recorded in the repository.
+func (bm *BookmarkModel) SchemaVersion(ctx context.Context) (int, error) {
+	var exists int
+	err := bm.conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'gmweb_schema'",
+	).Scan(&exists)
+	if err != nil || exists == 0 {
+		return 0, err
+	}
+
+	var v sql.NullInt64
+	if err := bm.conn.QueryRowContext(ctx, "SELECT MAX(version) FROM gmweb_schema").Scan(&v); err != nil {
+		return 0, err
+	}
+
+	return int(v.Int64), nil
+}
+
+// CheckSchema returns an error if the repository schema is newer than the
+// one supported by this build.
+func (bm *BookmarkModel) CheckSchema(ctx context.Context) error {
+	current, err := bm.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	if current > SchemaLatest() {
+		return fmt.Errorf("%w: version %d, supported %d", ErrSchemaTooNew, current, SchemaLatest())
+	}
+
+	return nil
+}
+
+// Migrate applies the pending migrations, each one in its own transaction,
+// and returns the resulting schema version.
+func (bm *BookmarkModel) Migrate(ctx context.Context) (int, error) {
+	if err := bm.CheckSchema(ctx); err != nil {
+		return 0, err
+	}
+
+	if err := bm.ensureSchemaTable(ctx); err != nil {
+		return 0, fmt.Errorf("creating schema table: %w", err)
+	}
+
+	current, err := bm.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := bm.applyMigration(ctx, m); err != nil {
+			return current, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+
+		current = m.version
+	}
+
+	return current, nil
+}
+
+func (bm *BookmarkModel) applyMigration(ctx context.Context, m migration) error {
+	tx, err := bm.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO gmweb_schema (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IntegrityCheck runs SQLite's integrity check and returns its report. A
+// healthy database reports a single "ok" line.
+func (bm *BookmarkModel) IntegrityCheck(ctx context.Context) ([]string, error) {
+	rows, err := bm.conn.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var report []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		report = append(report, line)
+	}
+
+	return report, rows.Err()
+}
+
+// Vacuum rebuilds the database file, reclaiming unused space.
+func (bm *BookmarkModel) Vacuum(ctx context.Context) error {
+	_, err := bm.conn.ExecContext(ctx, "VACUUM")
+	return err
+}
@@ -3,9 +3,14 @@ package mocks
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 
 	"github.com/mateconpizza/gm/pkg/bookmark"
 	"github.com/mateconpizza/gm/pkg/db"
+
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/urlclean"
 )
 
 var ErrMock = errors.New("mock error")
@@ -42,6 +47,13 @@ type Mock struct {
 	Records           []*bookmark.Bookmark
 	TagsCount         map[string]int
 	MockHas           func(url string) (*bookmark.Bookmark, bool)
+	MockUpdateOne     func(ctx context.Context, b *bookmark.Bookmark) error
+	History           []*models.Revision
+	Moved             []*models.Redirect
+	Archived          []*models.Snapshot
+	Articles          map[int]*models.Article
+	Metas             map[int]*models.Metadata
+	Origins           map[int]*models.OriginalURL
 }
 
 func (m *Mock) All(ctx context.Context) ([]*bookmark.Bookmark, error) { return m.Records, nil }
@@ -68,19 +80,113 @@ func (m *Mock) Has(ctx context.Context, url string) (*bookmark.Bookmark, bool) {
 	return nil, false
 }
 
-func (m *Mock) Count(ctx context.Context, table db.Table) int                      { return 5 }
-func (m *Mock) CountFavorites(ctx context.Context) int                             { return 2 }
-func (m *Mock) CountTags(ctx context.Context) (map[string]int, error)              { return m.TagsCount, nil }
-func (m *Mock) Close()                                                             {}
-func (m *Mock) Name() string                                                       { return "mock" }
-func (m *Mock) Fullpath() string                                                   { return "/mock" }
-func (m *Mock) Init(ctx context.Context) error                                     { return nil }
-func (m *Mock) InsertOne(ctx context.Context, b *bookmark.Bookmark) (int64, error) { return 0, nil }
-func (m *Mock) InsertMany(ctx context.Context, bs []*bookmark.Bookmark) error      { return nil }
-func (m *Mock) UpdateNotes(ctx context.Context, bID int, notes string) error       { return nil }
-func (m *Mock) UpdateOne(ctx context.Context, b *bookmark.Bookmark) error          { return nil }
-func (m *Mock) SetFavorite(ctx context.Context, b *bookmark.Bookmark) error        { return nil }
-func (m *Mock) DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error      { return nil }
+func (m *Mock) ByCanonicalURL(ctx context.Context, url string) (*bookmark.Bookmark, bool) {
+	k := urlclean.Canonical(url)
+	for _, b := range m.Records {
+		if urlclean.Canonical(b.URL) == k {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+func (m *Mock) Count(ctx context.Context, table db.Table) int                 { return 5 }
+func (m *Mock) CountFavorites(ctx context.Context) int                        { return 2 }
+func (m *Mock) CountTags(ctx context.Context) (map[string]int, error)         { return m.TagsCount, nil }
+func (m *Mock) Close()                                                        {}
+func (m *Mock) Name() string                                                  { return "mock" }
+func (m *Mock) Fullpath() string                                              { return "/mock" }
+func (m *Mock) Init(ctx context.Context) error                                { return nil }
+func (m *Mock) UpdateNotes(ctx context.Context, bID int, notes string) error  { return nil }
+func (m *Mock) SetFavorite(ctx context.Context, b *bookmark.Bookmark) error   { return nil }
+func (m *Mock) DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error { return nil }
+func (m *Mock) UpdateOne(ctx context.Context, b *bookmark.Bookmark) error {
+	if m.MockUpdateOne != nil {
+		return m.MockUpdateOne(ctx, b)
+	}
+
+	return nil
+}
+
+func (m *Mock) InsertOne(ctx context.Context, b *bookmark.Bookmark) (int64, error) {
+	b.ID = len(m.Records) + 1
+	m.Records = append(m.Records, b)
+
+	return int64(b.ID), nil
+}
+
+func (m *Mock) InsertMany(ctx context.Context, bs []*bookmark.Bookmark) error {
+	for _, b := range bs {
+		if _, err := m.InsertOne(ctx, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Mock) SetFavicon(ctx context.Context, bID int, faviconURL, faviconLocal string) error {
+	b, err := m.ByID(ctx, bID)
+	if err != nil {
+		return err
+	}
+	b.FaviconURL, b.FaviconLocal = faviconURL, faviconLocal
+
+	return nil
+}
+
+func (m *Mock) SetStatus(ctx context.Context, b *bookmark.Bookmark) error {
+	cur, err := m.ByID(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+	cur.HTTPStatusCode, cur.HTTPStatusText = b.HTTPStatusCode, b.HTTPStatusText
+	cur.IsActive, cur.LastStatusChecked = b.IsActive, b.LastStatusChecked
+
+	return nil
+}
+
+// Begin returns a batch writing to the mock, which restores the records on
+// Rollback.
+func (m *Mock) Begin(ctx context.Context) (models.Batch, error) {
+	saved := make([]*bookmark.Bookmark, len(m.Records))
+	for i, b := range m.Records {
+		c := *b
+		saved[i] = &c
+	}
+
+	return &batch{Mock: m, saved: saved}, nil
+}
+
+type batch struct {
+	*Mock
+	saved []*bookmark.Bookmark
+	done  bool
+}
+
+// Merge removes the others from the records.
+func (b *batch) Merge(ctx context.Context, keep *bookmark.Bookmark, others []*bookmark.Bookmark) error {
+	b.Records = slices.DeleteFunc(b.Records, func(r *bookmark.Bookmark) bool {
+		return slices.ContainsFunc(others, func(o *bookmark.Bookmark) bool { return o.ID == r.ID })
+	})
+
+	return nil
+}
+
+func (b *batch) Commit() error {
+	b.done = true
+	return nil
+}
+
+func (b *batch) Rollback() error {
+	if !b.done {
+		b.Records = b.saved
+		b.done = true
+	}
+
+	return nil
+}
+
 func (m *Mock) AddVisit(ctx context.Context, bID int) error {
 	if m.MockSetVisitCount != nil {
 		return m.MockSetVisitCount(ctx, bID)
@@ -91,6 +197,198 @@ func (m *Mock) AddVisit(ctx context.Context, bID int) error {
 	return nil
 }
 
+func (m *Mock) Ping(ctx context.Context) error {
+	if m.Fail {
+		return ErrMock
+	}
+	return nil
+}
+
+func (m *Mock) SchemaVersion(ctx context.Context) (int, error) { return 1, nil }
+func (m *Mock) Vacuum(ctx context.Context) error               { return nil }
+func (m *Mock) IntegrityCheck(ctx context.Context) ([]string, error) {
+	if m.Fail {
+		return nil, ErrMock
+	}
+	return []string{"ok"}, nil
+}
+
+func (m *Mock) Revisions(ctx context.Context, bID int) ([]*models.Revision, error) {
+	var revs []*models.Revision
+	for _, r := range m.History {
+		if r.BookmarkID == bID {
+			revs = append(revs, r)
+		}
+	}
+	return revs, nil
+}
+
+func (m *Mock) RevisionByID(ctx context.Context, bID, revID int) (*models.Revision, error) {
+	for _, r := range m.History {
+		if r.BookmarkID == bID && r.ID == revID {
+			return r, nil
+		}
+	}
+	return nil, models.ErrRevisionNotFound
+}
+
+func (m *Mock) RestoreRevision(ctx context.Context, bID, revID int) (*bookmark.Bookmark, error) {
+	rev, err := m.RevisionByID(ctx, bID, revID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := m.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+	rev.Apply(b)
+	return b, nil
+}
+
+func (m *Mock) Redirects(ctx context.Context) ([]*models.Redirect, error) { return m.Moved, nil }
+
+func (m *Mock) SaveRedirect(ctx context.Context, r *models.Redirect) error {
+	_ = m.ClearRedirect(ctx, r.BookmarkID)
+	m.Moved = append(m.Moved, r)
+	return nil
+}
+
+func (m *Mock) ClearRedirect(ctx context.Context, bID int) error {
+	m.Moved = slices.DeleteFunc(m.Moved, func(r *models.Redirect) bool { return r.BookmarkID == bID })
+	return nil
+}
+
+func (m *Mock) AcceptRedirect(ctx context.Context, bID int) (*bookmark.Bookmark, error) {
+	i := slices.IndexFunc(m.Moved, func(r *models.Redirect) bool { return r.BookmarkID == bID })
+	if i == -1 {
+		return nil, models.ErrRedirectNotFound
+	}
+	b, err := m.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+	b.URL = m.Moved[i].FinalURL
+	return b, m.ClearRedirect(ctx, bID)
+}
+
+func (m *Mock) AddSnapshot(ctx context.Context, s *models.Snapshot) error {
+	s.ID = len(m.Archived) + 1
+	m.Archived = append(m.Archived, s)
+	return nil
+}
+
+func (m *Mock) Snapshots(ctx context.Context, bID int) ([]*models.Snapshot, error) {
+	var ss []*models.Snapshot
+	for _, s := range slices.Backward(m.Archived) {
+		if s.BookmarkID == bID {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
+func (m *Mock) SnapshotByID(ctx context.Context, bID, sID int) (*models.Snapshot, error) {
+	for _, s := range m.Archived {
+		if s.BookmarkID == bID && s.ID == sID {
+			return s, nil
+		}
+	}
+	return nil, models.ErrSnapshotNotFound
+}
+
+func (m *Mock) DeleteSnapshot(ctx context.Context, bID, sID int) (bool, error) {
+	s, err := m.SnapshotByID(ctx, bID, sID)
+	if err != nil {
+		return false, err
+	}
+	m.Archived = slices.DeleteFunc(m.Archived, func(x *models.Snapshot) bool { return x == s })
+	used, _ := m.HasSnapshotContent(ctx, s.Hash)
+	return !used, nil
+}
+
+func (m *Mock) HasSnapshotContent(ctx context.Context, hash string) (bool, error) {
+	return slices.ContainsFunc(m.Archived, func(s *models.Snapshot) bool { return s.Hash == hash }), nil
+}
+
+func (m *Mock) ArchiveSize(ctx context.Context) (int64, error) {
+	var size int64
+	seen := make(map[string]bool)
+	for _, s := range m.Archived {
+		if !seen[s.Hash] {
+			seen[s.Hash] = true
+			size += s.Size
+		}
+	}
+	return size, nil
+}
+
+func (m *Mock) SaveArticle(ctx context.Context, a *models.Article) error {
+	if m.Articles == nil {
+		m.Articles = make(map[int]*models.Article)
+	}
+	m.Articles[a.BookmarkID] = a
+	return nil
+}
+
+func (m *Mock) Article(ctx context.Context, bID int) (*models.Article, error) {
+	if a, ok := m.Articles[bID]; ok {
+		return a, nil
+	}
+	return nil, models.ErrArticleNotFound
+}
+
+func (m *Mock) SaveMetadata(ctx context.Context, md *models.Metadata) error {
+	if m.Metas == nil {
+		m.Metas = make(map[int]*models.Metadata)
+	}
+	m.Metas[md.BookmarkID] = md
+	return nil
+}
+
+func (m *Mock) Metadata(ctx context.Context, bID int) (*models.Metadata, error) {
+	if md, ok := m.Metas[bID]; ok {
+		return md, nil
+	}
+	return nil, models.ErrMetadataNotFound
+}
+
+func (m *Mock) SaveOriginalURL(ctx context.Context, o *models.OriginalURL) error {
+	if m.Origins == nil {
+		m.Origins = make(map[int]*models.OriginalURL)
+	}
+	m.Origins[o.BookmarkID] = o
+	return nil
+}
+
+func (m *Mock) OriginalURL(ctx context.Context, bID int) (*models.OriginalURL, error) {
+	if o, ok := m.Origins[bID]; ok {
+		return o, nil
+	}
+	return nil, models.ErrOriginalURLNotFound
+}
+
+func (m *Mock) Previews(ctx context.Context) (map[int]string, error) {
+	previews := make(map[int]string)
+	for id, md := range m.Metas {
+		if md.Preview != "" {
+			previews[id] = md.Preview
+		}
+	}
+	return previews, nil
+}
+
+func (m *Mock) SearchArticles(ctx context.Context, query string) ([]int, error) {
+	var ids []int
+	for id, a := range m.Articles {
+		text := strings.ToLower(a.Title + " " + a.Text)
+		if query != "" && strings.Contains(text, strings.ToLower(query)) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
 func New() *Mock {
 	return &Mock{}
 }
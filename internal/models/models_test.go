@@ -0,0 +1,301 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// newTestRepo returns a migrated repository in a temporary file.
+func newTestRepo(t *testing.T) *BookmarkModel {
+	t.Helper()
+	ctx := t.Context()
+
+	bm, err := Initialize(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("initializing repository: %v", err)
+	}
+	t.Cleanup(bm.Close)
+
+	if _, err := bm.Migrate(ctx); err != nil {
+		t.Fatalf("migrating repository: %v", err)
+	}
+
+	return bm
+}
+
+// insert saves b, the way gm does, and returns it as read back.
+func insert(t *testing.T, bm *BookmarkModel, b *bookmark.Bookmark) *bookmark.Bookmark {
+	t.Helper()
+	ctx := t.Context()
+
+	b.GenChecksum()
+	id, err := bm.InsertOne(ctx, b)
+	if err != nil {
+		t.Fatalf("inserting %s: %v", b.URL, err)
+	}
+
+	got, err := bm.ByID(ctx, int(id))
+	if err != nil {
+		t.Fatalf("reading #%d: %v", id, err)
+	}
+
+	return got
+}
+
+// commit runs fn in a batch of bm and commits it.
+func commit(t *testing.T, bm *BookmarkModel, fn func(ctx context.Context, tx Batch) error) {
+	t.Helper()
+	ctx := t.Context()
+
+	tx, err := bm.Begin(ctx)
+	if err != nil {
+		t.Fatalf("beginning batch: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(ctx, tx); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing batch: %v", err)
+	}
+}
+
+func TestBatchMerge(t *testing.T) {
+	t.Parallel()
+	bm := newTestRepo(t)
+	ctx := t.Context()
+
+	keep := insert(t, bm, &bookmark.Bookmark{URL: "https://example.com/post", Title: "Post", Tags: "go"})
+	dup := insert(t, bm, &bookmark.Bookmark{URL: "https://www.example.com/post/", Title: "A post", Tags: "blog"})
+
+	dup.Title = "A longer post"
+	if err := bm.UpdateOne(ctx, dup); err != nil {
+		t.Fatalf("updating #%d: %v", dup.ID, err)
+	}
+	if err := bm.AddSnapshot(ctx, &Snapshot{BookmarkID: dup.ID, Kind: SnapshotPage, Hash: "abc", URL: dup.URL}); err != nil {
+		t.Fatalf("adding snapshot: %v", err)
+	}
+	if err := bm.SaveArticle(ctx, &Article{BookmarkID: dup.ID, Title: "Post", Content: "<p>gophers</p>", Text: "gophers"}); err != nil {
+		t.Fatalf("saving article: %v", err)
+	}
+	if err := bm.SaveOriginalURL(ctx, &OriginalURL{BookmarkID: dup.ID, URL: dup.URL + "?utm_source=feed"}); err != nil {
+		t.Fatalf("saving original url: %v", err)
+	}
+
+	keep.Title, keep.Tags, keep.CreatedAt = "A longer post", "blog,go", "2001-02-03T04:05:06Z"
+	keep.GenChecksum()
+	commit(t, bm, func(ctx context.Context, tx Batch) error {
+		if err := tx.UpdateOne(ctx, keep); err != nil {
+			return err
+		}
+		return tx.Merge(ctx, keep, []*bookmark.Bookmark{dup})
+	})
+
+	if _, err := bm.ByID(ctx, dup.ID); !errors.Is(err, bookmark.ErrBookmarkNotFound) {
+		t.Errorf("expected #%d deleted, got %v", dup.ID, err)
+	}
+	got, err := bm.ByID(ctx, keep.ID)
+	if err != nil {
+		t.Fatalf("reading #%d: %v", keep.ID, err)
+	}
+	if got.CreatedAt != keep.CreatedAt || got.Tags != "blog,go" {
+		t.Errorf("expected the merged fields written, got %+v", got)
+	}
+
+	if revs, err := bm.Revisions(ctx, keep.ID); err != nil || len(revs) != 2 {
+		t.Errorf("expected the revisions of both bookmarks, got %d (%v)", len(revs), err)
+	}
+	if snaps, err := bm.Snapshots(ctx, keep.ID); err != nil || len(snaps) != 1 {
+		t.Errorf("expected the snapshot moved, got %d (%v)", len(snaps), err)
+	}
+	if _, err := bm.Article(ctx, keep.ID); err != nil {
+		t.Errorf("expected the article moved, got %v", err)
+	}
+	if ids, err := bm.SearchArticles(ctx, "gophers"); err != nil || len(ids) != 1 || ids[0] != keep.ID {
+		t.Errorf("expected the article indexed under #%d, got %v (%v)", keep.ID, ids, err)
+	}
+	for _, u := range []string{dup.URL, dup.URL + "?utm_source=feed"} {
+		if b, ok := bm.Has(ctx, u); !ok || b.ID != keep.ID {
+			t.Errorf("expected %s to resolve to #%d, got %v", u, keep.ID, b)
+		}
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	bm := newTestRepo(t)
+	ctx := t.Context()
+
+	const writers = 8
+	bs := make([]*bookmark.Bookmark, writers)
+	for i := range bs {
+		bs[i] = insert(t, bm, &bookmark.Bookmark{URL: fmt.Sprintf("https://example.com/%d", i), Tags: "go"})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*3)
+	for i, b := range bs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 10 {
+				b.Title, b.Tags = fmt.Sprintf("title %d", n), fmt.Sprintf("go,tag%d", i)
+				b.GenChecksum()
+				if err := bm.UpdateOne(ctx, b); err != nil {
+					errs <- fmt.Errorf("updating #%d: %w", b.ID, err)
+					return
+				}
+				if err := bm.UpdateNotes(ctx, b.ID, fmt.Sprintf("notes %d", n)); err != nil {
+					errs <- fmt.Errorf("updating notes of #%d: %w", b.ID, err)
+					return
+				}
+				b.HTTPStatusCode = 200 + n
+				if err := bm.SetStatus(ctx, b); err != nil {
+					errs <- fmt.Errorf("setting status of #%d: %w", b.ID, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	tags, err := bm.CountTags(ctx)
+	if err != nil {
+		t.Fatalf("counting tags: %v", err)
+	}
+	if tags["go"] != writers || len(tags) != writers+1 {
+		t.Errorf("expected the tags of every writer, got %v", tags)
+	}
+}
+
+// linkedTags returns the names of the tags linked to url, sorted.
+func linkedTags(t *testing.T, bm *BookmarkModel, url string) []string {
+	t.Helper()
+
+	rows, err := bm.conn.QueryContext(t.Context(), `
+		SELECT t.name FROM tags t JOIN bookmark_tags bt ON bt.tag_id = t.id
+		WHERE bt.bookmark_url = ? ORDER BY t.name`, url)
+	if err != nil {
+		t.Fatalf("reading tag links: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("reading tag links: %v", err)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func TestWritesMatchStore(t *testing.T) {
+	t.Parallel()
+	bm := newTestRepo(t)
+	ctx := t.Context()
+
+	// the rows gm writes are the reference for the ones written here.
+	ref := insert(t, bm, &bookmark.Bookmark{URL: "https://gm.example.com", Title: "Ref", Tags: "db,go"})
+	edited := insert(t, bm, &bookmark.Bookmark{URL: "https://edited.example.com", Title: "Edited", Tags: "go,web"})
+
+	added := &bookmark.Bookmark{URL: "https://added.example.com", Title: "Added", Tags: "db,go", IsActive: true}
+	added.GenChecksum()
+	gone := &bookmark.Bookmark{URL: "https://gone.example.com", Title: "Gone", Tags: "gone,go", IsActive: true}
+	gone.GenChecksum()
+	commit(t, bm, func(ctx context.Context, tx Batch) error {
+		for _, b := range []*bookmark.Bookmark{added, gone} {
+			id, err := tx.InsertOne(ctx, b)
+			if err != nil {
+				return err
+			}
+			b.ID = int(id)
+		}
+		return nil
+	})
+
+	edited.URL, edited.Tags = "https://moved.example.com", "db,go"
+	edited.GenChecksum()
+	if err := bm.UpdateOne(ctx, edited); err != nil {
+		t.Fatalf("updating #%d: %v", edited.ID, err)
+	}
+
+	commit(t, bm, func(ctx context.Context, tx Batch) error {
+		added.Title = "Added and edited"
+		added.GenChecksum()
+		if err := tx.UpdateOne(ctx, added); err != nil {
+			return err
+		}
+		return tx.DeleteMany(ctx, []*bookmark.Bookmark{gone})
+	})
+
+	if err := bm.UpdateNotes(ctx, added.ID, "some notes"); err != nil {
+		t.Fatalf("updating notes of #%d: %v", added.ID, err)
+	}
+
+	want := map[int]*bookmark.Bookmark{ref.ID: ref, edited.ID: edited, added.ID: added}
+	all, err := bm.All(ctx)
+	if err != nil {
+		t.Fatalf("listing bookmarks: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("expected %d bookmarks, got %d", len(want), len(all))
+	}
+	for _, b := range all {
+		w, ok := want[b.ID]
+		if !ok {
+			t.Errorf("unexpected bookmark %+v", b)
+			continue
+		}
+		got, err := bm.ByID(ctx, b.ID)
+		if err != nil {
+			t.Fatalf("reading #%d: %v", b.ID, err)
+		}
+		if got.URL != w.URL || got.Title != w.Title || got.Tags != ref.Tags || got.Checksum != w.Checksum {
+			t.Errorf("#%d: expected %+v, got %+v", b.ID, w, got)
+		}
+		if tags := linkedTags(t, bm, got.URL); !slices.Equal(tags, linkedTags(t, bm, ref.URL)) {
+			t.Errorf("#%d: expected the tag links gm writes, got %v", b.ID, tags)
+		}
+	}
+
+	if got, _ := bm.ByID(ctx, added.ID); got.Notes != "some notes" {
+		t.Errorf("expected the notes written, got %q", got.Notes)
+	}
+	if revs, err := bm.Revisions(ctx, added.ID); err != nil || len(revs) != 2 {
+		t.Errorf("expected a revision per change of #%d, got %d (%v)", added.ID, len(revs), err)
+	}
+	for _, u := range []string{"https://edited.example.com", gone.URL} {
+		if tags := linkedTags(t, bm, u); len(tags) != 0 {
+			t.Errorf("expected no tags linked to %s, got %v", u, tags)
+		}
+	}
+
+	tags, err := bm.CountTags(ctx)
+	if err != nil {
+		t.Fatalf("counting tags: %v", err)
+	}
+	if !maps.Equal(tags, map[string]int{"db": 3, "go": 3}) {
+		t.Errorf("expected every bookmark tagged db,go, got %v", tags)
+	}
+	var n int
+	if err := bm.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM tags").Scan(&n); err != nil || n != 2 {
+		t.Errorf("expected the unused tags pruned, got %d tags (%v)", n, err)
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOriginalURLNotFound is returned when the URL of a bookmark was saved
+// as given.
+var ErrOriginalURLNotFound = errors.New("original url not found")
+
+// OriginalURL is the URL a bookmark was saved with, before the URL rules of
+// the repository cleaned it.
+type OriginalURL struct {
+	BookmarkID int       `json:"bookmark_id"`
+	URL        string    `json:"url"`
+	CleanedAt  time.Time `json:"cleaned_at"`
+}
+
+// SaveOriginalURL stores or replaces the original URL of a bookmark.
+func (bm *BookmarkModel) SaveOriginalURL(ctx context.Context, o *OriginalURL) error {
+	if o.CleanedAt.IsZero() {
+		o.CleanedAt = time.Now().UTC()
+	}
+
+	_, err := bm.conn.ExecContext(ctx, `
+		INSERT INTO gmweb_original_urls (bookmark_id, url, cleaned_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET
+			url = excluded.url, cleaned_at = excluded.cleaned_at`,
+		o.BookmarkID, o.URL, o.CleanedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("saving original url: %w", err)
+	}
+
+	return nil
+}
+
+// OriginalURL returns the original URL of the bookmark.
+func (bm *BookmarkModel) OriginalURL(ctx context.Context, bID int) (*OriginalURL, error) {
+	var (
+		o       OriginalURL
+		cleaned string
+	)
+
+	err := bm.conn.QueryRowContext(ctx,
+		"SELECT bookmark_id, url, cleaned_at FROM gmweb_original_urls WHERE bookmark_id = ?", bID,
+	).Scan(&o.BookmarkID, &o.URL, &cleaned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrOriginalURLNotFound, bID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o.CleanedAt, _ = time.Parse(time.RFC3339, cleaned)
+
+	return &o, nil
+}
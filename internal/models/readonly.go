@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// ErrReadOnly is returned by every write on a read-only repository.
+var ErrReadOnly = errors.New("repository is read-only")
+
+// readOnlyRepo wraps a repository and rejects every write.
+type readOnlyRepo struct {
+	Repo
+}
+
+// ReadOnly returns a repository that delegates reads to r and rejects every
+// write with ErrReadOnly.
+func ReadOnly(r Repo) Repo {
+	return &readOnlyRepo{Repo: r}
+}
+
+func (readOnlyRepo) InsertOne(context.Context, *bookmark.Bookmark) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (readOnlyRepo) InsertMany(context.Context, []*bookmark.Bookmark) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) UpdateOne(context.Context, *bookmark.Bookmark) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) UpdateNotes(context.Context, int, string) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) SetFavorite(context.Context, *bookmark.Bookmark) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) AddVisit(context.Context, int) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) SetFavicon(context.Context, int, string, string) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) SetStatus(context.Context, *bookmark.Bookmark) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) Begin(context.Context) (Batch, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyRepo) DeleteMany(context.Context, []*bookmark.Bookmark) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) RestoreRevision(context.Context, int, int) (*bookmark.Bookmark, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyRepo) SaveRedirect(context.Context, *Redirect) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) ClearRedirect(context.Context, int) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) AcceptRedirect(context.Context, int) (*bookmark.Bookmark, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyRepo) AddSnapshot(context.Context, *Snapshot) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) DeleteSnapshot(context.Context, int, int) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyRepo) SaveArticle(context.Context, *Article) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) SaveMetadata(context.Context, *Metadata) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) SaveOriginalURL(context.Context, *OriginalURL) error {
+	return ErrReadOnly
+}
+
+func (readOnlyRepo) Vacuum(context.Context) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// ErrRedirectNotFound is returned when a bookmark has no pending redirect.
+var ErrRedirectNotFound = errors.New("redirect not found")
+
+// Hop is a single redirect response followed while checking a URL.
+type Hop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
+// Redirect is a bookmark whose URL redirects to a different location,
+// waiting to be reviewed.
+type Redirect struct {
+	BookmarkID int       `json:"bookmark_id"`
+	URL        string    `json:"url"`
+	FinalURL   string    `json:"final_url"`
+	Chain      []Hop     `json:"chain"`
+	Permanent  bool      `json:"permanent"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// IsPermanent reports whether every hop of the chain is a permanent
+// redirect (301 or 308).
+func IsPermanent(chain []Hop) bool {
+	if len(chain) == 0 {
+		return false
+	}
+
+	for _, h := range chain {
+		if h.StatusCode != 301 && h.StatusCode != 308 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SaveRedirect stores or replaces the pending redirect of a bookmark.
+func (bm *BookmarkModel) SaveRedirect(ctx context.Context, r *Redirect) error {
+	chain, err := json.Marshal(r.Chain)
+	if err != nil {
+		return err
+	}
+
+	checked := r.CheckedAt
+	if checked.IsZero() {
+		checked = time.Now()
+	}
+
+	_, err = bm.conn.ExecContext(ctx, `
+		INSERT INTO gmweb_redirects (bookmark_id, url, final_url, chain, permanent, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET
+			url = excluded.url, final_url = excluded.final_url, chain = excluded.chain,
+			permanent = excluded.permanent, checked_at = excluded.checked_at`,
+		r.BookmarkID, r.URL, r.FinalURL, string(chain), r.Permanent, checked.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("saving redirect: %w", err)
+	}
+
+	return nil
+}
+
+// ClearRedirect removes the pending redirect of a bookmark, if any.
+func (bm *BookmarkModel) ClearRedirect(ctx context.Context, bID int) error {
+	_, err := bm.conn.ExecContext(ctx, "DELETE FROM gmweb_redirects WHERE bookmark_id = ?", bID)
+	return err
+}
+
+// Redirects returns the pending redirects, permanent ones first.
+func (bm *BookmarkModel) Redirects(ctx context.Context) ([]*Redirect, error) {
+	rows, err := bm.conn.QueryContext(ctx, `
+		SELECT bookmark_id, url, final_url, chain, permanent, checked_at
+		FROM gmweb_redirects ORDER BY permanent DESC, bookmark_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rs []*Redirect
+	for rows.Next() {
+		r, err := scanRedirect(rows)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, rows.Err()
+}
+
+// RedirectByID returns the pending redirect of a bookmark.
+func (bm *BookmarkModel) RedirectByID(ctx context.Context, bID int) (*Redirect, error) {
+	row := bm.conn.QueryRowContext(ctx, `
+		SELECT bookmark_id, url, final_url, chain, permanent, checked_at
+		FROM gmweb_redirects WHERE bookmark_id = ?`, bID)
+
+	r, err := scanRedirect(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrRedirectNotFound, bID)
+	}
+
+	return r, err
+}
+
+// AcceptRedirect moves the bookmark to the final URL of its pending redirect.
+// The old URL is kept as an alias so Has still finds the bookmark by it.
+func (bm *BookmarkModel) AcceptRedirect(ctx context.Context, bID int) (*bookmark.Bookmark, error) {
+	r, err := bm.RedirectByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := bm.store.ByID(ctx, bID)
+	if err != nil {
+		return nil, err
+	}
+
+	if other, ok := bm.ByCanonicalURL(ctx, r.FinalURL); ok && other.ID != b.ID {
+		return nil, fmt.Errorf("%w: %s (id %d)", ErrRecordDuplicate, r.FinalURL, other.ID)
+	}
+
+	oldURL := b.URL
+	b.URL = r.FinalURL
+	b.GenChecksum()
+	if err := bm.UpdateOne(ctx, b); err != nil {
+		return nil, err
+	}
+
+	if err := addAlias(ctx, bm.conn, oldURL, bID); err != nil {
+		return nil, err
+	}
+
+	return b, bm.ClearRedirect(ctx, bID)
+}
+
+// addAlias records url as a former location of the bookmark.
+func addAlias(ctx context.Context, db execer, url string, bID int) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO gmweb_url_aliases (url, bookmark_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET bookmark_id = excluded.bookmark_id, created_at = excluded.created_at`,
+		url, bID, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("recording url alias: %w", err)
+	}
+
+	return nil
+}
+
+// byAlias returns the bookmark that was previously stored under url.
+func (bm *BookmarkModel) byAlias(ctx context.Context, url string) (*bookmark.Bookmark, bool) {
+	var bID int
+	err := bm.conn.QueryRowContext(ctx,
+		"SELECT bookmark_id FROM gmweb_url_aliases WHERE url = ?", url,
+	).Scan(&bID)
+	if err != nil {
+		return nil, false
+	}
+
+	b, err := bm.store.ByID(ctx, bID)
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+func scanRedirect(row rowScanner) (*Redirect, error) {
+	var (
+		r       Redirect
+		chain   string
+		checked string
+	)
+
+	err := row.Scan(&r.BookmarkID, &r.URL, &r.FinalURL, &chain, &r.Permanent, &checked)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(chain), &r.Chain); err != nil {
+		return nil, fmt.Errorf("decoding redirect chain: %w", err)
+	}
+	r.CheckedAt, _ = time.Parse(time.RFC3339, checked)
+
+	return &r, nil
+}
@@ -18,6 +18,10 @@ type Reader interface {
 	// Has returns a bookmark by its URL and a boolean indicating if it exists.
 	Has(ctx context.Context, url string) (*bookmark.Bookmark, bool)
 
+	// ByCanonicalURL returns the bookmark saved under a URL of the same page
+	// as url.
+	ByCanonicalURL(ctx context.Context, url string) (*bookmark.Bookmark, bool)
+
 	// Count returns the number of records in the given table.
 	Count(ctx context.Context, table db.Table) int
 
@@ -48,13 +52,157 @@ type Writer interface {
 	// AddVisitAndUpdateCount adds a visit to a bookmark and updates its count.
 	AddVisit(ctx context.Context, bID int) error
 
+	// SetFavicon updates the favicon fields of a bookmark, leaving the rest
+	// of the row untouched.
+	SetFavicon(ctx context.Context, bID int, faviconURL, faviconLocal string) error
+
+	// SetStatus updates the link status fields of a bookmark, leaving the
+	// rest of the row untouched.
+	SetStatus(ctx context.Context, b *bookmark.Bookmark) error
+
 	// DeleteMany deletes multiple bookmarks.
 	DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error
 }
 
+// Batch groups writes to a repository that are applied together by Commit,
+// or not at all.
+type Batch interface {
+	// InsertOne inserts a bookmark.
+	InsertOne(ctx context.Context, b *bookmark.Bookmark) (int64, error)
+
+	// UpdateOne updates an existing bookmark, recording its revision.
+	UpdateOne(ctx context.Context, b *bookmark.Bookmark) error
+
+	// SetFavorite sets a bookmark as favorite.
+	SetFavorite(ctx context.Context, b *bookmark.Bookmark) error
+
+	// DeleteMany deletes multiple bookmarks.
+	DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error
+
+	// Merge moves the history, snapshots and page data of the others to
+	// keep, records their URLs as aliases of keep and deletes them.
+	Merge(ctx context.Context, keep *bookmark.Bookmark, others []*bookmark.Bookmark) error
+
+	// Commit applies the writes.
+	Commit() error
+
+	// Rollback discards the writes. It does nothing after Commit.
+	Rollback() error
+}
+
+// Historian provides access to the revisions of the bookmarks.
+type Historian interface {
+	// Revisions returns the revisions of the bookmark, newest first.
+	Revisions(ctx context.Context, bID int) ([]*Revision, error)
+
+	// RevisionByID returns a revision of the bookmark.
+	RevisionByID(ctx context.Context, bID, revID int) (*Revision, error)
+
+	// RestoreRevision sets the bookmark fields back to the given revision.
+	RestoreRevision(ctx context.Context, bID, revID int) (*bookmark.Bookmark, error)
+}
+
+// Redirector tracks the bookmarks whose URL moved to a new location.
+type Redirector interface {
+	// Redirects returns the pending redirects.
+	Redirects(ctx context.Context) ([]*Redirect, error)
+
+	// SaveRedirect stores or replaces the pending redirect of a bookmark.
+	SaveRedirect(ctx context.Context, r *Redirect) error
+
+	// ClearRedirect removes the pending redirect of a bookmark.
+	ClearRedirect(ctx context.Context, bID int) error
+
+	// AcceptRedirect moves the bookmark to the final URL of its redirect.
+	AcceptRedirect(ctx context.Context, bID int) (*bookmark.Bookmark, error)
+}
+
+// Snapshotter keeps the index of the local copies of the bookmarked pages.
+type Snapshotter interface {
+	// AddSnapshot records a snapshot of a bookmark.
+	AddSnapshot(ctx context.Context, s *Snapshot) error
+
+	// Snapshots returns the snapshots of the bookmark, newest first.
+	Snapshots(ctx context.Context, bID int) ([]*Snapshot, error)
+
+	// SnapshotByID returns a snapshot of the bookmark.
+	SnapshotByID(ctx context.Context, bID, sID int) (*Snapshot, error)
+
+	// DeleteSnapshot removes a snapshot and reports whether its content is
+	// no longer referenced.
+	DeleteSnapshot(ctx context.Context, bID, sID int) (bool, error)
+
+	// HasSnapshotContent reports whether the content is already referenced.
+	HasSnapshotContent(ctx context.Context, hash string) (bool, error)
+
+	// ArchiveSize returns the bytes used by the snapshot contents.
+	ArchiveSize(ctx context.Context) (int64, error)
+}
+
+// ArticleStore keeps the readable content extracted from the bookmarked
+// pages.
+type ArticleStore interface {
+	// SaveArticle stores or replaces the article of a bookmark.
+	SaveArticle(ctx context.Context, a *Article) error
+
+	// Article returns the article extracted for the bookmark.
+	Article(ctx context.Context, bID int) (*Article, error)
+
+	// SearchArticles returns the IDs of the bookmarks whose article matches
+	// the query.
+	SearchArticles(ctx context.Context, query string) ([]int, error)
+}
+
+// MetadataStore keeps the metadata scraped from the bookmarked pages.
+type MetadataStore interface {
+	// SaveMetadata stores or replaces the metadata of a bookmark.
+	SaveMetadata(ctx context.Context, m *Metadata) error
+
+	// Metadata returns the metadata saved for the bookmark.
+	Metadata(ctx context.Context, bID int) (*Metadata, error)
+
+	// Previews returns the preview keys of the bookmarks that have one.
+	Previews(ctx context.Context) (map[int]string, error)
+}
+
+// OriginStore keeps the URLs the bookmarks were saved with before the URL
+// rules cleaned them.
+type OriginStore interface {
+	// SaveOriginalURL stores or replaces the original URL of a bookmark.
+	SaveOriginalURL(ctx context.Context, o *OriginalURL) error
+
+	// OriginalURL returns the original URL of the bookmark.
+	OriginalURL(ctx context.Context, bID int) (*OriginalURL, error)
+}
+
+// Maintainer provides schema and housekeeping operations on the repository.
+type Maintainer interface {
+	// SchemaVersion returns the schema version recorded in the repository.
+	SchemaVersion(ctx context.Context) (int, error)
+
+	// IntegrityCheck runs an integrity check and returns its report.
+	IntegrityCheck(ctx context.Context) ([]string, error)
+
+	// Vacuum rebuilds the database file, reclaiming unused space.
+	Vacuum(ctx context.Context) error
+}
+
 type Repo interface {
 	Reader
 	Writer
+	Historian
+	Redirector
+	Snapshotter
+	ArticleStore
+	MetadataStore
+	OriginStore
+	Maintainer
+
+	// Begin starts a batch of writes.
+	Begin(ctx context.Context) (Batch, error)
+
+	// Ping verifies the connection to the repository is usable.
+	Ping(ctx context.Context) error
 
 	// Close closes the repository.
 	Close()
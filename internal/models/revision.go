@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// ErrRevisionNotFound is returned when a revision does not exist for the
+// given bookmark.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is a snapshot of the editable fields of a bookmark, taken right
+// before they were changed.
+type Revision struct {
+	ID         int       `json:"id"`
+	BookmarkID int       `json:"bookmark_id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	Desc       string    `json:"desc"`
+	Tags       string    `json:"tags"`
+	Notes      string    `json:"notes"`
+	User       string    `json:"user,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FieldChange is the difference of a single field between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// NewRevision returns a snapshot of the editable fields of b.
+func NewRevision(b *bookmark.Bookmark) *Revision {
+	return &Revision{
+		BookmarkID: b.ID,
+		URL:        b.URL,
+		Title:      b.Title,
+		Desc:       b.Desc,
+		Tags:       b.Tags,
+		Notes:      b.Notes,
+	}
+}
+
+// Diff returns the fields that changed from r to next.
+func (r *Revision) Diff(next *Revision) []FieldChange {
+	fields := []struct{ name, old, new string }{
+		{"url", r.URL, next.URL},
+		{"title", r.Title, next.Title},
+		{"desc", r.Desc, next.Desc},
+		{"tags", r.Tags, next.Tags},
+		{"notes", r.Notes, next.Notes},
+	}
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	return changes
+}
+
+// Apply copies the revision fields into b.
+func (r *Revision) Apply(b *bookmark.Bookmark) {
+	b.URL = r.URL
+	b.Title = r.Title
+	b.Desc = r.Desc
+	b.Tags = r.Tags
+	b.Notes = r.Notes
+}
+
+type userCtxKey struct{}
+
+// WithUser returns a context carrying the name of the user making the
+// changes, recorded in the revisions.
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userCtxKey{}, name)
+}
+
+// UserFromContext returns the user name stored by WithUser, if any.
+func UserFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(userCtxKey{}).(string)
+	return name
+}
+
+// recordRevision stores prev as a revision, within the transaction of the
+// update, if any editable field differs from next.
+func recordRevision(ctx context.Context, tx *sql.Tx, prev, next *bookmark.Bookmark) error {
+	rev := NewRevision(prev)
+	if len(rev.Diff(NewRevision(next))) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO gmweb_revisions (bookmark_id, url, title, description, tags, notes, user, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.BookmarkID, rev.URL, rev.Title, rev.Desc, rev.Tags, rev.Notes,
+		UserFromContext(ctx), time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("recording revision: %w", err)
+	}
+
+	return nil
+}
+
+// Revisions returns the revisions of the bookmark, newest first.
+func (bm *BookmarkModel) Revisions(ctx context.Context, bID int) ([]*Revision, error) {
+	rows, err := bm.conn.QueryContext(ctx, `
+		SELECT id, bookmark_id, url, title, description, tags, notes, user, created_at
+		FROM gmweb_revisions WHERE bookmark_id = ? ORDER BY id DESC`, bID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var revs []*Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+
+	return revs, rows.Err()
+}
+
+// RevisionByID returns a revision of the bookmark.
+func (bm *BookmarkModel) RevisionByID(ctx context.Context, bID, revID int) (*Revision, error) {
+	row := bm.conn.QueryRowContext(ctx, `
+		SELECT id, bookmark_id, url, title, description, tags, notes, user, created_at
+		FROM gmweb_revisions WHERE bookmark_id = ? AND id = ?`, bID, revID)
+
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, revID)
+	}
+
+	return rev, err
+}
+
+// RestoreRevision sets the bookmark fields back to the given revision. The
+// replaced state is recorded as a new revision, in the same transaction.
+func (bm *BookmarkModel) RestoreRevision(ctx context.Context, bID, revID int) (*bookmark.Bookmark, error) {
+	err := bm.withTx(ctx, func(tx *sql.Tx) error {
+		rev, err := scanRevision(tx.QueryRowContext(ctx, `
+			SELECT id, bookmark_id, url, title, description, tags, notes, user, created_at
+			FROM gmweb_revisions WHERE bookmark_id = ? AND id = ?`, bID, revID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %d", ErrRevisionNotFound, revID)
+		}
+		if err != nil {
+			return err
+		}
+
+		prev, err := editableRow(ctx, tx, bID)
+		if err != nil {
+			return err
+		}
+
+		next := *prev
+		rev.Apply(&next)
+		next.GenChecksum()
+		if err := updateEditable(ctx, tx, prev.URL, &next); err != nil {
+			return err
+		}
+
+		return recordRevision(ctx, tx, prev, &next)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bm.store.ByID(ctx, bID)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRevision(row rowScanner) (*Revision, error) {
+	var (
+		rev     Revision
+		created string
+	)
+
+	err := row.Scan(&rev.ID, &rev.BookmarkID, &rev.URL, &rev.Title, &rev.Desc,
+		&rev.Tags, &rev.Notes, &rev.User, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.CreatedAt, _ = time.Parse(time.RFC3339, created)
+
+	return &rev, nil
+}
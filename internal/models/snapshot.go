@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSnapshotNotFound is returned when a snapshot does not exist for the
+// given bookmark.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot kinds.
+const (
+	SnapshotPage = "page" // the page with its assets inlined
+)
+
+// Snapshot is a local copy of a bookmarked page. The content lives in the
+// archive store under Hash.
+type Snapshot struct {
+	ID         int       `json:"id"`
+	BookmarkID int       `json:"bookmark_id"`
+	Kind       string    `json:"kind"`
+	Hash       string    `json:"hash"`
+	URL        string    `json:"url"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AddSnapshot records a snapshot of a bookmark and sets its ID.
+func (bm *BookmarkModel) AddSnapshot(ctx context.Context, s *Snapshot) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now().UTC()
+	}
+
+	res, err := bm.conn.ExecContext(ctx, `
+		INSERT INTO gmweb_snapshots (bookmark_id, kind, hash, url, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		s.BookmarkID, s.Kind, s.Hash, s.URL, s.Size, s.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("recording snapshot: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = int(id)
+
+	return nil
+}
+
+// Snapshots returns the snapshots of the bookmark, newest first.
+func (bm *BookmarkModel) Snapshots(ctx context.Context, bID int) ([]*Snapshot, error) {
+	rows, err := bm.conn.QueryContext(ctx, `
+		SELECT id, bookmark_id, kind, hash, url, size, created_at
+		FROM gmweb_snapshots WHERE bookmark_id = ? ORDER BY id DESC`, bID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ss []*Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, rows.Err()
+}
+
+// SnapshotByID returns a snapshot of the bookmark.
+func (bm *BookmarkModel) SnapshotByID(ctx context.Context, bID, sID int) (*Snapshot, error) {
+	row := bm.conn.QueryRowContext(ctx, `
+		SELECT id, bookmark_id, kind, hash, url, size, created_at
+		FROM gmweb_snapshots WHERE bookmark_id = ? AND id = ?`, bID, sID)
+
+	s, err := scanSnapshot(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotNotFound, sID)
+	}
+
+	return s, err
+}
+
+// DeleteSnapshot removes a snapshot of the bookmark. It reports whether its
+// content is no longer referenced by any other snapshot.
+func (bm *BookmarkModel) DeleteSnapshot(ctx context.Context, bID, sID int) (orphan bool, err error) {
+	s, err := bm.SnapshotByID(ctx, bID, sID)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := bm.conn.ExecContext(ctx, "DELETE FROM gmweb_snapshots WHERE id = ?", s.ID); err != nil {
+		return false, err
+	}
+
+	var refs int
+	err = bm.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM gmweb_snapshots WHERE hash = ?", s.Hash).Scan(&refs)
+	if err != nil {
+		return false, err
+	}
+
+	return refs == 0, nil
+}
+
+// HasSnapshotContent reports whether any snapshot already references the
+// content with the given hash.
+func (bm *BookmarkModel) HasSnapshotContent(ctx context.Context, hash string) (bool, error) {
+	var n int
+	err := bm.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM gmweb_snapshots WHERE hash = ?", hash).Scan(&n)
+	return n > 0, err
+}
+
+// ArchiveSize returns the bytes used by the distinct snapshot contents.
+func (bm *BookmarkModel) ArchiveSize(ctx context.Context) (int64, error) {
+	var size sql.NullInt64
+	err := bm.conn.QueryRowContext(ctx, `
+		SELECT SUM(size) FROM (SELECT DISTINCT hash, size FROM gmweb_snapshots)`).Scan(&size)
+	return size.Int64, err
+}
+
+func scanSnapshot(row rowScanner) (*Snapshot, error) {
+	var (
+		s       Snapshot
+		created string
+	)
+
+	err := row.Scan(&s.ID, &s.BookmarkID, &s.Kind, &s.Hash, &s.URL, &s.Size, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	s.CreatedAt, _ = time.Parse(time.RFC3339, created)
+
+	return &s, nil
+}
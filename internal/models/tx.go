@@ -0,0 +1,290 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// The gm store runs each write in a transaction of its own connection, so
+// the writes that must land together with a gmweb table (a revision, a
+// canonical URL) are made here instead, on the gmweb connection and in a
+// single transaction. They follow the gm schema: the bookmarks table, plus
+// the tags and bookmark_tags tables linking the tags to the bookmark URL.
+
+// withTx runs fn in a transaction, committed when fn returns nil.
+func (bm *BookmarkModel) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := bm.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// batch runs the writes of a Batch in a transaction.
+type batch struct {
+	tx *sql.Tx
+}
+
+// Begin starts a batch of writes, held in a transaction until Commit.
+func (bm *BookmarkModel) Begin(ctx context.Context) (Batch, error) {
+	tx, err := bm.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch{tx: tx}, nil
+}
+
+func (bt *batch) InsertOne(ctx context.Context, b *bookmark.Bookmark) (int64, error) {
+	res, err := bt.tx.ExecContext(ctx, `
+		INSERT INTO bookmarks (
+			url, title, "desc", tags, notes, created_at, last_visit, updated_at,
+			visit_count, favorite, favicon_url, favicon_local, checksum,
+			archive_url, archive_timestamp, last_status_checked,
+			status_code, status_text, is_active
+		) VALUES (?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), CURRENT_TIMESTAMP), ?, CURRENT_TIMESTAMP,
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.URL, b.Title, b.Desc, b.Tags, b.Notes, b.CreatedAt, b.LastVisit,
+		b.VisitCount, b.Favorite, b.FaviconURL, b.FaviconLocal, b.Checksum,
+		b.ArchiveURL, b.ArchiveTimestamp, b.LastStatusChecked,
+		b.HTTPStatusCode, b.HTTPStatusText, b.IsActive,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("inserting bookmark: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setCanonical(ctx, bt.tx, int(id), b.URL); err != nil {
+		return 0, err
+	}
+
+	return id, linkTags(ctx, bt.tx, b.URL, b.URL, b.Tags)
+}
+
+func (bt *batch) UpdateOne(ctx context.Context, b *bookmark.Bookmark) error {
+	return updateOne(ctx, bt.tx, b)
+}
+
+func (bt *batch) SetFavorite(ctx context.Context, b *bookmark.Bookmark) error {
+	_, err := bt.tx.ExecContext(ctx, "UPDATE bookmarks SET favorite = ? WHERE id = ?", b.Favorite, b.ID)
+	return err
+}
+
+// DeleteMany deletes the bookmarks and their tag links. The trigger of the
+// bookmarks table removes the rest.
+func (bt *batch) DeleteMany(ctx context.Context, bs []*bookmark.Bookmark) error {
+	for _, b := range bs {
+		if _, err := bt.tx.ExecContext(ctx, "DELETE FROM bookmarks WHERE id = ?", b.ID); err != nil {
+			return fmt.Errorf("deleting bookmark: %w", err)
+		}
+		if _, err := bt.tx.ExecContext(ctx, "DELETE FROM bookmark_tags WHERE bookmark_url = ?", b.URL); err != nil {
+			return fmt.Errorf("unlinking tags: %w", err)
+		}
+	}
+
+	return pruneTags(ctx, bt.tx)
+}
+
+// Merge moves the rows of the gmweb tables from the others to keep before
+// deleting them, so the trigger of the bookmarks table finds nothing left
+// to remove. Where keep already has a row of its own, as an article or the
+// page metadata, keep's row wins. The redirects found for the others are
+// dropped: they describe URLs keep is not saved under.
+func (bt *batch) Merge(ctx context.Context, keep *bookmark.Bookmark, others []*bookmark.Bookmark) error {
+	for _, b := range others {
+		var origin sql.NullString
+		err := bt.tx.QueryRowContext(ctx,
+			"SELECT url FROM gmweb_original_urls WHERE bookmark_id = ?", b.ID).Scan(&origin)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reading original url: %w", err)
+		}
+
+		// the search index follows the article, unless keep has one already.
+		if _, err := bt.tx.ExecContext(ctx, `
+			INSERT INTO gmweb_articles_fts (rowid, title, text)
+			SELECT ?, title, text FROM gmweb_articles_fts
+			WHERE rowid = ? AND NOT EXISTS (SELECT 1 FROM gmweb_articles WHERE bookmark_id = ?)`,
+			keep.ID, b.ID, keep.ID,
+		); err != nil {
+			return fmt.Errorf("moving article index: %w", err)
+		}
+
+		for _, q := range []string{
+			"UPDATE gmweb_revisions SET bookmark_id = ? WHERE bookmark_id = ?",
+			"UPDATE gmweb_snapshots SET bookmark_id = ? WHERE bookmark_id = ?",
+			"UPDATE gmweb_url_aliases SET bookmark_id = ? WHERE bookmark_id = ?",
+			"UPDATE OR IGNORE gmweb_articles SET bookmark_id = ? WHERE bookmark_id = ?",
+			"UPDATE OR IGNORE gmweb_metadata SET bookmark_id = ? WHERE bookmark_id = ?",
+			"UPDATE OR IGNORE gmweb_original_urls SET bookmark_id = ? WHERE bookmark_id = ?",
+		} {
+			if _, err := bt.tx.ExecContext(ctx, q, keep.ID, b.ID); err != nil {
+				return fmt.Errorf("merging bookmark %d: %w", b.ID, err)
+			}
+		}
+
+		for _, u := range []string{b.URL, origin.String} {
+			if u == "" || u == keep.URL {
+				continue
+			}
+			if err := addAlias(ctx, bt.tx, u, keep.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bt.DeleteMany(ctx, others)
+}
+
+func (bt *batch) Commit() error {
+	return bt.tx.Commit()
+}
+
+func (bt *batch) Rollback() error {
+	if err := bt.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+
+	return nil
+}
+
+// updateOne updates the bookmark and records its previous state as a
+// revision.
+func updateOne(ctx context.Context, tx *sql.Tx, b *bookmark.Bookmark) error {
+	prev, err := editableRow(ctx, tx, b.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := updateRow(ctx, tx, prev.URL, b); err != nil {
+		return err
+	}
+
+	return recordRevision(ctx, tx, prev, b)
+}
+
+// editableRow reads the fields a revision keeps, within the transaction, so
+// the state recorded is the one the update replaces.
+func editableRow(ctx context.Context, tx *sql.Tx, bID int) (*bookmark.Bookmark, error) {
+	var b bookmark.Bookmark
+	var tags, notes sql.NullString
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, url, title, "desc", tags, notes FROM bookmarks WHERE id = ?`, bID,
+	).Scan(&b.ID, &b.URL, &b.Title, &b.Desc, &tags, &notes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", bookmark.ErrBookmarkNotFound, bID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.Tags, b.Notes = tags.String, notes.String
+
+	return &b, nil
+}
+
+// updateRow writes every column of the bookmark, keeping the creation time
+// when b has none, indexes its canonical URL and relinks its tags.
+func updateRow(ctx context.Context, tx *sql.Tx, prevURL string, b *bookmark.Bookmark) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE bookmarks SET
+			url = ?, title = ?, "desc" = ?, tags = ?, notes = ?,
+			created_at = COALESCE(NULLIF(?, ''), created_at), last_visit = ?,
+			updated_at = CURRENT_TIMESTAMP, visit_count = ?, favorite = ?,
+			favicon_url = ?, favicon_local = ?, checksum = ?,
+			archive_url = ?, archive_timestamp = ?, last_status_checked = ?,
+			status_code = ?, status_text = ?, is_active = ?
+		WHERE id = ?`,
+		b.URL, b.Title, b.Desc, b.Tags, b.Notes, b.CreatedAt, b.LastVisit,
+		b.VisitCount, b.Favorite,
+		b.FaviconURL, b.FaviconLocal, b.Checksum,
+		b.ArchiveURL, b.ArchiveTimestamp, b.LastStatusChecked,
+		b.HTTPStatusCode, b.HTTPStatusText, b.IsActive,
+		b.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating bookmark: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", bookmark.ErrBookmarkNotFound, b.ID)
+	}
+	if err := setCanonical(ctx, tx, b.ID, b.URL); err != nil {
+		return err
+	}
+
+	return linkTags(ctx, tx, prevURL, b.URL, b.Tags)
+}
+
+// updateEditable writes only the fields a revision keeps and the checksum,
+// indexes the canonical URL and relinks the tags of the bookmark.
+func updateEditable(ctx context.Context, tx *sql.Tx, prevURL string, b *bookmark.Bookmark) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE bookmarks SET
+			url = ?, title = ?, "desc" = ?, tags = ?, notes = ?, checksum = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		b.URL, b.Title, b.Desc, b.Tags, b.Notes, b.Checksum, b.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating bookmark: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", bookmark.ErrBookmarkNotFound, b.ID)
+	}
+	if err := setCanonical(ctx, tx, b.ID, b.URL); err != nil {
+		return err
+	}
+
+	return linkTags(ctx, tx, prevURL, b.URL, b.Tags)
+}
+
+// linkTags replaces the tags linked to the bookmark, moved from prevURL to
+// url, and drops the tags no bookmark uses anymore.
+func linkTags(ctx context.Context, tx *sql.Tx, prevURL, url, tags string) error {
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM bookmark_tags WHERE bookmark_url IN (?, ?)", prevURL, url,
+	); err != nil {
+		return fmt.Errorf("unlinking tags: %w", err)
+	}
+
+	for name := range strings.SplitSeq(tags, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", name); err != nil {
+			return fmt.Errorf("adding tag %q: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO bookmark_tags (bookmark_url, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`, url, name,
+		); err != nil {
+			return fmt.Errorf("linking tag %q: %w", name, err)
+		}
+	}
+
+	return pruneTags(ctx, tx)
+}
+
+// pruneTags drops the tags no bookmark uses.
+func pruneTags(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM bookmark_tags)")
+	if err != nil {
+		return fmt.Errorf("pruning tags: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package preview downloads the preview image of a page, as announced by its
+// OpenGraph or Twitter card tags, and keeps resized copies in a cache
+// directory.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoder
+	"image/jpeg"
+	_ "image/png" // register decoder
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/mateconpizza/gm/pkg/files"
+
+	"github.com/mateconpizza/gmweb/internal/imgutil"
+)
+
+const (
+	MaxImageSize = 8 << 20 // bytes of the downloaded image
+	MaxPixels    = 40e6    // pixels of the decoded image
+	Quality      = 80      // JPEG quality of the thumbnails
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image too large")
+)
+
+// Size is a thumbnail size. Images are cropped to its aspect ratio.
+type Size struct {
+	Name          string
+	Width, Height int
+}
+
+// Sizes are the thumbnails kept for every preview.
+var Sizes = []Size{
+	{Name: "small", Width: 320, Height: 180},
+	{Name: "large", Width: 640, Height: 360},
+}
+
+// Store keeps the thumbnails under dir, named by the hash of the original
+// image so the files never change once written.
+type Store struct {
+	dir    string
+	client *http.Client
+}
+
+// New creates a store under dir, downloading the images with client.
+func New(dir string, client *http.Client) *Store {
+	return &Store{dir: dir, client: client}
+}
+
+// Dir returns the directory of the thumbnails.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// File returns the name of the thumbnail of the given size.
+func File(key, size string) string {
+	return key + "-" + size + ".jpg"
+}
+
+// Save downloads the image, writes its thumbnails and returns their key.
+func (s *Store) Save(ctx context.Context, imageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "image/jpeg,image/png,image/gif;q=0.9,*/*;q=0.5")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching preview: unexpected status: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxImageSize {
+		return "", fmt.Errorf("%w: more than %d bytes", ErrTooLarge, MaxImageSize)
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:16])
+	if s.has(key) {
+		return key, nil
+	}
+
+	src, err := decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := files.MkdirAll(s.dir); err != nil {
+		return "", err
+	}
+	for _, size := range Sizes {
+		if err := s.write(File(key, size.Name), imgutil.Cover(src, size.Width, size.Height)); err != nil {
+			return "", err
+		}
+	}
+
+	return key, nil
+}
+
+func (s *Store) has(key string) bool {
+	for _, size := range Sizes {
+		if !files.Exists(filepath.Join(s.dir, File(key, size.Name))) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Store) write(name string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: Quality}); err != nil {
+		return err
+	}
+
+	p := filepath.Join(s.dir, name)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), files.FilePerm); err != nil {
+		return fmt.Errorf("writing preview: %w", err)
+	}
+
+	return os.Rename(tmp, p)
+}
+
+// decode checks the dimensions of the image before decoding it, so a small
+// file cannot claim a huge canvas.
+func decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || float64(cfg.Width)*float64(cfg.Height) > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+
+	return img, nil
+}
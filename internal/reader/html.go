@@ -0,0 +1,257 @@
+package reader
+
+import (
+	"html"
+	"strings"
+)
+
+// node is an element or a text run of a parsed page. Text nodes have an
+// empty tag.
+type node struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+var (
+	voidTags = set("area", "base", "br", "col", "embed", "hr", "img", "input",
+		"link", "meta", "param", "source", "track", "wbr")
+
+	// rawTags hold text that is not markup.
+	rawTags = set("script", "style", "noscript", "template", "textarea", "title", "svg", "math")
+
+	// selfClosing are closed by an opening tag of the same kind.
+	selfClosing = set("p", "li", "dt", "dd", "tr", "td", "th", "option")
+)
+
+func set(ss ...string) map[string]bool {
+	m := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		m[s] = true
+	}
+
+	return m
+}
+
+// parse builds a lenient tree of the page. Unknown or mismatched closing
+// tags are ignored; unclosed elements end with their parent.
+func parse(page string) *node {
+	root := &node{tag: "#root"}
+	cur := root
+
+	for len(page) > 0 {
+		lt := strings.IndexByte(page, '<')
+		if lt < 0 {
+			cur.addText(page)
+			break
+		}
+		if lt > 0 {
+			cur.addText(page[:lt])
+			page = page[lt:]
+		}
+
+		switch {
+		case strings.HasPrefix(page, "<!--"):
+			end := strings.Index(page, "-->")
+			if end < 0 {
+				return root
+			}
+			page = page[end+3:]
+
+		case strings.HasPrefix(page, "</"):
+			end := strings.IndexByte(page, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(page[2:end]))
+			page = page[end+1:]
+			for n := cur; n != root; n = n.parent {
+				if n.tag == name {
+					cur = n.parent
+					break
+				}
+			}
+
+		case len(page) > 1 && isLetter(page[1]):
+			end := tagEnd(page)
+			if end < 0 {
+				return root
+			}
+			name, attrs, closed := parseTag(page[1:end])
+			page = page[end+1:]
+
+			if selfClosing[name] && cur.tag == name {
+				cur = cur.parent
+			}
+			n := &node{tag: name, attrs: attrs, parent: cur}
+			cur.children = append(cur.children, n)
+
+			switch {
+			case rawTags[name]:
+				end := strings.Index(strings.ToLower(page), "</"+name)
+				if end < 0 {
+					end = len(page)
+				}
+				n.text = page[:end]
+				page = page[end:]
+				if gt := strings.IndexByte(page, '>'); gt >= 0 {
+					page = page[gt+1:]
+				}
+			case !voidTags[name] && !closed:
+				cur = n
+			}
+
+		default:
+			// a stray '<' or a doctype.
+			if strings.HasPrefix(page, "<!") || strings.HasPrefix(page, "<?") {
+				end := strings.IndexByte(page, '>')
+				if end < 0 {
+					return root
+				}
+				page = page[end+1:]
+				continue
+			}
+			cur.addText("<")
+			page = page[1:]
+		}
+	}
+
+	return root
+}
+
+func (n *node) addText(s string) {
+	n.children = append(n.children, &node{text: html.UnescapeString(s), parent: n})
+}
+
+// tagEnd returns the index of the '>' closing the tag at the start of s,
+// skipping quoted attribute values.
+func tagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseTag splits the inside of a start tag into its name and attributes.
+func parseTag(s string) (name string, attrs map[string]string, closed bool) {
+	s, closed = strings.CutSuffix(strings.TrimSpace(s), "/")
+
+	i := strings.IndexAny(s, " \t\r\n/")
+	if i < 0 {
+		return strings.ToLower(s), nil, closed
+	}
+	name, s = strings.ToLower(s[:i]), s[i:]
+
+	attrs = make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t\r\n/")
+		if s == "" {
+			return name, attrs, closed
+		}
+
+		end := strings.IndexAny(s, " \t\r\n=/")
+		if end < 0 {
+			end = len(s)
+		}
+		key := strings.ToLower(s[:end])
+		s = strings.TrimLeft(s[end:], " \t\r\n")
+
+		var val string
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t\r\n")
+			if s != "" && (s[0] == '"' || s[0] == '\'') {
+				q := s[0]
+				end := strings.IndexByte(s[1:], q)
+				if end < 0 {
+					end = len(s) - 1
+				}
+				val, s = s[1:end+1], s[min(end+2, len(s)):]
+			} else {
+				end := strings.IndexAny(s, " \t\r\n")
+				if end < 0 {
+					end = len(s)
+				}
+				val, s = s[:end], s[end:]
+			}
+		}
+		if _, ok := attrs[key]; !ok && key != "" {
+			attrs[key] = html.UnescapeString(val)
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// innerText returns the text of the node and its descendants, with the
+// whitespace collapsed.
+func (n *node) innerText() string {
+	var sb strings.Builder
+	n.walkText(&sb)
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func (n *node) walkText(sb *strings.Builder) {
+	if n.tag == "" {
+		sb.WriteString(n.text)
+		return
+	}
+	if rawTags[n.tag] {
+		return
+	}
+	for _, c := range n.children {
+		c.walkText(sb)
+		if c.tag == "br" || c.tag == "p" || c.tag == "div" || c.tag == "li" {
+			sb.WriteByte(' ')
+		}
+	}
+}
+
+// find returns the elements matching fn, in document order.
+func (n *node) find(fn func(*node) bool) []*node {
+	var out []*node
+	var walk func(*node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			if c.tag == "" {
+				continue
+			}
+			if fn(c) {
+				out = append(out, c)
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return out
+}
+
+// first returns the first element named tag.
+func (n *node) first(tag string) *node {
+	for _, c := range n.children {
+		if c.tag == tag {
+			return c
+		}
+		if f := c.first(tag); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
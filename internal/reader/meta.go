@@ -0,0 +1,204 @@
+package reader
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mateconpizza/gmweb/internal/models"
+)
+
+// Meta returns the metadata the page found at base declares about itself.
+func Meta(page []byte, base *url.URL) *models.Metadata {
+	return meta(parse(string(page)), base)
+}
+
+func meta(root *node, base *url.URL) *models.Metadata {
+	tags := metaTags(root)
+	ld := linkedData(root)
+
+	m := &models.Metadata{
+		Title:     firstOf(tags["og:title"], tags["twitter:title"], titleText(root), ld.headline()),
+		Desc:      firstOf(tags["og:description"], tags["description"], tags["twitter:description"], ld.str("description")),
+		Keywords:  keywords(tags["keywords"]),
+		Image:     resolve(base, firstOf(tags["og:image"], tags["twitter:image"], tags["twitter:image:src"], ld.image())),
+		SiteName:  firstOf(tags["og:site_name"], tags["application-name"], ld.publisher()),
+		Type:      firstOf(ld.str("@type"), tags["og:type"]),
+		Author:    firstOf(ld.author(), tags["author"], tags["article:author"], tags["twitter:creator"], byline(root)),
+		Canonical: resolve(base, link(root, "canonical")),
+		Published: publishDate(root, tags, ld.str("datePublished")),
+	}
+	if h := root.first("html"); h != nil {
+		m.Lang = strings.TrimSpace(h.attrs["lang"])
+	}
+	if m.Lang == "" {
+		m.Lang = firstOf(tags["og:locale"], tags["content-language"])
+	}
+	if strings.HasPrefix(strings.ToLower(m.Image), "data:") {
+		m.Image = ""
+	}
+
+	m.FaviconURL = resolve(base, firstOf(link(root, "icon"), link(root, "shortcut icon"), link(root, "apple-touch-icon")))
+	if m.FaviconURL == "" && base != nil {
+		m.FaviconURL = resolve(base, "/favicon.ico")
+	}
+
+	return m
+}
+
+// link returns the href of the first <link> with the given rel.
+func link(root *node, rel string) string {
+	for _, l := range root.find(func(n *node) bool { return n.tag == "link" }) {
+		if strings.EqualFold(strings.Join(strings.Fields(l.attrs["rel"]), " "), rel) {
+			return l.attrs["href"]
+		}
+	}
+
+	return ""
+}
+
+func keywords(s string) []string {
+	var out []string
+	for k := range strings.SplitSeq(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+
+	return out
+}
+
+// jsonLD is the main JSON-LD object of a page.
+type jsonLD map[string]any
+
+// linkedData returns the first JSON-LD object with a type, looking into
+// arrays and @graph lists. Article-like objects are preferred over the
+// website or organisation ones.
+func linkedData(root *node) jsonLD {
+	var found []jsonLD
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]any:
+			if g, ok := v["@graph"]; ok {
+				collect(g)
+			}
+			if _, ok := v["@type"]; ok {
+				found = append(found, v)
+			}
+		}
+	}
+
+	for _, s := range root.find(func(n *node) bool {
+		return n.tag == "script" && strings.EqualFold(strings.TrimSpace(n.attrs["type"]), "application/ld+json")
+	}) {
+		var v any
+		if json.Unmarshal([]byte(s.text), &v) == nil {
+			collect(v)
+		}
+	}
+
+	for _, ld := range found {
+		t := strings.ToLower(ld.str("@type"))
+		if strings.Contains(t, "article") || strings.Contains(t, "posting") || ld.str("headline") != "" {
+			return ld
+		}
+	}
+	if len(found) > 0 {
+		return found[0]
+	}
+
+	return nil
+}
+
+// str returns the value of key as a string; for a list, its first string.
+func (ld jsonLD) str(key string) string {
+	switch v := ld[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+
+	return ""
+}
+
+func (ld jsonLD) headline() string {
+	return firstOf(ld.str("headline"), ld.str("name"))
+}
+
+// name returns the name of the person or organisation held by key, which
+// may be a plain string, an object or a list of either.
+func (ld jsonLD) name(key string) string {
+	switch v := ld[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return jsonLD(v).str("name")
+	case []any:
+		var names []string
+		for _, e := range v {
+			if n := (jsonLD{"v": e}).name("v"); n != "" {
+				names = append(names, n)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+
+	return ""
+}
+
+func (ld jsonLD) author() string {
+	return ld.name("author")
+}
+
+func (ld jsonLD) publisher() string {
+	return ld.name("publisher")
+}
+
+func (ld jsonLD) image() string {
+	switch v := ld["image"].(type) {
+	case map[string]any:
+		return jsonLD(v).str("url")
+	case []any:
+		if len(v) > 0 {
+			return (jsonLD{"image": v[0]}).image()
+		}
+	}
+
+	return ld.str("image")
+}
+
+// publishDate returns the first date that parses, from the meta tags, the
+// extra candidates and the first <time> element.
+func publishDate(root *node, tags map[string]string, extra ...string) time.Time {
+	candidates := append([]string{
+		tags["article:published_time"], tags["datepublished"], tags["date"],
+		tags["pubdate"], tags["dc.date.issued"],
+	}, extra...)
+	if t := root.first("time"); t != nil {
+		candidates = append(candidates, t.attrs["datetime"], t.innerText())
+	}
+
+	for _, s := range candidates {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC()
+			}
+		}
+	}
+
+	return time.Time{}
+}
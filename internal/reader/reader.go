@@ -0,0 +1,343 @@
+// Package reader extracts the main article of a page, with its metadata, for
+// a distraction-free reading view.
+package reader
+
+import (
+	"errors"
+	"html"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mateconpizza/gmweb/internal/models"
+)
+
+// WordsPerMinute is the reading speed used to estimate the reading time.
+const WordsPerMinute = 230
+
+// minParagraph is the length a text block needs to count as content.
+const minParagraph = 25
+
+var ErrNoContent = errors.New("no readable content found")
+
+var (
+	rePositive = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	reNegative = regexp.MustCompile(
+		`(?i)comment|meta|footer|footnote|sidebar|nav|share|social|related|promo|sponsor|banner|menu|widget|cookie|popup|subscribe|newsletter|breadcrumb`,
+	)
+	reByline = regexp.MustCompile(`(?i)byline|author|writtenby`)
+)
+
+// unlikelyTags never hold the article text.
+var unlikelyTags = set("nav", "aside", "footer", "header", "form", "button", "iframe",
+	"select", "input", "object", "embed", "canvas", "dialog", "menu")
+
+// allowedTags are kept in the extracted content; other elements are
+// replaced by their children.
+var allowedTags = set("p", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "li", "blockquote", "pre",
+	"code", "em", "strong", "b", "i", "a", "img", "figure", "figcaption", "br", "hr",
+	"table", "thead", "tbody", "tr", "td", "th", "dl", "dt", "dd", "sup", "sub")
+
+// dateLayouts are the publish date formats understood, most precise first.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// Extract returns the article of the page found at base.
+func Extract(page []byte, base *url.URL) (*models.Article, error) {
+	root := parse(string(page))
+	m := meta(root, base)
+
+	a := &models.Article{
+		Title:     m.Title,
+		Byline:    m.Author,
+		LeadImage: m.Image,
+		Excerpt:   m.Desc,
+		Published: m.Published,
+		Lang:      m.Lang,
+	}
+
+	top := topCandidate(root)
+	if top == nil {
+		return nil, ErrNoContent
+	}
+
+	var content, text strings.Builder
+	render(&content, top, base)
+	plainText(&text, top)
+
+	a.Content = content.String()
+	a.Text = collapse(text.String())
+	a.Words = len(strings.Fields(a.Text))
+	a.ReadingTime = int(math.Ceil(float64(a.Words) / WordsPerMinute))
+	if a.Words == 0 {
+		return nil, ErrNoContent
+	}
+
+	if a.Excerpt == "" {
+		first, _, _ := strings.Cut(a.Text, "\n")
+		a.Excerpt = shorten(first, 200)
+	}
+
+	return a, nil
+}
+
+// topCandidate scores the parents of the paragraphs and returns the element
+// most likely to hold the article.
+func topCandidate(root *node) *node {
+	scores := make(map[*node]float64)
+	var order []*node
+	add := func(n *node, s float64) {
+		if n == nil || n.tag == "#root" {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = classWeight(n)
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+
+	for _, p := range root.find(func(n *node) bool {
+		return n.tag == "p" || n.tag == "pre" || n.tag == "td" || n.tag == "blockquote"
+	}) {
+		if unlikely(p) {
+			continue
+		}
+		text := p.innerText()
+		if len(text) < minParagraph {
+			continue
+		}
+
+		s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		add(p.parent, s)
+		if p.parent != nil {
+			add(p.parent.parent, s/2)
+		}
+	}
+
+	var (
+		top  *node
+		best float64
+	)
+	for _, n := range order {
+		s := scores[n] * (1 - linkDensity(n))
+		if top == nil || s > best {
+			top, best = n, s
+		}
+	}
+
+	return top
+}
+
+// unlikely reports whether the node sits in an element that is not part of
+// the article.
+func unlikely(n *node) bool {
+	for ; n != nil; n = n.parent {
+		if unlikelyTags[n.tag] {
+			return true
+		}
+		if classWeight(n) < 0 && n.tag != "body" && n.tag != "html" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func classWeight(n *node) float64 {
+	var w float64
+	for _, s := range []string{n.attrs["class"], n.attrs["id"]} {
+		if s == "" {
+			continue
+		}
+		if reNegative.MatchString(s) {
+			w -= 25
+		}
+		if rePositive.MatchString(s) {
+			w += 25
+		}
+	}
+	if n.tag == "article" || n.tag == "main" {
+		w += 10
+	}
+
+	return w
+}
+
+func linkDensity(n *node) float64 {
+	total := len(n.innerText())
+	if total == 0 {
+		return 0
+	}
+
+	var links int
+	for _, a := range n.find(func(c *node) bool { return c.tag == "a" }) {
+		links += len(a.innerText())
+	}
+
+	return float64(links) / float64(total)
+}
+
+// dropped reports whether the element is left out of the content. The h1
+// is dropped as the view shows the title.
+func dropped(n *node) bool {
+	return rawTags[n.tag] || unlikelyTags[n.tag] || n.tag == "h1" ||
+		(classWeight(n) < 0 && n.tag != "article")
+}
+
+// render writes the allowed markup of n, dropping attributes other than
+// links and image sources.
+func render(sb *strings.Builder, n *node, base *url.URL) {
+	for _, c := range n.children {
+		switch {
+		case c.tag == "":
+			sb.WriteString(html.EscapeString(c.text))
+		case dropped(c):
+			continue
+		case c.tag == "img":
+			src := firstOf(c.attrs["data-src"], c.attrs["src"])
+			if u := resolve(base, src); u != "" {
+				sb.WriteString(`<img src="` + html.EscapeString(u) + `" alt="` + html.EscapeString(c.attrs["alt"]) + `" loading="lazy">`)
+			}
+		case c.tag == "a":
+			if u := resolve(base, c.attrs["href"]); u != "" {
+				sb.WriteString(`<a href="` + html.EscapeString(u) + `" rel="noopener noreferrer">`)
+				render(sb, c, base)
+				sb.WriteString("</a>")
+			} else {
+				render(sb, c, base)
+			}
+		case c.tag == "br" || c.tag == "hr":
+			sb.WriteString("<" + c.tag + ">")
+		case allowedTags[c.tag]:
+			sb.WriteString("<" + c.tag + ">")
+			render(sb, c, base)
+			sb.WriteString("</" + c.tag + ">")
+		default:
+			render(sb, c, base)
+		}
+	}
+}
+
+// blockTags end a line of the plain text.
+var blockTags = set("p", "div", "section", "article", "h2", "h3", "h4", "h5", "h6",
+	"li", "blockquote", "pre", "tr", "figure", "br", "dd", "dt")
+
+// plainText writes the text of n, ending a line after every block.
+func plainText(sb *strings.Builder, n *node) {
+	for _, c := range n.children {
+		switch {
+		case c.tag == "":
+			sb.WriteString(strings.ReplaceAll(c.text, "\n", " "))
+		case dropped(c):
+			continue
+		default:
+			plainText(sb, c)
+			if blockTags[c.tag] {
+				sb.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// metaTags returns the content of the <meta> tags by name or property.
+func metaTags(root *node) map[string]string {
+	meta := make(map[string]string)
+	for _, m := range root.find(func(n *node) bool { return n.tag == "meta" }) {
+		key := strings.ToLower(firstOf(m.attrs["property"], m.attrs["name"], m.attrs["itemprop"]))
+		val := strings.TrimSpace(m.attrs["content"])
+		if key != "" && val != "" && meta[key] == "" {
+			meta[key] = val
+		}
+	}
+
+	return meta
+}
+
+func titleText(root *node) string {
+	if t := root.first("title"); t != nil {
+		return strings.Join(strings.Fields(html.UnescapeString(t.text)), " ")
+	}
+	if h := root.first("h1"); h != nil {
+		return h.innerText()
+	}
+
+	return ""
+}
+
+func byline(root *node) string {
+	for _, n := range root.find(func(n *node) bool {
+		return n.attrs["rel"] == "author" || reByline.MatchString(n.attrs["class"]+" "+n.attrs["itemprop"])
+	}) {
+		if s := n.innerText(); s != "" && len(s) < 100 {
+			return strings.TrimPrefix(strings.TrimPrefix(s, "By "), "by ")
+		}
+	}
+
+	return ""
+}
+
+// resolve returns ref as an absolute http(s) URL, or an empty string.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !slices.Contains([]string{"http", "https"}, u.Scheme) {
+		return ""
+	}
+
+	return u.String()
+}
+
+// collapse squeezes the whitespace of every line and drops the empty ones.
+func collapse(s string) string {
+	var lines []string
+	for l := range strings.Lines(s) {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func firstOf(ss ...string) string {
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func shorten(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return strings.TrimSpace(string(r[:n])) + "…"
+}
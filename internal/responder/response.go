@@ -4,6 +4,15 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
+	"github.com/mateconpizza/gmweb/internal/dupes"
+	"github.com/mateconpizza/gmweb/internal/favicon"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/tags"
 )
 
 type ResponseData struct {
@@ -17,11 +26,18 @@ type ResponseError struct {
 }
 
 type FetchDataResponse struct {
-	Title      string   `json:"title"`
-	Desc       string   `json:"desc"`
-	Tags       []string `json:"tags"`
-	FaviconURL string   `json:"favicon_url"`
-	ArchiveURL string   `json:"archive_url"`
+	Title      string    `json:"title"`
+	Desc       string    `json:"desc"`
+	Tags       []string  `json:"tags"`
+	FaviconURL string    `json:"favicon_url"`
+	ArchiveURL string    `json:"archive_url"`
+	Image      string    `json:"image"`
+	SiteName   string    `json:"site_name"`
+	Type       string    `json:"type"`
+	Author     string    `json:"author"`
+	Canonical  string    `json:"canonical"`
+	Lang       string    `json:"lang"`
+	Published  time.Time `json:"published,omitzero"`
 }
 
 type FetchSnapshotResponse struct {
@@ -43,10 +59,160 @@ type QRCodeRequest struct {
 }
 
 type RepoStatsResponse struct {
-	Name      string `json:"name"`
-	Bookmarks int    `json:"bookmarks"`
-	Tags      int    `json:"tags"`
-	Favorites int    `json:"favorites"`
+	Name          string         `json:"name"`
+	Bookmarks     int            `json:"bookmarks"`
+	Tags          int            `json:"tags"`
+	Favorites     int            `json:"favorites"`
+	SchemaVersion int            `json:"schema_version"`
+	ReadOnly      bool           `json:"read_only"`
+	BypassProxy   bool           `json:"bypass_proxy"`
+	ArchiveSize   int64          `json:"archive_size"`
+	Favicons      *favicon.Stats `json:"favicons,omitempty"`
+	OpenedAt      time.Time      `json:"opened_at,omitzero"`
+	LastUsed      time.Time      `json:"last_used,omitzero"`
+}
+
+type MaintenanceResponse struct {
+	Name          string   `json:"name"`
+	Integrity     []string `json:"integrity"`
+	OK            bool     `json:"ok"`
+	Vacuumed      bool     `json:"vacuumed"`
+	SizeBefore    int64    `json:"size_before"`
+	SizeAfter     int64    `json:"size_after"`
+	SchemaVersion int      `json:"schema_version"`
+	Duration      string   `json:"duration"`
+}
+
+type HistoryResponse struct {
+	BookmarkID int              `json:"bookmark_id"`
+	Current    *models.Revision `json:"current"`
+	Revisions  []*HistoryEntry  `json:"revisions"`
+}
+
+// HistoryEntry is a revision and the changes made right after it.
+type HistoryEntry struct {
+	*models.Revision
+	Changes []models.FieldChange `json:"changes"`
+}
+
+type DeadLinksResponse struct {
+	Repo    string               `json:"repo"`
+	Total   int                  `json:"total"`
+	Dead    []*bookmark.Bookmark `json:"dead"`
+	LastRun *linkcheck.Status    `json:"last_run,omitempty"`
+}
+
+type RedirectsResponse struct {
+	Repo      string             `json:"repo"`
+	Total     int                `json:"total"`
+	Permanent int                `json:"permanent"`
+	Redirects []*models.Redirect `json:"redirects"`
+}
+
+// RedirectsRequest selects the redirects to accept or dismiss, either by
+// bookmark ID or all of them.
+type RedirectsRequest struct {
+	IDs           []int `json:"ids"`
+	All           bool  `json:"all"`
+	PermanentOnly bool  `json:"permanent_only"`
+}
+
+type RedirectsResult struct {
+	Accepted  []int          `json:"accepted,omitempty"`
+	Dismissed []int          `json:"dismissed,omitempty"`
+	Failed    map[int]string `json:"failed,omitempty"`
+}
+
+// TagsRequest describes a tag operation: the tags renamed, merged or
+// deleted, or the tags added to and removed from the selected bookmarks.
+// With Preview set nothing is saved.
+type TagsRequest struct {
+	Tags    []string `json:"tags"`
+	To      string   `json:"to"`
+	IDs     []int    `json:"ids"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+	Preview bool     `json:"preview"`
+}
+
+// TagsResult reports the bookmarks a tag operation changes and the use of
+// the tags involved before and after it.
+type TagsResult struct {
+	Preview  bool           `json:"preview"`
+	Affected int            `json:"affected"`
+	Changes  []*tags.Change `json:"changes"`
+	Before   map[string]int `json:"before"`
+	After    map[string]int `json:"after"`
+	Failed   map[int]string `json:"failed,omitempty"`
+}
+
+// BulkFilter selects the bookmarks shown by the list view: its tag, search
+// query, initial letter and sort.
+type BulkFilter struct {
+	Tag      string `json:"tag"`
+	Query    string `json:"query"`
+	Letter   string `json:"letter"`
+	FilterBy string `json:"filter_by"`
+}
+
+// BulkRequest describes an operation run on the selected bookmarks, or on
+// the ones matching the filter. Tags are the ones added or removed, Repo
+// the repository the bookmarks are moved to.
+type BulkRequest struct {
+	Op     string      `json:"op"`
+	IDs    []int       `json:"ids"`
+	Filter *BulkFilter `json:"filter"`
+	Tags   []string    `json:"tags"`
+	Repo   string      `json:"repo"`
+}
+
+// BulkItem is the outcome of a bulk operation on a bookmark. NewID is the
+// ID of a moved bookmark in its new repository.
+type BulkItem struct {
+	ID    int    `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	NewID int    `json:"new_id,omitempty"`
+}
+
+// BulkResult reports the outcome of a bulk operation on each bookmark.
+type BulkResult struct {
+	Op        string      `json:"op"`
+	Total     int         `json:"total"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Items     []*BulkItem `json:"items"`
+}
+
+// DuplicatesResponse lists the groups of bookmarks that look like the same
+// page.
+type DuplicatesResponse struct {
+	Groups []*dupes.Group `json:"groups"`
+}
+
+// MergeRequest selects the duplicates merged into one. Keep is the bookmark
+// retained; the richest one when zero.
+type MergeRequest struct {
+	IDs  []int `json:"ids"`
+	Keep int   `json:"keep"`
+}
+
+// MergeResult is the merged bookmark and the IDs of the ones deleted.
+type MergeResult struct {
+	Bookmark *bookmark.Bookmark `json:"bookmark"`
+	Deleted  []int              `json:"deleted"`
+}
+
+type SnapshotsResponse struct {
+	BookmarkID int                `json:"bookmark_id"`
+	ViewURL    string             `json:"view_url"`
+	Snapshots  []*models.Snapshot `json:"snapshots"`
+}
+
+type ArchiveResponse struct {
+	Repo  string `json:"repo"`
+	Used  int64  `json:"used"`
+	Quota int64  `json:"quota"` // bytes, zero or less is unlimited
 }
 
 type ImportResponse struct {
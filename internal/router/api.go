@@ -11,6 +11,9 @@ type API struct {
 	GenQR    func() string
 	GenQRPNG func() string
 	Shutdown func() string
+	Search   func() string
+
+	FaviconMetrics func() string
 
 	// Import endpoints
 	ImportHTML     func() string
@@ -18,15 +21,37 @@ type API struct {
 	ImportRepoGPG  func() string
 
 	// Repository endpoints
-	RepoList   func() string
-	RepoAll    func() string
-	RepoNew    func() string
-	RepoInfo   func() string
-	RepoDelete func() string
+	RepoList     func() string
+	RepoAll      func() string
+	RepoNew      func() string
+	RepoInfo     func() string
+	RepoDelete   func() string
+	RepoMaint    func() string
+	RepoReadOnly func() string
+	LinkCheck    func() string
+	RepoArchive  func() string
+	RepoProxy    func() string
+	RepoFavicons func() string
+	RepoAliases  func() string
+	RepoTagRules func() string
+	RepoURLRules func() string
 
 	// Bookmark endpoints
 	All                func() string
 	Tags               func() string
+	TagsRename         func() string
+	TagsMerge          func() string
+	TagsDelete         func() string
+	TagsRetag          func() string
+	TagsNormalize      func() string
+	SuggestTags        func() string
+	Bulk               func() string
+	Duplicates         func() string
+	DuplicatesMerge    func() string
+	DeadLinks          func() string
+	Redirects          func() string
+	RedirectsAccept    func() string
+	RedirectsDismiss   func() string
 	NewBookmark        func() string
 	InternetArchiveURL func() string
 	BookmarkByID       func(id string) string
@@ -36,6 +61,15 @@ type API struct {
 	DeleteBookmark     func(id string) string
 	CheckStatus        func(id string) string
 	Notes              func(id string) string
+	History            func(id string) string
+	RestoreRevision    func(id, rev string) string
+	Snapshots          func(id string) string
+	Snapshot           func(id, sid string) string
+	Wayback            func(id string) string
+	Article            func(id string) string
+	Metadata           func(id string) string
+	Favicon            func(id string) string
+	OriginalURL        func(id string) string
 }
 
 // NewAPIRoutes creates type-safe route functions for a given database.
@@ -60,6 +94,9 @@ func NewAPIRoutes(db string) *API {
 		GenQR:    func() string { return "/api/qr" },
 		GenQRPNG: func() string { return "/api/qr/png" },
 		Shutdown: func() string { return "/api/shutdown" },
+		Search:   func() string { return "/api/search" },
+
+		FaviconMetrics: func() string { return "/api/favicons" },
 
 		// Import endpoints
 		ImportHTML:     func() string { return basePath("/import/html") },
@@ -67,15 +104,37 @@ func NewAPIRoutes(db string) *API {
 		ImportRepoGPG:  func() string { return basePath("/import/repogpg") },
 
 		// Repository endpoints
-		RepoList:   func() string { return "/api/repo/list" },
-		RepoAll:    func() string { return "/api/repo/all" },
-		RepoNew:    func() string { return fmt.Sprintf("/api/%s/new", db) },
-		RepoInfo:   func() string { return basePath("/info") },
-		RepoDelete: func() string { return basePath("/delete") },
+		RepoList:     func() string { return "/api/repo/list" },
+		RepoAll:      func() string { return "/api/repo/all" },
+		RepoNew:      func() string { return fmt.Sprintf("/api/%s/new", db) },
+		RepoInfo:     func() string { return basePath("/info") },
+		RepoDelete:   func() string { return basePath("/delete") },
+		RepoMaint:    func() string { return basePath("/maintenance") },
+		RepoReadOnly: func() string { return basePath("/readonly") },
+		LinkCheck:    func() string { return basePath("/linkcheck") },
+		RepoArchive:  func() string { return basePath("/archive") },
+		RepoProxy:    func() string { return basePath("/proxy") },
+		RepoFavicons: func() string { return basePath("/favicons/refresh") },
+		RepoAliases:  func() string { return basePath("/tags/aliases") },
+		RepoTagRules: func() string { return basePath("/tags/rules") },
+		RepoURLRules: func() string { return basePath("/urls/rules") },
 
 		// Bookmark endpoints
 		All:                func() string { return bookmarksPath("/all") },
 		Tags:               func() string { return bookmarksPath("/tags") },
+		TagsRename:         func() string { return bookmarksPath("/tags/rename") },
+		TagsMerge:          func() string { return bookmarksPath("/tags/merge") },
+		TagsDelete:         func() string { return bookmarksPath("/tags/delete") },
+		TagsRetag:          func() string { return bookmarksPath("/tags/retag") },
+		TagsNormalize:      func() string { return bookmarksPath("/tags/normalize") },
+		SuggestTags:        func() string { return bookmarksPath("/suggest-tags") },
+		Bulk:               func() string { return bookmarksPath("/bulk") },
+		Duplicates:         func() string { return bookmarksPath("/duplicates") },
+		DuplicatesMerge:    func() string { return bookmarksPath("/duplicates/merge") },
+		DeadLinks:          func() string { return bookmarksPath("/dead") },
+		Redirects:          func() string { return bookmarksPath("/redirects") },
+		RedirectsAccept:    func() string { return bookmarksPath("/redirects/accept") },
+		RedirectsDismiss:   func() string { return bookmarksPath("/redirects/dismiss") },
 		NewBookmark:        func() string { return bookmarksPath("/new") },
 		InternetArchiveURL: func() string { return "/api/archive" },
 		BookmarkByID:       func(id string) string { return bookmarksPath("/" + id) },
@@ -85,5 +144,18 @@ func NewAPIRoutes(db string) *API {
 		DeleteBookmark:     func(id string) string { return bookmarksPath("/" + id + "/delete") },
 		CheckStatus:        func(id string) string { return bookmarksPath("/" + id + "/status") },
 		Notes:              func(id string) string { return bookmarksPath("/" + id + "/notes") },
+		History:            func(id string) string { return bookmarksPath("/" + id + "/history") },
+		RestoreRevision: func(id, rev string) string {
+			return bookmarksPath("/" + id + "/history/" + rev + "/restore")
+		},
+		Snapshots: func(id string) string { return bookmarksPath("/" + id + "/snapshots") },
+		Snapshot:  func(id, sid string) string { return bookmarksPath("/" + id + "/snapshots/" + sid) },
+		Wayback:   func(id string) string { return bookmarksPath("/" + id + "/wayback") },
+		Article:   func(id string) string { return bookmarksPath("/" + id + "/article") },
+		Metadata:  func(id string) string { return bookmarksPath("/" + id + "/metadata") },
+		Favicon:   func(id string) string { return bookmarksPath("/" + id + "/favicon") },
+		OriginalURL: func(id string) string {
+			return bookmarksPath("/" + id + "/original-url")
+		},
 	}
 }
@@ -25,11 +25,16 @@ func (w *WebRouter) New() string             { return w.bookmarksPath("/new") }
 func (w *WebRouter) NewFrame() string        { return w.bookmarksPath("/frame") }
 func (w *WebRouter) Detail(id string) string { return w.bookmarksPath("/detail/" + id) }
 func (w *WebRouter) View(id string) string   { return w.bookmarksPath("/view/" + id) }
+func (w *WebRouter) Reader(id string) string { return w.bookmarksPath("/read/" + id) }
 func (w *WebRouter) Edit(id string) string   { return w.bookmarksPath("/edit/" + id) }
 func (w *WebRouter) QRCode(id string) string { return w.bookmarksPath("/qr/" + id) }
 func (w *WebRouter) Import() string          { return w.bookmarksPath("/import") }
 func (w *WebRouter) Export() string          { return w.bookmarksPath("/export") }
+func (w *WebRouter) DeadLinks() string       { return w.bookmarksPath("/dead") }
+func (w *WebRouter) Redirects() string       { return w.bookmarksPath("/redirects") }
+func (w *WebRouter) Duplicates() string      { return w.bookmarksPath("/duplicates") }
 func (w *WebRouter) Settings() string        { return "/settings" }
+func (w *WebRouter) Search() string          { return "/search" }
 func (w *WebRouter) Favicon() string         { return ui.DefaultFaviconPath }
 func (w *WebRouter) bookmarksPath(path string) string {
 	return fmt.Sprintf("/web/%s/bookmarks%s", w.db, path)
@@ -0,0 +1,285 @@
+// Package search runs a query across several repositories and merges the
+// results into a single ranked list.
+package search
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
+	"github.com/mateconpizza/gmweb/internal/helpers"
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/router"
+)
+
+const (
+	// DefaultBudget is the time given to all repositories to answer.
+	DefaultBudget = 3 * time.Second
+
+	// DefaultLimit is the number of results returned when none is given.
+	DefaultLimit = 100
+
+	// MaxLimit caps the number of results returned.
+	MaxLimit = 500
+)
+
+// Query holds the search parameters, with the same semantics as the filters
+// of the bookmark list.
+type Query struct {
+	Query    string
+	Tag      string
+	Letter   string
+	FilterBy string
+	Limit    int
+	Budget   time.Duration
+}
+
+// Hit is a bookmark matching the query, labelled with its repository.
+type Hit struct {
+	Repo      string             `json:"repo"`
+	Score     float64            `json:"score"`
+	DetailURL string             `json:"detail_url"`
+	InArticle bool               `json:"in_article,omitempty"` // the extracted article matches
+	Bookmark  *bookmark.Bookmark `json:"bookmark"`
+}
+
+// Result holds the merged hits and the outcome of each repository.
+type Result struct {
+	Hits     []*Hit            `json:"results"`
+	Total    int               `json:"total"`
+	Searched []string          `json:"searched"`
+	TimedOut []string          `json:"timed_out,omitempty"`
+	Failed   map[string]string `json:"failed,omitempty"`
+	Duration string            `json:"duration"`
+}
+
+// Loader returns the repository with the given name. It is closed once
+// used, which releases it to the registry.
+type Loader func(name string) (models.Repo, error)
+
+type repoResult struct {
+	name     string
+	bs       []*bookmark.Bookmark
+	articles map[int]bool // bookmarks whose article matches the query
+	err      error
+}
+
+// Run searches the given repositories concurrently.
+//
+// Repositories that do not answer within the query budget are reported as
+// timed out and their results are ignored.
+func Run(ctx context.Context, load Loader, repos []string, q Query) *Result {
+	start := time.Now()
+	q = q.withDefaults()
+
+	ctx, cancel := context.WithTimeout(ctx, q.Budget)
+	defer cancel()
+
+	ch := make(chan repoResult, len(repos))
+	var wg sync.WaitGroup
+	for _, name := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch <- searchRepo(ctx, load, name, q)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	res := &Result{Failed: map[string]string{}}
+	pending := make(map[string]bool, len(repos))
+	for _, name := range repos {
+		pending[name] = true
+	}
+
+	var hits []*Hit
+collect:
+	for {
+		select {
+		case rr, ok := <-ch:
+			if !ok {
+				break collect
+			}
+			delete(pending, rr.name)
+			if rr.err != nil {
+				res.Failed[rr.name] = rr.err.Error()
+				continue
+			}
+			res.Searched = append(res.Searched, rr.name)
+			for _, b := range rr.bs {
+				hits = append(hits, newHit(rr.name, b, q.Query, rr.articles[b.ID]))
+			}
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	for name := range pending {
+		res.TimedOut = append(res.TimedOut, name)
+	}
+	slices.Sort(res.Searched)
+	slices.Sort(res.TimedOut)
+
+	hits = rank(hits, q.FilterBy)
+	res.Total = len(hits)
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	res.Hits = hits
+	res.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	return res
+}
+
+func (q Query) withDefaults() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	q.Limit = min(q.Limit, MaxLimit)
+	if q.Budget <= 0 {
+		q.Budget = DefaultBudget
+	}
+
+	return q
+}
+
+func searchRepo(ctx context.Context, load Loader, name string, q Query) repoResult {
+	repo, err := load(name)
+	if err != nil {
+		return repoResult{name: name, err: err}
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(ctx)
+	if err != nil {
+		return repoResult{name: name, err: err}
+	}
+
+	matched := helpers.ApplyFiltersAndSorting(q.Tag, q.Query, q.Letter, q.FilterBy, bs)
+	if strings.TrimSpace(q.Query) == "" {
+		return repoResult{name: name, bs: matched}
+	}
+
+	// bookmarks whose extracted article matches are added, still subject to
+	// the other filters.
+	ids, err := repo.SearchArticles(ctx, q.Query)
+	if err != nil {
+		return repoResult{name: name, err: err}
+	}
+	articles := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		articles[id] = true
+	}
+
+	seen := make(map[int]bool, len(matched))
+	for _, b := range matched {
+		seen[b.ID] = true
+	}
+	extra := slices.DeleteFunc(slices.Clone(bs), func(b *bookmark.Bookmark) bool {
+		return seen[b.ID] || !articles[b.ID]
+	})
+	if len(extra) > 0 {
+		matched = append(matched, helpers.ApplyFiltersAndSorting(q.Tag, "", q.Letter, q.FilterBy, extra)...)
+	}
+
+	return repoResult{name: name, bs: matched, articles: articles}
+}
+
+func newHit(repo string, b *bookmark.Bookmark, query string, inArticle bool) *Hit {
+	score := Score(b, query)
+	if inArticle {
+		score++
+	}
+
+	return &Hit{
+		Repo:      repo,
+		Score:     score,
+		DetailURL: router.NewWebRoutes(repo).Detail(strconv.Itoa(b.ID)),
+		InArticle: inArticle,
+		Bookmark:  b,
+	}
+}
+
+// rank orders the merged hits. With a filter, the filter order is kept
+// across repositories; otherwise hits are ordered by score.
+func rank(hits []*Hit, filterBy string) []*Hit {
+	if filterBy != "" {
+		byBookmark := make(map[*bookmark.Bookmark]*Hit, len(hits))
+		bs := make([]*bookmark.Bookmark, 0, len(hits))
+		for _, h := range hits {
+			byBookmark[h.Bookmark] = h
+			bs = append(bs, h.Bookmark)
+		}
+
+		bs = helpers.SortBy(filterBy, bs)
+		ranked := make([]*Hit, 0, len(bs))
+		for _, b := range bs {
+			ranked = append(ranked, byBookmark[b])
+		}
+
+		return ranked
+	}
+
+	slices.SortStableFunc(hits, func(a, b *Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Bookmark.CreatedAt, a.Bookmark.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Repo, b.Repo)
+	})
+
+	return hits
+}
+
+// Score returns the relevance of a bookmark for the given query. Matches in
+// the title weigh more than in tags, URL and description; favorites and
+// frequently visited bookmarks get a small boost.
+func Score(b *bookmark.Bookmark, query string) float64 {
+	var score float64
+
+	title := strings.ToLower(b.Title)
+	url := strings.ToLower(b.URL)
+	desc := strings.ToLower(b.Desc)
+	rawTags := strings.ToLower(b.Tags)
+	tags := strings.Split(rawTags, ",")
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, word := range strings.Fields(query) {
+		if strings.Contains(title, word) {
+			score += 4
+		}
+		if slices.Contains(tags, word) {
+			score += 3
+		} else if strings.Contains(rawTags, word) {
+			score++
+		}
+		if strings.Contains(url, word) {
+			score += 2
+		}
+		if strings.Contains(desc, word) {
+			score++
+		}
+	}
+
+	if query != "" && strings.Contains(title, query) {
+		score += 3
+	}
+	if b.Favorite {
+		score++
+	}
+	score += math.Log1p(float64(b.VisitCount)) / 2
+
+	return math.Round(score*100) / 100
+}
@@ -0,0 +1,109 @@
+package tags
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rules controls the tags stored in a repository. The zero value only
+// trims, dedupes and sorts them.
+type Rules struct {
+	// Lowercase stores every tag in lowercase.
+	Lowercase bool `json:"lowercase,omitempty"`
+
+	// MaxLength truncates the tags to this many characters. Zero is
+	// unlimited.
+	MaxLength int `json:"max_length,omitempty"`
+
+	// Charset lists the characters allowed besides letters and digits,
+	// such as "-_/". Others are replaced with a dash. Empty allows any.
+	Charset string `json:"charset,omitempty"`
+}
+
+// Validate checks that the rules can produce storable tags.
+func (r *Rules) Validate() error {
+	if r.MaxLength < 0 {
+		return fmt.Errorf("%w: negative max length", ErrInvalid)
+	}
+	if strings.ContainsFunc(r.Charset, func(c rune) bool { return c == ',' || unicode.IsSpace(c) }) {
+		return fmt.Errorf("%w: charset cannot allow commas or spaces", ErrInvalid)
+	}
+
+	return nil
+}
+
+// tag applies the rules to a single tag, returning "" when nothing is left.
+func (r *Rules) tag(t string) string {
+	t = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#"))
+	if r.Lowercase {
+		t = strings.ToLower(t)
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, c := range t {
+		if r.MaxLength > 0 && n == r.MaxLength {
+			break
+		}
+		switch {
+		case unicode.IsSpace(c), c == ',':
+			c = '-'
+		case r.Charset != "" && !unicode.IsLetter(c) && !unicode.IsDigit(c) && !strings.ContainsRune(r.Charset, c):
+			c = '-'
+		}
+		sb.WriteRune(c)
+		n++
+	}
+
+	// drop the empty levels left by the replacements and the truncation.
+	levels := strings.Split(strings.Trim(sb.String(), "-"+Sep), Sep)
+	out := levels[:0]
+	for _, l := range levels {
+		if l = strings.Trim(l, "-"); l != "" {
+			out = append(out, l)
+		}
+	}
+
+	return strings.Join(out, Sep)
+}
+
+// Normalizer is the single pipeline the tags written to a repository go
+// through: its rules, then its aliases, deduped and sorted.
+type Normalizer struct {
+	Rules   Rules
+	Aliases Aliases
+}
+
+// Normalize returns the stored form of the comma-separated tags.
+func (n *Normalizer) Normalize(s string) string {
+	ts := make([]string, 0, strings.Count(s, ",")+1)
+	for _, t := range Parse(s) {
+		t = n.Rules.tag(n.Aliases.Resolve(n.Rules.tag(t)))
+		if t != "" && !contains(ts, t) {
+			ts = append(ts, t)
+		}
+	}
+
+	return Join(ts)
+}
+
+// Edit normalizes the tags the edit changes, leaving untouched the
+// bookmarks it does not apply to.
+func (n *Normalizer) Edit(edit Edit) Edit {
+	return func(ts []string) []string {
+		out := edit(ts)
+		if Join(out) == Join(ts) {
+			return out
+		}
+
+		return Parse(n.Normalize(strings.Join(out, ",")))
+	}
+}
+
+// All normalizes the tags of every bookmark.
+func (n *Normalizer) All() Edit {
+	return func(ts []string) []string {
+		return Parse(n.Normalize(strings.Join(ts, ",")))
+	}
+}
@@ -0,0 +1,163 @@
+package tags
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+// MaxSuggestions is the number of tags Suggest returns by default.
+const MaxSuggestions = 8
+
+// Weights of each source of a suggestion. A tag every bookmark of the
+// domain uses outweighs a word of the title.
+const (
+	weightDomain = 3.0
+	weightToken  = 2.0
+	weightCooc   = 1.0
+)
+
+// Page describes the bookmark being saved.
+type Page struct {
+	URL   string
+	Title string
+	Desc  string
+	Tags  []string // already chosen, never suggested
+}
+
+// Suggestion is a tag of the repository proposed for a page.
+type Suggestion struct {
+	Tag     string   `json:"tag"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"` // domain, title or related
+}
+
+// Suggest proposes up to limit existing tags for the page, from:
+//   - the tags of the bookmarks sharing its domain, by how many use them;
+//   - the tags, or a level of them, found among the words of its title and
+//     description;
+//   - the tags most often used along with the ones already chosen.
+func Suggest(bs []*bookmark.Bookmark, p *Page, limit int) []*Suggestion {
+	if limit <= 0 {
+		limit = MaxSuggestions
+	}
+
+	scores := map[string]*Suggestion{}
+	add := func(t string, score float64, reason string) {
+		if contains(p.Tags, t) {
+			return
+		}
+		key := strings.ToLower(t)
+		s, ok := scores[key]
+		if !ok {
+			s = &Suggestion{Tag: t}
+			scores[key] = s
+		}
+		s.Score += score
+		if !slices.Contains(s.Reasons, reason) {
+			s.Reasons = append(s.Reasons, reason)
+		}
+	}
+
+	host := domain(p.URL)
+	words := tokens(p.Title + " " + p.Desc)
+	var sameHost []*bookmark.Bookmark
+	var related []*bookmark.Bookmark
+	seen := map[string]bool{}
+
+	for _, b := range bs {
+		ts := Parse(b.Tags)
+		if host != "" && domain(b.URL) == host && b.URL != p.URL {
+			sameHost = append(sameHost, b)
+		}
+		if slices.ContainsFunc(ts, func(t string) bool { return contains(p.Tags, t) }) {
+			related = append(related, b)
+		}
+		for _, t := range ts {
+			key := strings.ToLower(t)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if matchesWords(t, words) {
+				add(t, weightToken, "title")
+			}
+		}
+	}
+
+	for t, n := range frequency(sameHost) {
+		add(t, weightDomain*float64(n)/float64(len(sameHost)), "domain")
+	}
+	for t, n := range frequency(related) {
+		add(t, weightCooc*float64(n)/float64(len(related)), "related")
+	}
+
+	out := make([]*Suggestion, 0, len(scores))
+	for _, s := range scores {
+		out = append(out, s)
+	}
+	slices.SortFunc(out, func(a, b *Suggestion) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return out[:min(limit, len(out))]
+}
+
+// frequency counts the bookmarks using each tag.
+func frequency(bs []*bookmark.Bookmark) map[string]int {
+	n := map[string]int{}
+	for _, b := range bs {
+		for _, t := range Parse(b.Tags) {
+			n[t]++
+		}
+	}
+
+	return n
+}
+
+// domain returns the host of the URL without its www. prefix.
+func domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// tokens returns the lowercase words of s, skipping the short ones.
+func tokens(s string) map[string]bool {
+	words := map[string]bool{}
+	for w := range strings.FieldsFuncSeq(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	}) {
+		if len(w) > 1 {
+			words[w] = true
+		}
+	}
+
+	return words
+}
+
+// matchesWords reports whether the tag, or any of its levels, is one of the
+// words.
+func matchesWords(t string, words map[string]bool) bool {
+	t = strings.ToLower(t)
+	if words[t] {
+		return true
+	}
+	for level := range strings.SplitSeq(t, Sep) {
+		if len(level) > 1 && words[level] {
+			return true
+		}
+	}
+
+	return false
+}
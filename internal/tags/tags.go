@@ -0,0 +1,165 @@
+// Package tags edits the tags of the bookmarks, stored as a comma-separated
+// string, across a whole repository. Tags may be hierarchical, with levels
+// separated by Sep, and rewritten by alias rules.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mateconpizza/gm/pkg/bookmark"
+)
+
+var (
+	ErrEmpty   = errors.New("empty tag")
+	ErrInvalid = errors.New("invalid tag")
+)
+
+// Parse splits the tags of a bookmark, dropping the empty ones and those
+// repeated regardless of case.
+func Parse(s string) []string {
+	var ts []string
+	for t := range strings.SplitSeq(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || contains(ts, t) {
+			continue
+		}
+		ts = append(ts, t)
+	}
+
+	return ts
+}
+
+// Join returns the tags sorted and comma-separated, as they are stored.
+func Join(ts []string) string {
+	ts = slices.Clone(ts)
+	slices.Sort(ts)
+	return strings.Join(ts, ",")
+}
+
+// Validate checks that the tag can be stored: not empty, without commas
+// nor spaces, and without empty levels.
+func Validate(t string) error {
+	if strings.TrimSpace(t) == "" {
+		return ErrEmpty
+	}
+	if strings.ContainsFunc(t, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		return fmt.Errorf("%w: %q", ErrInvalid, t)
+	}
+	if slices.Contains(strings.Split(t, Sep), "") {
+		return fmt.Errorf("%w: empty level in %q", ErrInvalid, t)
+	}
+
+	return nil
+}
+
+// Edit returns the new tags of a bookmark.
+type Edit func(ts []string) []string
+
+// Replace replaces the tags from with the tag to, which renames a single
+// tag or merges several into one.
+func Replace(from []string, to string) Edit {
+	return func(ts []string) []string {
+		out := make([]string, 0, len(ts))
+		found := false
+		for _, t := range ts {
+			if contains(from, t) {
+				found = true
+				continue
+			}
+			out = append(out, t)
+		}
+		if found && !contains(out, to) {
+			out = append(out, to)
+		}
+
+		return out
+	}
+}
+
+// Remove removes the tags.
+func Remove(rm ...string) Edit {
+	return Retag(nil, rm)
+}
+
+// Retag adds and removes tags.
+func Retag(add, rm []string) Edit {
+	return func(ts []string) []string {
+		out := make([]string, 0, len(ts)+len(add))
+		for _, t := range ts {
+			if !contains(rm, t) {
+				out = append(out, t)
+			}
+		}
+		for _, t := range add {
+			if !contains(out, t) {
+				out = append(out, t)
+			}
+		}
+
+		return out
+	}
+}
+
+// Change is the edit of the tags of a bookmark.
+type Change struct {
+	Bookmark *bookmark.Bookmark `json:"-"`
+	ID       int                `json:"id"`
+	Before   string             `json:"before"`
+	After    string             `json:"after"`
+}
+
+// Plan returns the changes the edit makes to the bookmarks, leaving them
+// untouched. Bookmarks whose tags do not change are skipped.
+func Plan(bs []*bookmark.Bookmark, edit Edit) []*Change {
+	var cs []*Change
+	for _, b := range bs {
+		before := Parse(b.Tags)
+		after := edit(slices.Clone(before))
+		if Join(before) == Join(after) {
+			continue
+		}
+		cs = append(cs, &Change{Bookmark: b, ID: b.ID, Before: Join(before), After: Join(after)})
+	}
+
+	return cs
+}
+
+// Count returns the number of bookmarks using each of the tags, before and
+// after the changes. A tag given twice is counted once.
+func Count(cs []*Change, ts []string, all []*bookmark.Bookmark) (before, after map[string]int) {
+	ts = Parse(strings.Join(ts, ","))
+	before = make(map[string]int, len(ts))
+	after = make(map[string]int, len(ts))
+	changed := make(map[int]*Change, len(cs))
+	for _, c := range cs {
+		changed[c.ID] = c
+	}
+
+	for _, b := range all {
+		now := Parse(b.Tags)
+		next := now
+		if c, ok := changed[b.ID]; ok {
+			next = Parse(c.After)
+		}
+		for _, t := range ts {
+			if contains(now, t) {
+				before[t]++
+			}
+			if contains(next, t) {
+				after[t]++
+			}
+		}
+	}
+
+	return before, after
+}
+
+// contains reports whether the tag is in ts, ignoring case as the filters
+// do.
+func contains(ts []string, t string) bool {
+	return slices.ContainsFunc(ts, func(s string) bool { return strings.EqualFold(s, t) })
+}
@@ -0,0 +1,186 @@
+package tags
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAliasesResolve(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewAliases(map[string]string{
+		"golang":     "go",
+		"golang/web": "web/go",
+		"JS":         "javascript",
+		"Ⱥ":          "a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "tag", in: "golang", want: "go"},
+		{name: "ignoring case", in: "GoLang", want: "go"},
+		{name: "keys lowercased", in: "js", want: "javascript"},
+		{name: "child path", in: "golang/cli", want: "go/cli"},
+		{name: "child path keeps its case", in: "golang/CLI", want: "go/CLI"},
+		{name: "most specific wins", in: "golang/web", want: "web/go"},
+		{name: "child of the most specific", in: "golang/web/http", want: "web/go/http"},
+		{name: "prefix is not a parent", in: "golangci", want: "golangci"},
+		{name: "parent is not aliased", in: "lang/golang", want: "lang/golang"},
+		{name: "no rule", in: "rust", want: "rust"},
+		// Ⱥ lowercases to a longer rune, so the tag is matched as given.
+		{name: "case-sensitive fallback", in: "golang/Ⱥ", want: "go/Ⱥ"},
+		{name: "no case folding in the fallback", in: "GOLANG/Ⱥ", want: "GOLANG/Ⱥ"},
+		{name: "key lowercased to a longer rune", in: "ⱥ", want: "a"},
+	}
+
+	//nolint:paralleltest //test
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Resolve(tt.in); got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAliases(t *testing.T) {
+	t.Parallel()
+
+	for _, rules := range []map[string]string{
+		{"golang": "go lang"},
+		{"": "go"},
+		{"golang": "go//web"},
+		{"Go": "go"},
+	} {
+		if _, err := NewAliases(rules); !errors.Is(err, ErrInvalid) && !errors.Is(err, ErrEmpty) {
+			t.Errorf("NewAliases(%v): expected an invalid rule, got %v", rules, err)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tag, filter string
+		want        bool
+	}{
+		{"go", "go", true},
+		{"Go", "gO", true},
+		{"go/web", "go", true},
+		{"go/web/http", "GO/Web", true},
+		{"golang", "go", false},
+		{"go", "go/web", false},
+		{"lang/go", "go", false},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.tag, tt.filter); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.tag, tt.filter, got, tt.want)
+		}
+	}
+}
+
+// flatten lists the paths of the tree, depth first.
+func flatten(ns []*Node) []string {
+	var paths []string
+	for _, n := range ns {
+		paths = append(paths, n.Path)
+		paths = append(paths, flatten(n.Children)...)
+	}
+
+	return paths
+}
+
+func TestTree(t *testing.T) {
+	t.Parallel()
+
+	roots := Tree([]string{"lang/go", "web", "Lang/Rust", "lang/go/cli", "/db/", "Apps"})
+	// a node keeps the path of the tag that created it.
+	want := []string{"Apps", "db", "lang", "lang/go", "lang/go/cli", "Lang/Rust", "web"}
+	if got := flatten(roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tree paths = %v, want %v", got, want)
+	}
+	if len(roots) != 4 || roots[2].Name != "lang" || len(roots[2].Children) != 2 {
+		t.Errorf("expected the lang levels merged regardless of case, got %+v", roots)
+	}
+	if n := roots[2].Children[0]; n.Name != "go" || n.Path != "lang/go" || n.Children[0].Name != "cli" {
+		t.Errorf("unexpected lang/go node %+v", n)
+	}
+	if Tree(nil) != nil {
+		t.Error("expected no nodes without tags")
+	}
+}
+
+func TestRulesTag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rules Rules
+		in    string
+		want  string
+	}{
+		{name: "zero rules trim", in: "  #Go  ", want: "Go"},
+		{name: "lowercase", rules: Rules{Lowercase: true}, in: "GoLang", want: "golang"},
+		{name: "spaces replaced", in: "go lang", want: "go-lang"},
+		{name: "charset replacement", rules: Rules{Charset: "-/"}, in: "c++/std:io", want: "c/std-io"},
+		{name: "charset keeps letters", rules: Rules{Charset: "_"}, in: "café_2", want: "café_2"},
+		{name: "truncation counts runes", rules: Rules{MaxLength: 4}, in: "éèêëa", want: "éèêë"},
+		{name: "truncation leaving an empty level", rules: Rules{MaxLength: 3}, in: "go/web", want: "go"},
+		{name: "truncation leaving a dash", rules: Rules{MaxLength: 3}, in: "go lang", want: "go"},
+		{name: "replacements leaving empty levels", rules: Rules{Charset: "/"}, in: "go/++/web", want: "go/web"},
+		{name: "leading separator", in: "/go/", want: "go"},
+		{name: "nothing left", rules: Rules{Charset: "-"}, in: "+++", want: ""},
+	}
+
+	//nolint:paralleltest //test
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.tag(tt.in); got != tt.want {
+				t.Errorf("tag(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewAliases(map[string]string{"golang": "go", "js": "javascript"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Normalizer{Rules: Rules{Lowercase: true, Charset: "-/"}, Aliases: a}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"Web,go", "go,web"},
+		{"Golang, GO ,go", "go"},
+		{"golang/Web,JS", "go/web,javascript"},
+		{"#cli, ,c++", "c,cli"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	edit := n.Edit(Replace([]string{"web"}, "Frontend"))
+	if got := edit([]string{"Go", "web"}); !reflect.DeepEqual(got, []string{"frontend", "go"}) {
+		t.Errorf("expected an edited bookmark normalized, got %v", got)
+	}
+	if got := edit([]string{"Go"}); !reflect.DeepEqual(got, []string{"Go"}) {
+		t.Errorf("expected a bookmark the edit skips left as is, got %v", got)
+	}
+}
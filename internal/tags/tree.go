@@ -0,0 +1,105 @@
+package tags
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sep separates the levels of a hierarchical tag, as in lang/go.
+const Sep = "/"
+
+// Matches reports whether the tag t is selected by the filter: the tag
+// itself or any of its children, ignoring case.
+func Matches(t, filter string) bool {
+	t, filter = strings.ToLower(t), strings.ToLower(filter)
+	return t == filter || strings.HasPrefix(t, filter+Sep)
+}
+
+// Node is a level of the tag tree. Path is the full tag it stands for,
+// which may only exist as the parent of other tags.
+type Node struct {
+	Name     string
+	Path     string
+	Children []*Node
+}
+
+// Tree arranges the tags by their levels, each level sorted by name.
+func Tree(ts []string) []*Node {
+	var roots []*Node
+	for _, t := range ts {
+		level := &roots
+		var path string
+		for name := range strings.SplitSeq(t, Sep) {
+			if name == "" {
+				continue
+			}
+			if path != "" {
+				path += Sep
+			}
+			path += name
+
+			i := slices.IndexFunc(*level, func(n *Node) bool { return strings.EqualFold(n.Path, path) })
+			if i < 0 {
+				*level = append(*level, &Node{Name: name, Path: path})
+				i = len(*level) - 1
+			}
+			level = &(*level)[i].Children
+		}
+	}
+	sortTree(roots)
+
+	return roots
+}
+
+func sortTree(ns []*Node) {
+	slices.SortFunc(ns, func(a, b *Node) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+	for _, n := range ns {
+		sortTree(n.Children)
+	}
+}
+
+// Aliases maps tags to the one used in their place, such as golang to go.
+// Keys are lowercase.
+type Aliases map[string]string
+
+// NewAliases checks the rules, mapping each tag to the one replacing it,
+// and lowercases their keys.
+func NewAliases(rules map[string]string) (Aliases, error) {
+	a := make(Aliases, len(rules))
+	for from, to := range rules {
+		if err := Validate(from); err != nil {
+			return nil, fmt.Errorf("alias %q: %w", from, err)
+		}
+		if err := Validate(to); err != nil {
+			return nil, fmt.Errorf("alias %q: %w", from, err)
+		}
+		if strings.EqualFold(from, to) {
+			return nil, fmt.Errorf("%w: alias %q maps to itself", ErrInvalid, from)
+		}
+		a[strings.ToLower(from)] = to
+	}
+
+	return a, nil
+}
+
+// Resolve returns the tag the alias rules map t to. A rule also applies to
+// the children of the tag, so golang/web becomes go/web; the most specific
+// rule wins.
+func (a Aliases) Resolve(t string) string {
+	lower := strings.ToLower(t)
+	if len(lower) != len(t) {
+		lower = t // keep the byte offsets of t valid
+	}
+	match := ""
+	for from := range a {
+		if (lower == from || strings.HasPrefix(lower, from+Sep)) && len(from) > len(match) {
+			match = from
+		}
+	}
+	if match == "" {
+		return t
+	}
+
+	return a[match] + t[len(match):]
+}
@@ -0,0 +1,110 @@
+package urlclean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidRule is returned for a parameter or host that cannot be matched.
+var ErrInvalidRule = errors.New("invalid url rule")
+
+// shorteners are the hosts of the known link shorteners.
+var shorteners = []string{
+	"bit.ly", "buff.ly", "cutt.ly", "dlvr.it", "goo.gl", "is.gd", "lnkd.in",
+	"ow.ly", "rebrand.ly", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+}
+
+// Rules controls how the URLs saved to a repository are cleaned. The zero
+// value keeps them as given.
+type Rules struct {
+	// StripTracking removes the tracking parameters, such as utm_source.
+	StripTracking bool `json:"strip_tracking,omitempty"`
+
+	// StripParams lists more query parameters to remove, such as ref.
+	StripParams []string `json:"strip_params,omitempty"`
+
+	// ForceHTTPS rewrites http URLs to https.
+	ForceHTTPS bool `json:"force_https,omitempty"`
+
+	// ResolveShorteners replaces the links of the known shorteners, and of
+	// the hosts in Shorteners, with the URL they redirect to.
+	ResolveShorteners bool     `json:"resolve_shorteners,omitempty"`
+	Shorteners        []string `json:"shorteners,omitempty"`
+}
+
+// Validate checks the parameters and hosts listed by the rules.
+func (r *Rules) Validate() error {
+	for _, p := range r.StripParams {
+		if p == "" || strings.ContainsAny(p, "=&?#") || strings.ContainsFunc(p, unicode.IsSpace) {
+			return fmt.Errorf("%w: parameter %q", ErrInvalidRule, p)
+		}
+	}
+	for _, h := range r.Shorteners {
+		if h == "" || strings.ContainsAny(h, "/?#:@") || strings.ContainsFunc(h, unicode.IsSpace) {
+			return fmt.Errorf("%w: host %q", ErrInvalidRule, h)
+		}
+	}
+
+	return nil
+}
+
+// IsShortener reports whether the host is a link shortener resolved by the
+// rules.
+func (r *Rules) IsShortener(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	return slices.Contains(shorteners, host) ||
+		slices.ContainsFunc(r.Shorteners, func(h string) bool { return strings.EqualFold(h, host) })
+}
+
+// Resolver returns the URL a short link redirects to.
+type Resolver func(ctx context.Context, rawURL string) (string, error)
+
+// Clean returns the URL cleaned by the rules. A short link that cannot be
+// resolved is kept, otherwise cleaned, and returned along with the error.
+// A URL that cannot be parsed is returned as given.
+func (r *Rules) Clean(ctx context.Context, rawURL string, resolve Resolver) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL, nil
+	}
+
+	var resolveErr error
+	if r.ResolveShorteners && resolve != nil && r.IsShortener(u.Hostname()) {
+		final, err := resolve(ctx, u.String())
+		switch {
+		case err != nil:
+			resolveErr = fmt.Errorf("resolving %q: %w", rawURL, err)
+		default:
+			if fu, err := url.Parse(final); err == nil && fu.Host != "" {
+				u = fu
+			}
+		}
+	}
+
+	if r.ForceHTTPS && strings.EqualFold(u.Scheme, "http") {
+		u.Scheme = "https"
+	}
+
+	if u.RawQuery != "" && (r.StripTracking || len(r.StripParams) > 0) {
+		q := u.Query()
+		stripped := false
+		for k := range q {
+			if (r.StripTracking && IsTracking(k)) ||
+				slices.ContainsFunc(r.StripParams, func(p string) bool { return strings.EqualFold(p, k) }) {
+				q.Del(k)
+				stripped = true
+			}
+		}
+		// re-encoding sorts the parameters; keep the query as given otherwise.
+		if stripped {
+			u.RawQuery = q.Encode()
+		}
+	}
+
+	return u.String(), resolveErr
+}
@@ -0,0 +1,85 @@
+// Package urlclean normalizes the bookmarked URLs: it strips the tracking
+// parameters added by newsletters and ads, and reduces a URL to a canonical
+// form shared by the addresses of the same page.
+package urlclean
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are the query parameters that identify a campaign or a
+// click, never the page. Parameters starting with utm_ are tracking too.
+var trackingParams = map[string]bool{
+	"fbclid":      true,
+	"gclid":       true,
+	"dclid":       true,
+	"msclkid":     true,
+	"yclid":       true,
+	"igshid":      true,
+	"mc_cid":      true,
+	"mc_eid":      true,
+	"_hsenc":      true,
+	"_hsmi":       true,
+	"mkt_tok":     true,
+	"oly_anon_id": true,
+	"oly_enc_id":  true,
+	"vero_id":     true,
+}
+
+// IsTracking reports whether the query parameter only tracks the visit.
+func IsTracking(param string) bool {
+	param = strings.ToLower(param)
+	return strings.HasPrefix(param, "utm_") || trackingParams[param]
+}
+
+// StripTracking removes the tracking parameters from the query.
+func StripTracking(q url.Values) {
+	for k := range q {
+		if IsTracking(k) {
+			q.Del(k)
+		}
+	}
+}
+
+// defaultPorts maps the schemes to the port they imply.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonical returns the form shared by the URLs of the same page:
+//   - lowercase scheme and host, without www. or the default port;
+//   - no tracking parameters, the others sorted;
+//   - no fragment, unless it is a route such as #!/page or #/page;
+//   - no trailing slash.
+//
+// A URL that cannot be parsed is returned trimmed.
+func Canonical(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(rawURL, "/")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+
+	q := u.Query()
+	StripTracking(q)
+	u.RawQuery = q.Encode()
+
+	if !strings.HasPrefix(u.Fragment, "!") && !strings.HasPrefix(u.Fragment, "/") {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	return u.String()
+}
@@ -0,0 +1,201 @@
+// Package wayback looks up and requests snapshots of the bookmarked pages in
+// the Internet Archive Wayback Machine.
+package wayback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL  = "https://web.archive.org"
+	DefaultTimeout  = 90 * time.Second // saving a page can take a while
+	DefaultInterval = 5 * time.Second  // minimum time between requests
+)
+
+var (
+	ErrNotFound    = errors.New("no wayback snapshot found")
+	ErrUnavailable = errors.New("wayback machine unavailable")
+	ErrRateLimited = errors.New("wayback machine rate limit reached")
+)
+
+// reSnapshotPath matches the path of a snapshot, /web/<timestamp>/<url>.
+var reSnapshotPath = regexp.MustCompile(`^/web/(\d{14})[a-z_]*/`)
+
+// Snapshot is a capture of a page in the Wayback Machine.
+type Snapshot struct {
+	URL       string `json:"url"`
+	Timestamp string `json:"timestamp"` // YYYYMMDDhhmmss
+}
+
+type OptFn func(*Client)
+
+// Client talks to a Wayback Machine compatible service, spacing out its
+// requests.
+type Client struct {
+	base     *url.URL
+	client   *http.Client
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func WithClient(c *http.Client) OptFn {
+	return func(w *Client) {
+		w.client = c
+	}
+}
+
+// WithInterval sets the minimum time between two requests to the service.
+func WithInterval(d time.Duration) OptFn {
+	return func(w *Client) {
+		w.interval = d
+	}
+}
+
+// New creates a client for the service at baseURL.
+func New(baseURL string, opts ...OptFn) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("wayback base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("wayback base URL: unsupported scheme %q", base.Scheme)
+	}
+
+	w := &Client{
+		base:     base,
+		client:   &http.Client{Timeout: DefaultTimeout},
+		interval: DefaultInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w, nil
+}
+
+// Closest returns the most recent snapshot of the page.
+func (w *Client) Closest(ctx context.Context, rawURL string) (*Snapshot, error) {
+	u := w.base.JoinPath("/wayback/available")
+	u.RawQuery = url.Values{"url": {rawURL}}.Encode()
+
+	resp, err := w.do(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var res struct {
+		ArchivedSnapshots struct {
+			Closest *struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("%w: decoding response: %w", ErrUnavailable, err)
+	}
+
+	c := res.ArchivedSnapshots.Closest
+	if c == nil || !c.Available || c.URL == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, rawURL)
+	}
+
+	return &Snapshot{URL: c.URL, Timestamp: c.Timestamp}, nil
+}
+
+// Save asks the service to capture the page now and returns the new
+// snapshot.
+func (w *Client) Save(ctx context.Context, rawURL string) (*Snapshot, error) {
+	u := w.base.JoinPath("/save/")
+	u.Path += rawURL
+
+	resp, err := w.do(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The service redirects to the new snapshot, or names it in the
+	// Content-Location header.
+	if loc := resp.Header.Get("Content-Location"); loc != "" {
+		if m := reSnapshotPath.FindStringSubmatch(loc); m != nil {
+			return &Snapshot{URL: w.base.String() + loc, Timestamp: m[1]}, nil
+		}
+	}
+	if m := reSnapshotPath.FindStringSubmatch(resp.Request.URL.Path); m != nil {
+		return &Snapshot{URL: resp.Request.URL.String(), Timestamp: m[1]}, nil
+	}
+
+	return w.Closest(ctx, rawURL)
+}
+
+// do sends a GET request once the rate limit allows it.
+func (w *Client) do(ctx context.Context, rawURL string) (*http.Response, error) {
+	if err := w.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		_ = resp.Body.Close()
+		return nil, ErrRateLimited
+	case resp.StatusCode >= http.StatusInternalServerError:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	case resp.StatusCode == http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= http.StatusBadRequest:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("wayback machine: unexpected status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// wait blocks until the next request slot.
+func (w *Client) wait(ctx context.Context) error {
+	w.mu.Lock()
+	now := time.Now()
+	slot := now
+	if w.next.After(now) {
+		slot = w.next
+	}
+	w.next = slot.Add(w.interval)
+	w.mu.Unlock()
+
+	if d := slot.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return nil
+}
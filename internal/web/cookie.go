@@ -13,6 +13,7 @@ const expiryOneYear = 60 * 60 * 24 * 365
 type CookieState struct {
 	ActiveTheme *ActiveTheme
 	CompactMode bool
+	GridMode    bool
 	VimMode     bool
 	DevMode     bool
 }
@@ -20,6 +21,7 @@ type CookieState struct {
 type jar struct {
 	vimMode         string
 	compactMode     string
+	gridMode        string
 	themeCurrent    string
 	themeMode       string
 	itemsPerPage    string
@@ -115,6 +117,7 @@ func (c *cookieType) userPref(r *http.Request) *CookieState {
 	return &CookieState{
 		ActiveTheme: t,
 		CompactMode: cookie.getBool(r, cookie.jar.compactMode, false),
+		GridMode:    cookie.getBool(r, cookie.jar.gridMode, false),
 		VimMode:     cookie.getBool(r, cookie.jar.vimMode, false),
 		DevMode:     devMode,
 	}
@@ -124,6 +127,7 @@ var cookie = &cookieType{
 	jar: &jar{
 		vimMode:         "vim_mode",
 		compactMode:     "compact_mode",
+		gridMode:        "grid_mode",
 		themeCurrent:    "user_theme",
 		themeMode:       "theme_mode",
 		itemsPerPage:    "items_per_page",
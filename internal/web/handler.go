@@ -9,6 +9,9 @@ import (
 	"log/slog"
 
 	"github.com/mateconpizza/gmweb/internal/application"
+	"github.com/mateconpizza/gmweb/internal/archive"
+	"github.com/mateconpizza/gmweb/internal/favicon"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/router"
 )
@@ -23,6 +26,9 @@ type Opt struct {
 	appCfg       *application.Config
 	logger       *slog.Logger
 	router       *router.Router
+	checker      *linkcheck.Checker
+	archiver     *archive.Archiver
+	favicons     *favicon.Cache
 }
 
 type Handler struct {
@@ -80,6 +86,26 @@ func WithRoutes(r *router.Router) OptFn {
 	}
 }
 
+func WithLinkChecker(c *linkcheck.Checker) OptFn {
+	return func(o *Opt) {
+		o.checker = c
+	}
+}
+
+func WithArchiver(a *archive.Archiver) OptFn {
+	return func(o *Opt) {
+		o.archiver = a
+	}
+}
+
+// WithFavicons sets the cache the favicons of the listed bookmarks are
+// fetched into. Without it the cached favicons are only served.
+func WithFavicons(c *favicon.Cache) OptFn {
+	return func(o *Opt) {
+		o.favicons = c
+	}
+}
+
 func NewHandler(opts ...OptFn) *Handler {
 	wo := &Opt{}
 	for _, opt := range opts {
@@ -2,8 +2,11 @@ package web
 
 import (
 	"bytes"
+	"cmp"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"net/http"
 	"path/filepath"
@@ -13,11 +16,17 @@ import (
 	"github.com/mateconpizza/gm/pkg/bookmark"
 	"github.com/mateconpizza/gm/pkg/files"
 
+	"github.com/mateconpizza/gmweb/internal/archive"
+	"github.com/mateconpizza/gmweb/internal/database"
+	"github.com/mateconpizza/gmweb/internal/dupes"
 	"github.com/mateconpizza/gmweb/internal/forms"
 	"github.com/mateconpizza/gmweb/internal/helpers"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/middleware"
+	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/qr"
 	"github.com/mateconpizza/gmweb/internal/responder"
+	"github.com/mateconpizza/gmweb/internal/search"
 	"github.com/mateconpizza/gmweb/ui"
 )
 
@@ -37,10 +46,17 @@ func (h *Handler) Routes(mux *http.ServeMux) {
 	mux.Handle("GET "+r.Web.All(), requireDB(h.index))
 	mux.Handle("GET "+r.Web.New(), requireDB(h.recordNew))
 	mux.Handle("GET "+r.Web.NewFrame(), requireDB(h.recordNewFrame))
+	mux.Handle("GET "+r.Web.Detail("{id}"), requireIDAndDB(h.recordDetail))
+	mux.Handle("GET "+r.Web.View("{id}"), requireIDAndDB(h.recordSnapshot))
+	mux.Handle("GET "+r.Web.Reader("{id}"), requireIDAndDB(h.recordReader))
 	mux.Handle("GET "+r.Web.Edit("{id}"), requireIDAndDB(h.recordEdit))
 	mux.Handle("GET "+r.Web.QRCode("{id}"), requireIDAndDB(h.recordQR))
 	mux.Handle("GET "+r.Web.Export(), requireDB(h.recordExport))
+	mux.Handle("GET "+r.Web.DeadLinks(), requireDB(h.deadLinks))
+	mux.Handle("GET "+r.Web.Redirects(), requireDB(h.redirects))
+	mux.Handle("GET "+r.Web.Duplicates(), requireDB(h.duplicates))
 	mux.HandleFunc("POST "+r.Web.Settings(), h.settings)
+	mux.HandleFunc("GET "+r.Web.Search(), h.searchAll)
 
 	// User related
 	mux.HandleFunc("GET "+r.User.Signup, h.userSignup)
@@ -61,6 +77,20 @@ func (h *Handler) Routes(mux *http.ServeMux) {
 		ui.FaviconCachePath,
 		http.StripPrefix(ui.FaviconCachePath, http.FileServer(http.Dir(iconsPath))),
 	)
+
+	// previews are named after their content and never change.
+	previewsPath := filepath.Join(h.cacheDir, "preview")
+	mux.Handle(ui.PreviewCachePath, immutable(
+		http.StripPrefix(ui.PreviewCachePath, http.FileServer(http.Dir(previewsPath))),
+	))
+}
+
+// immutable lets the browsers cache the responses of next for a year.
+func immutable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(expiryOneYear)+", immutable")
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (h *Handler) indexRedirect(w http.ResponseWriter, r *http.Request) {
@@ -80,6 +110,7 @@ func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
 		responder.ServerErr(w, r, err)
 		return
 	}
+	defer repo.Close()
 
 	records, err := repo.All(r.Context())
 	if err != nil {
@@ -98,14 +129,12 @@ func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
 	pagination := calculatePagination(len(filtered), p.Page, h.itemsPerPage)
 	paginated := filtered[pagination.StartIndex:pagination.EndIndex]
 
-	faviconPath := filepath.Join(h.cacheDir, "favicon")
-	if err := files.MkdirAll(faviconPath); err != nil {
-		responder.ServerErr(w, r, err)
+	// a read-only repository cannot save the fetched favicons, so only the
+	// ones already cached are served.
+	if h.favicons != nil && !database.IsReadOnly(p.CurrentDB) {
+		h.favicons.Enqueue(p.CurrentDB, paginated)
 	}
 
-	favicons := NewFaviconProcessor(repo, faviconPath, ui.FaviconCachePath)
-	go favicons.Process(paginated)
-
 	// Context
 	ctx := &TemplateContext{
 		App:        h.appCfg,
@@ -119,6 +148,12 @@ func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
 
 	data := buildIndexTemplateData(ctx)
 	data.Colorscheme.List = h.colorschemes
+	if data.Cookie.GridMode {
+		data.Previews, err = repo.Previews(r.Context())
+		if err != nil {
+			h.logger.Warn("index: loading previews", "db", p.CurrentDB, "error", err)
+		}
+	}
 	h.renderPage(w, r, http.StatusOK, "index", data)
 }
 
@@ -129,6 +164,7 @@ func (h *Handler) recordQR(w http.ResponseWriter, r *http.Request) {
 		responder.ServerErr(w, r, err)
 		return
 	}
+	defer repo.Close()
 
 	bID, _ := strconv.Atoi(r.PathValue("id"))
 	b, err := repo.ByID(r.Context(), bID)
@@ -183,13 +219,276 @@ func (h *Handler) recordQR(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// recordDetail renders the bookmark list with the given record and opens
+// its detail modal.
+func (h *Handler) recordDetail(w http.ResponseWriter, r *http.Request) {
+	p := parseRequestParams(r)
+	p.CurrentDB = r.PathValue("db")
+
+	repo, err := h.repoLoader(p.CurrentDB)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	b, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		if errors.Is(err, bookmark.ErrBookmarkNotFound) {
+			responder.ServerCustomErr(w, r, err, http.StatusNotFound)
+			return
+		}
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	bs := []*bookmark.Bookmark{b}
+	ctx := &TemplateContext{
+		App:        h.appCfg,
+		Request:    r,
+		Bookmarks:  bs,
+		Params:     p,
+		Routes:     h.router,
+		TagsFn:     helpers.GetTagsFn("", "", bs, bs),
+		Pagination: calculatePagination(len(bs), 1, h.itemsPerPage),
+	}
+
+	data := buildIndexTemplateData(ctx)
+	data.Bookmark = b
+	data.PageTitle = h.appCfg.Name + ": " + b.Title
+	data.Colorscheme.List = h.colorschemes
+	h.renderPage(w, r, http.StatusOK, "index", data)
+}
+
+// snapshotCSP keeps the archived page from loading anything that was not
+// inlined, and from running scripts.
+const snapshotCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; " +
+	"font-src data:; media-src data:; sandbox allow-popups allow-popups-to-escape-sandbox"
+
+// recordSnapshot serves the local snapshot of the record, the newest one
+// unless ?snapshot={id} is given.
+func (h *Handler) recordSnapshot(w http.ResponseWriter, r *http.Request) {
+	if h.archiver == nil {
+		responder.ServerCustomErr(w, r, archive.ErrDisabled, http.StatusNotFound)
+		return
+	}
+
+	dbName := r.PathValue("db")
+	repo, err := h.repoLoader(dbName)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	sid := r.URL.Query().Get("snapshot")
+	var s *models.Snapshot
+	if sid != "" {
+		sID, _ := strconv.Atoi(sid)
+		s, err = repo.SnapshotByID(r.Context(), bID, sID)
+	} else {
+		var ss []*models.Snapshot
+		ss, err = repo.Snapshots(r.Context(), bID)
+		if err == nil && len(ss) == 0 {
+			err = models.ErrSnapshotNotFound
+		}
+		if err == nil {
+			s = ss[0]
+		}
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrSnapshotNotFound) {
+			responder.ServerCustomErr(w, r, err, http.StatusNotFound)
+			return
+		}
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	f, err := h.archiver.Open(dbName, s)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Content-Security-Policy", snapshotCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if sid != "" {
+		// a given snapshot never changes.
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		h.logger.Error("serving snapshot", "error", err, "db", dbName, "id", bID)
+	}
+}
+
+// recordReader renders the extracted article of the record in the reader
+// view.
+func (h *Handler) recordReader(w http.ResponseWriter, r *http.Request) {
+	d := newTemplateData(r)
+	repo, err := h.repoLoader(d.Params.CurrentDB)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	bID, _ := strconv.Atoi(r.PathValue("id"))
+	b, err := repo.ByID(r.Context(), bID)
+	if err != nil {
+		responder.ServerCustomErr(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	a, err := repo.Article(r.Context(), bID)
+	switch {
+	case err == nil:
+		d.Article = &ArticleView{Article: a, Content: template.HTML(a.Content)} //nolint:gosec // sanitised
+	case !errors.Is(err, models.ErrArticleNotFound):
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	d.App = h.appCfg
+	d.Bookmark = b
+	d.PageTitle = h.appCfg.Name + ": " + cmp.Or(b.Title, b.URL)
+	d.CurrentPath = r.URL.Path
+	d.Colorscheme = &AppColorscheme{Default: ui.DefaultColorschemeFile, List: h.colorschemes}
+
+	h.renderPage(w, r, http.StatusOK, "reader", d)
+}
+
+// deadLinks renders the records the link checker found unreachable.
+func (h *Handler) deadLinks(w http.ResponseWriter, r *http.Request) {
+	d := newTemplateData(r)
+	repo, err := h.repoLoader(d.Params.CurrentDB)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(r.Context())
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	d.App = h.appCfg
+	d.PageTitle = h.appCfg.Name + ": Dead links"
+	d.CurrentPath = r.URL.Path
+	d.Colorscheme = &AppColorscheme{Default: ui.DefaultColorschemeFile, List: h.colorschemes}
+	d.Bookmarks = linkcheck.Dead(bs)
+	if h.checker != nil {
+		st := h.checker.Status(d.Params.CurrentDB)
+		d.LinkCheck = &st
+	}
+
+	h.renderPage(w, r, http.StatusOK, "dead-links", d)
+}
+
+// redirects renders the records whose URL moved, to review the rewrites.
+func (h *Handler) redirects(w http.ResponseWriter, r *http.Request) {
+	d := newTemplateData(r)
+	repo, err := h.repoLoader(d.Params.CurrentDB)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	rs, err := repo.Redirects(r.Context())
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	d.App = h.appCfg
+	d.PageTitle = h.appCfg.Name + ": Moved links"
+	d.CurrentPath = r.URL.Path
+	d.Colorscheme = &AppColorscheme{Default: ui.DefaultColorschemeFile, List: h.colorschemes}
+	for _, rd := range rs {
+		b, err := repo.ByID(r.Context(), rd.BookmarkID)
+		if err != nil {
+			// the record was deleted after the check.
+			continue
+		}
+		d.Redirects = append(d.Redirects, &RedirectView{Redirect: rd, Bookmark: b})
+	}
+
+	h.renderPage(w, r, http.StatusOK, "redirects", d)
+}
+
+// duplicates renders the bookmarks saved more than once, to merge them.
+func (h *Handler) duplicates(w http.ResponseWriter, r *http.Request) {
+	d := newTemplateData(r)
+	repo, err := h.repoLoader(d.Params.CurrentDB)
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+	defer repo.Close()
+
+	bs, err := repo.All(r.Context())
+	if err != nil {
+		responder.ServerErr(w, r, err)
+		return
+	}
+
+	d.App = h.appCfg
+	d.PageTitle = h.appCfg.Name + ": Duplicates"
+	d.CurrentPath = r.URL.Path
+	d.Colorscheme = &AppColorscheme{Default: ui.DefaultColorschemeFile, List: h.colorschemes}
+	d.Duplicates = dupes.Find(bs)
+
+	h.renderPage(w, r, http.StatusOK, "duplicates", d)
+}
+
+// searchAll renders the results of a search across every repository.
+func (h *Handler) searchAll(w http.ResponseWriter, r *http.Request) {
+	// the page is not bound to a repository; use the default one for the
+	// navigation links.
+	r.SetPathValue("db", cookie.get(r, cookie.jar.defaultRepoName, h.appCfg.MainDB))
+
+	d := newTemplateData(r)
+	d.App = h.appCfg
+	d.PageTitle = h.appCfg.Name + ": Search"
+	d.CurrentPath = r.URL.Path
+	d.Colorscheme = &AppColorscheme{Default: ui.DefaultColorschemeFile, List: h.colorschemes}
+
+	p := d.Params
+	if p.Query != "" || p.Tag != "" || p.Letter != "" || p.FilterBy != "" {
+		d.Search = search.Run(r.Context(), h.repoLoader, database.Names(), search.Query{
+			Query:    p.Query,
+			Tag:      p.Tag,
+			Letter:   p.Letter,
+			FilterBy: p.FilterBy,
+		})
+		for name, err := range d.Search.Failed {
+			h.logger.Error("search", "error", err, "repo", name)
+		}
+	}
+
+	h.renderPage(w, r, http.StatusOK, "search", d)
+}
+
 func (h *Handler) recordEdit(w http.ResponseWriter, r *http.Request) {
 	d := newTemplateData(r)
+	if d.ReadOnly {
+		responder.ServerCustomErr(w, r, models.ErrReadOnly, http.StatusForbidden)
+		return
+	}
+
 	repo, err := h.repoLoader(d.Params.CurrentDB)
 	if err != nil {
 		responder.ServerErr(w, r, err)
 		return
 	}
+	defer repo.Close()
 
 	idStr := r.PathValue("id")
 	bID, _ := strconv.Atoi(idStr)
@@ -212,6 +511,11 @@ func (h *Handler) recordEdit(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) recordNew(w http.ResponseWriter, r *http.Request) {
 	// FIX: maybe, redirect to `view` new record.
 	d := newTemplateData(r)
+	if d.ReadOnly {
+		responder.ServerCustomErr(w, r, models.ErrReadOnly, http.StatusForbidden)
+		return
+	}
+
 	d.Colorscheme.List = h.colorschemes
 	d.PageTitle = "New Bookmark"
 	d.URL = buildURLs(d.Params, r)
@@ -223,6 +527,11 @@ func (h *Handler) recordNew(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) recordNewFrame(w http.ResponseWriter, r *http.Request) {
 	d := newTemplateData(r)
+	if d.ReadOnly {
+		responder.ServerCustomErr(w, r, models.ErrReadOnly, http.StatusForbidden)
+		return
+	}
+
 	u := r.URL.Query().Get("url")
 
 	repo, err := h.repoLoader(d.Params.CurrentDB)
@@ -230,6 +539,7 @@ func (h *Handler) recordNewFrame(w http.ResponseWriter, r *http.Request) {
 		responder.ServerErr(w, r, err)
 		return
 	}
+	defer repo.Close()
 
 	d.Colorscheme.List = h.colorschemes
 	d.PageTitle = "New Bookmark"
@@ -316,6 +626,7 @@ func (h *Handler) recordExport(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer repo.Close()
 
 	records, err := repo.All(r.Context())
 	if err != nil {
@@ -345,6 +656,7 @@ func (h *Handler) settings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cookie.set(w, cookie.jar.compactMode, strconv.FormatBool(f.CompactMode))
+	cookie.set(w, cookie.jar.gridMode, strconv.FormatBool(f.GridMode))
 	cookie.set(w, cookie.jar.itemsPerPage, strconv.Itoa(f.ItemsPerPage))
 	cookie.set(w, cookie.jar.themeCurrent, f.ThemeName)
 	cookie.set(w, cookie.jar.themeMode, themeMode)
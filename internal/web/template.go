@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/justinas/nosurf"
@@ -13,8 +14,16 @@ import (
 	"github.com/mateconpizza/gm/pkg/files"
 
 	"github.com/mateconpizza/gmweb/internal/application"
+	"github.com/mateconpizza/gmweb/internal/database"
+	"github.com/mateconpizza/gmweb/internal/dupes"
+	"github.com/mateconpizza/gmweb/internal/favicon"
 	"github.com/mateconpizza/gmweb/internal/helpers"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
+	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/preview"
 	"github.com/mateconpizza/gmweb/internal/router"
+	"github.com/mateconpizza/gmweb/internal/search"
+	"github.com/mateconpizza/gmweb/internal/tags"
 	"github.com/mateconpizza/gmweb/ui"
 )
 
@@ -38,8 +47,15 @@ type TemplateData struct {
 	Pagination PaginationInfo
 	Params     *RequestParams
 	Routes     *router.WebRouter
-	TagGroups  map[string][]string
+	TagTree    []*TagNode
 	CSRFToken  string
+	ReadOnly   bool
+	Search     *search.Result
+	LinkCheck  *linkcheck.Status
+	Redirects  []*RedirectView
+	Duplicates []*dupes.Group
+	Article    *ArticleView
+	Previews   map[int]string // preview keys by bookmark ID, in grid mode
 
 	// Forms
 	Form          any
@@ -71,9 +87,23 @@ func newTemplateData(r *http.Request) *TemplateData {
 		CurrentURI:  r.RequestURI,
 		Cookie:      cookie.userPref(r),
 		CSRFToken:   nosurf.Token(r),
+		ReadOnly:    database.IsReadOnly(p.CurrentDB),
 	}
 }
 
+// RedirectView is a pending redirect and the bookmark it belongs to.
+type RedirectView struct {
+	*models.Redirect
+	Bookmark *bookmark.Bookmark
+}
+
+// ArticleView is the extracted article of a bookmark, with its content ready
+// to render. The content is sanitised by the reader when extracted.
+type ArticleView struct {
+	*models.Article
+	Content template.HTML
+}
+
 type BookmarkTemplateData struct {
 	Bookmark *bookmark.Bookmark
 	FuncMap  template.FuncMap
@@ -118,16 +148,14 @@ var templateFuncs = template.FuncMap{
 	"stripSuffix":       files.StripSuffixes,
 	"now":               func() int64 { return time.Now().UnixNano() },
 	"add":               func(a, b int) int { return a + b },
+	"avatar":            favicon.NewAvatar,
 	"sub":               func(a, b int) int { return a - b },
+	"previewURL": func(key, size string) string {
+		return ui.PreviewCachePath + preview.File(key, size)
+	},
 	"tagURL": func(p *RequestParams, tag string, path string) string {
 		return p.with().Tag(tag).Build(path)
 	},
-	"letterToggleURL": func(p *RequestParams, targetLetter, path string) string {
-		if p.Letter == targetLetter {
-			return p.with().Letter("").Build(path)
-		}
-		return p.with().Letter(targetLetter).Build(path)
-	},
 	"seq": func(start, end int) []int {
 		if start > end {
 			return nil
@@ -181,8 +209,9 @@ func buildIndexTemplateData(ctx *TemplateContext) *TemplateData {
 		PageTitle:   ctx.App.Name + ": Bookmarks",
 		CurrentYear: time.Now().Year(),
 		Pagination:  ctx.Pagination,
-		TagGroups:   helpers.GroupTagsByLetter(ctx.TagsFn()),
+		TagTree:     buildTagTree(p, r.URL.Path, tags.Tree(ctx.TagsFn())),
 		CSRFToken:   nosurf.Token(r),
+		ReadOnly:    database.IsReadOnly(p.CurrentDB),
 		CurrentPath: r.URL.Path,
 		CurrentURI:  r.RequestURI,
 		Routes:      ctx.Routes.SetRepo(p.CurrentDB).Web,
@@ -195,6 +224,37 @@ func buildIndexTemplateData(ctx *TemplateContext) *TemplateData {
 	}
 }
 
+// TagNode is a level of the tag tree of the sidebar. URL toggles the
+// filter by the tag, which also selects its children.
+type TagNode struct {
+	Name     string
+	Path     string
+	URL      string
+	Active   bool // filtered by this tag
+	Open     bool // filtered by this tag or one of its children
+	Children []*TagNode
+}
+
+func buildTagTree(p *RequestParams, path string, ns []*tags.Node) []*TagNode {
+	out := make([]*TagNode, 0, len(ns))
+	for _, n := range ns {
+		tn := &TagNode{
+			Name:     n.Name,
+			Path:     n.Path,
+			Active:   strings.EqualFold(n.Path, p.Tag),
+			Open:     p.Tag != "" && tags.Matches(p.Tag, n.Path),
+			Children: buildTagTree(p, path, n.Children),
+		}
+		tn.URL = p.with().Tag(n.Path).Build(path)
+		if tn.Active {
+			tn.URL = p.with().Tag("").Build(path)
+		}
+		out = append(out, tn)
+	}
+
+	return out
+}
+
 func buildURLs(p *RequestParams, r *http.Request) *URLs {
 	path := r.URL.Path
 	return &URLs{
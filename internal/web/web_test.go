@@ -9,7 +9,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/mateconpizza/gm/pkg/bookmark"
+
 	"github.com/mateconpizza/gmweb/internal/application"
+	"github.com/mateconpizza/gmweb/internal/archive"
 	"github.com/mateconpizza/gmweb/internal/database"
 	"github.com/mateconpizza/gmweb/internal/models"
 	"github.com/mateconpizza/gmweb/internal/models/mocks"
@@ -99,3 +102,164 @@ func TestIndex(t *testing.T) {
 		t.Errorf("expected to contain: %q", b.Tags)
 	}
 }
+
+func TestIndexTagTree(t *testing.T) {
+	t.Parallel()
+	m := mocks.New()
+	m.Records = []*bookmark.Bookmark{
+		{ID: 1, URL: "https://go.dev", Tags: "lang/go"},
+		{ID: 2, URL: "https://www.rust-lang.org", Tags: "lang/rust,systems"},
+		{ID: 3, URL: "https://misc.example/page", Tags: "misc"},
+	}
+	h := setupHandler(t, m)
+	mux := http.NewServeMux()
+	h.Routes(mux)
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	h.router.SetRepo(m.Name())
+	code, _, body := ts.get(t, h.router.Web.All()+"?tag=lang")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", code)
+	}
+
+	for _, want := range []string{"https://go.dev", "https://www.rust-lang.org", `class="tag-tree-branch" open`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the parent filter to include %q", want)
+		}
+	}
+	if strings.Contains(body, "https://misc.example/page") {
+		t.Error("expected bookmarks outside the parent tag to be filtered out")
+	}
+}
+
+func TestRecordDetailAndSearch(t *testing.T) {
+	t.Parallel()
+	m := mocks.New()
+	m.Records = mocks.Bookmarks
+	h := setupHandler(t, m)
+	mux := http.NewServeMux()
+	h.Routes(mux)
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	web := router.NewWebRoutes(m.Name())
+	code, _, body := ts.get(t, web.Detail("2"))
+	if code != http.StatusOK {
+		t.Fatalf("detail: expected 200 OK, got %d", code)
+	}
+	if !strings.Contains(body, `data-open-detail="2"`) {
+		t.Error("detail: expected the record modal to be opened")
+	}
+
+	if code, _, _ := ts.get(t, web.Detail("99")); code != http.StatusNotFound {
+		t.Errorf("detail: expected 404 for a missing record, got %d", code)
+	}
+
+	code, _, body = ts.get(t, web.Search()+"?q=other")
+	if code != http.StatusOK {
+		t.Fatalf("search: expected 200 OK, got %d", code)
+	}
+	if !strings.Contains(body, mocks.Bookmarks[1].Title) {
+		t.Errorf("search: expected to contain %q", mocks.Bookmarks[1].Title)
+	}
+	if !strings.Contains(body, web.Detail("2")) {
+		t.Errorf("search: expected link to %q", web.Detail("2"))
+	}
+}
+
+func TestRecordSnapshot(t *testing.T) {
+	t.Parallel()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, `<html><head><script>alert(1)</script></head><body>archived page</body></html>`)
+	}))
+	defer page.Close()
+
+	m := mocks.New()
+	m.Records = []*bookmark.Bookmark{{ID: 1, URL: page.URL}}
+	h := setupHandler(t, m)
+	h.archiver = archive.New(t.TempDir(), func(string) (models.Repo, error) { return m, nil })
+	mux := http.NewServeMux()
+	h.Routes(mux)
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	web := router.NewWebRoutes(m.Name())
+	if code, _, _ := ts.get(t, web.View("1")); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without snapshots, got %d", code)
+	}
+
+	if _, err := h.archiver.Archive(t.Context(), m.Name(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	code, header, body := ts.get(t, web.View("1"))
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", code)
+	}
+	if !strings.Contains(body, "archived page") {
+		t.Errorf("expected the snapshot content, got %q", body)
+	}
+	if strings.Contains(body, "<script") {
+		t.Error("expected scripts to be removed from the snapshot")
+	}
+	if !strings.Contains(header.Get("Content-Security-Policy"), "default-src 'none'") {
+		t.Errorf("expected a restrictive CSP, got %q", header.Get("Content-Security-Policy"))
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	t.Parallel()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, `<html lang="en"><head><title>Reading list</title>
+			<meta name="author" content="Jane Doe"></head><body>
+			<nav><p>Home, About, Contact, and a few more menu entries here.</p></nav>
+			<article><h1>Reading list</h1>
+			<p>The first paragraph talks about gophers, channels, and goroutines.</p>
+			<p>The second paragraph <script>alert(1)</script>is long enough to count.</p>
+			</article></body></html>`)
+	}))
+	defer page.Close()
+
+	m := mocks.New()
+	m.Records = []*bookmark.Bookmark{{ID: 1, URL: page.URL}}
+	h := setupHandler(t, m)
+	h.archiver = archive.New(t.TempDir(), func(string) (models.Repo, error) { return m, nil })
+	mux := http.NewServeMux()
+	h.Routes(mux)
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	web := router.NewWebRoutes(m.Name())
+	if code, _, body := ts.get(t, web.Reader("1")); code != http.StatusOK || !strings.Contains(body, "No article") {
+		t.Fatalf("expected the empty reader view, got %d", code)
+	}
+
+	a, err := h.archiver.Extract(t.Context(), m.Name(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Byline != "Jane Doe" || a.ReadingTime != 1 {
+		t.Errorf("unexpected article metadata: %+v", a)
+	}
+	if strings.Contains(a.Text, "Contact") {
+		t.Errorf("expected the navigation to be left out, got %q", a.Text)
+	}
+
+	code, _, body := ts.get(t, web.Reader("1"))
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", code)
+	}
+	if !strings.Contains(body, "<p>The first paragraph talks about gophers") {
+		t.Errorf("expected the article content, got %q", body)
+	}
+	if strings.Contains(body, "alert(1)") {
+		t.Error("expected scripts to be removed from the article")
+	}
+}
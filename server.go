@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,22 +17,41 @@ import (
 
 	"github.com/mateconpizza/gmweb/internal/api"
 	"github.com/mateconpizza/gmweb/internal/application"
+	"github.com/mateconpizza/gmweb/internal/archive"
 	"github.com/mateconpizza/gmweb/internal/database"
+	"github.com/mateconpizza/gmweb/internal/favicon"
+	"github.com/mateconpizza/gmweb/internal/fetch"
 	"github.com/mateconpizza/gmweb/internal/graceful"
+	"github.com/mateconpizza/gmweb/internal/linkcheck"
 	"github.com/mateconpizza/gmweb/internal/middleware"
 	"github.com/mateconpizza/gmweb/internal/models"
+	"github.com/mateconpizza/gmweb/internal/preview"
 	"github.com/mateconpizza/gmweb/internal/router"
 	"github.com/mateconpizza/gmweb/internal/server"
+	"github.com/mateconpizza/gmweb/internal/wayback"
 	"github.com/mateconpizza/gmweb/internal/web"
 	"github.com/mateconpizza/gmweb/ui"
 )
 
 // setupRoutes configures and returns the main HTTP router with all handlers.
-func setupRoutes(app *application.App) *http.ServeMux {
+func setupRoutes(
+	app *application.App,
+	favicons *favicon.Cache,
+	checker *linkcheck.Checker,
+	archiver *archive.Archiver,
+	wb *wayback.Client,
+	client *http.Client,
+) *http.ServeMux {
 	r := router.New("{db}")
 	mux := http.NewServeMux()
 
 	apiHandler := api.NewHandler(
+		api.WithLinkChecker(checker),
+		api.WithArchiver(archiver),
+		api.WithScraper(archive.NewScraper(client)),
+		api.WithFavicons(favicons),
+		api.WithWayback(wb),
+		api.WithClient(client),
 		api.WithRepoLoader(database.Get),
 		api.WithAppInfo(app.Cfg.Info),
 		api.WithDataDir(app.Flags.Path),
@@ -51,6 +71,9 @@ func setupRoutes(app *application.App) *http.ServeMux {
 		web.WithLogger(app.Log),
 		web.WithRoutes(r),
 		web.WithDevMode(app.Flags.DevMode),
+		web.WithLinkChecker(checker),
+		web.WithArchiver(archiver),
+		web.WithFavicons(favicons),
 	)
 	webHandler.Routes(mux)
 
@@ -67,10 +90,11 @@ func setupRepos(app *application.App) error {
 	if len(paths) == 0 {
 		dbPath := files.EnsureSuffix(filepath.Join(app.Cfg.DataDir, app.Cfg.MainDB), ".db")
 		app.Log.Debug("first run: creating main database")
-		_, err := models.Initialize(context.Background(), dbPath)
+		m, err := models.Initialize(context.Background(), dbPath)
 		if err != nil {
 			return err
 		}
+		m.Close()
 
 		paths = append(paths, dbPath)
 	}
@@ -79,6 +103,17 @@ func setupRepos(app *application.App) error {
 		database.Register(files.StripSuffixes(filepath.Base(p)), p)
 	}
 
+	if err := database.LoadSettings(filepath.Join(app.Cfg.DataDir, database.SettingsFile)); err != nil {
+		return err
+	}
+
+	for _, name := range app.Server.ReadOnlyRepos {
+		if err := database.LockReadOnly(name); err != nil {
+			return err
+		}
+		app.Log.Info("repository served read-only", "repo", name)
+	}
+
 	return nil
 }
 
@@ -95,11 +130,37 @@ func run(app *application.App) error {
 		return err
 	}
 
-	srv := setupServer(app)
-	registerCleanups(app, srv)
+	database.SetIdleTimeout(app.Server.RepoIdleTimeout)
+	go database.Watch(ctx)
+
+	fetchOpts, err := setupFetch(app)
+	if err != nil {
+		return err
+	}
+	client := fetch.New(fetchOpts...)
+
+	checker := setupLinkChecker(app, client)
+	go checker.Run(ctx)
+
+	// an empty base URL disables the Wayback Machine lookups.
+	var wb *wayback.Client
+	if app.Server.WaybackURL != "" {
+		// saving a page takes longer than the other outbound requests.
+		wbClient := fetch.New(append(fetchOpts, fetch.WithTimeout(wayback.DefaultTimeout))...)
+		if wb, err = wayback.New(app.Server.WaybackURL, wayback.WithClient(wbClient)); err != nil {
+			return err
+		}
+	}
+
+	favicons := setupFavicons(app, client)
+	go favicons.Run(ctx)
+
+	archiver := setupArchiver(app, client)
+	srv := setupServer(app, favicons, checker, archiver, wb, client)
+	registerCleanups(app, srv, favicons, checker, archiver)
 	graceful.Listen(ctx, cancel)
 
-	err := srv.Start()
+	err = srv.Start()
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		cancel()
 	}
@@ -109,7 +170,94 @@ func run(app *application.App) error {
 	return err
 }
 
-func setupServer(app *application.App) *server.Server {
+// setupFetch returns the options of the outbound HTTP client. Private and
+// loopback addresses are refused unless listed in --allow-net; repositories
+// set to bypass the proxy go direct.
+func setupFetch(app *application.App) ([]fetch.OptFn, error) {
+	nets, err := fetch.ParseNets(app.Server.AllowNets)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []fetch.OptFn{
+		fetch.WithTimeout(app.Server.FetchTimeout),
+		fetch.WithMaxBodySize(int64(app.Server.FetchMaxSizeMB) << 20),
+		fetch.WithUserAgent(app.Server.UserAgent),
+		fetch.WithAllowedNets(nets...),
+	}
+
+	if app.Server.Proxy != "" {
+		proxy, err := fetch.ParseProxy(app.Server.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			fetch.WithProxy(proxy, app.Server.NoProxy...),
+			fetch.WithProxyBypass(database.BypassesProxy),
+		)
+		app.Log.Info("outbound requests use a proxy", "proxy", proxy.Redacted())
+	}
+
+	return opts, nil
+}
+
+// setupLinkChecker creates the background link checker. Read-only
+// repositories are skipped.
+func setupLinkChecker(app *application.App, client *http.Client) *linkcheck.Checker {
+	writable := func() []string {
+		names := database.Names()
+		return slices.DeleteFunc(names, database.IsReadOnly)
+	}
+
+	return linkcheck.New(database.Get, writable,
+		linkcheck.WithInterval(app.Server.CheckInterval),
+		linkcheck.WithConcurrency(app.Server.CheckWorkers),
+		linkcheck.WithHostDelay(app.Server.CheckHostDelay),
+		linkcheck.WithLogger(app.Log),
+		linkcheck.WithClient(client),
+	)
+}
+
+// setupFavicons creates the favicon cache shared by the repositories.
+func setupFavicons(app *application.App, client *http.Client) *favicon.Cache {
+	return favicon.New(filepath.Join(app.Cfg.CacheDir, "favicon"), ui.FaviconCachePath,
+		database.Get, database.Names,
+		favicon.WithGCInterval(app.Server.FaviconGC),
+		favicon.WithBackoff(app.Server.FaviconBackoff),
+		favicon.WithWorkers(app.Server.FaviconWorkers),
+		favicon.WithLogger(app.Log),
+		favicon.WithClient(client),
+	)
+}
+
+// setupArchiver creates the page archiver. Snapshots are stored under the
+// data directory, limited by the repository quota.
+func setupArchiver(app *application.App, client *http.Client) *archive.Archiver {
+	quota := func(repo string) int64 {
+		mb := app.Server.ArchiveQuotaMB
+		if s, ok := database.GetSettings(repo); ok && s.ArchiveQuotaMB != 0 {
+			mb = s.ArchiveQuotaMB
+		}
+		return int64(mb) << 20
+	}
+
+	return archive.New(filepath.Join(app.Cfg.DataDir, "archive"), database.Get,
+		archive.WithQuota(quota),
+		archive.WithAutoArchive(app.Server.ArchiveNew),
+		archive.WithLogger(app.Log),
+		archive.WithClient(client),
+		archive.WithPreviews(preview.New(filepath.Join(app.Cfg.CacheDir, "preview"), client)),
+	)
+}
+
+func setupServer(
+	app *application.App,
+	favicons *favicon.Cache,
+	checker *linkcheck.Checker,
+	archiver *archive.Archiver,
+	wb *wayback.Client,
+	client *http.Client,
+) *server.Server {
 	middle := []server.Middleware{
 		middleware.Logging,
 		middleware.PanicRecover,
@@ -120,7 +268,7 @@ func setupServer(app *application.App) *server.Server {
 	return server.New(
 		server.WithAddr(app.Flags.Addr),
 		server.WithLogger(app.Log),
-		server.WithMux(setupRoutes(app)),
+		server.WithMux(setupRoutes(app, favicons, checker, archiver, wb, client)),
 		server.WithMiddleware(middle...),
 		server.WithTLS(app.Server.CertFile, app.Server.KeyFile),
 	)
@@ -191,12 +339,35 @@ func setupLogger(fn string, verbosity int) (*os.File, *slog.Logger, error) {
 	return f, logger, nil
 }
 
-func registerCleanups(app *application.App, srv *server.Server) {
+func registerCleanups(
+	app *application.App,
+	srv *server.Server,
+	favicons *favicon.Cache,
+	checker *linkcheck.Checker,
+	archiver *archive.Archiver,
+) {
 	graceful.Register(func() error {
 		database.CloseAll()
 		return nil
 	})
 
+	// the favicon workers, the link checks and the archive jobs write to the
+	// repositories, so they stop before these are closed.
+	graceful.Register(func() error {
+		app.Log.Info("stopping favicon workers")
+		return favicons.Close()
+	})
+
+	graceful.Register(func() error {
+		app.Log.Info("stopping link checks")
+		return checker.Close()
+	})
+
+	graceful.Register(func() error {
+		app.Log.Info("stopping archive jobs")
+		return archiver.Close()
+	})
+
 	graceful.Register(func() error {
 		app.Log.Info("shutting down HTTP server")
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
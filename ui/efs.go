@@ -12,6 +12,7 @@ var Files embed.FS
 
 const (
 	FaviconCachePath       string = "/cache/favicon/"               // Path to read favicons
+	PreviewCachePath       string = "/cache/preview/"               // Path to read preview images
 	ColorschemesPath       string = "static/css/colorshemes"        // Path to colorschemes files
 	DefaultColorschemeFile string = "default-colors.css"            // Default colors
 	TemplatePattern        string = "templates/**/*.gohtml"         // Template files
@@ -44,6 +44,7 @@ const App = {
       Modal.Repository,
       Modal.SettingsApp,
       Modal.SideMenu,
+      Modal.TagsManager,
       Modal.Nav,
     ];
 
@@ -5,10 +5,12 @@ import repo from "../repo.js";
 import api from "../services/api.js";
 import routes from "../services/routes.js";
 import utils from "../utils/utils.js";
+import Bulk from "./bulk.js";
 import Edit from "./edit.js";
 import New from "./new.js";
 
 const BookmarkMgr = {
+  Bulk,
   Edit,
   New,
 
@@ -190,30 +192,40 @@ const BookmarkMgr = {
   },
 
   /**
-   * Fetches a snapshot from the Internet Archive for a given URL.
-   * @param {string} url - The URL to fetch the snapshot for.
+   * Fetches the Internet Archive snapshot of a bookmark and stores it. When
+   * there is none, offers to have the page saved.
+   * @param {string} id - The ID of the bookmark.
    * @param {HTMLElement} statusSpan - The element to display the fetching status and result.
    * @param {HTMLButtonElement} archiveBtn - The button element to control the loading state.
    * @returns {Promise<void>} A promise that resolves when the fetching process is complete.
    */
-  async fetchArchive(url, statusSpan, archiveBtn) {
+  async fetchArchive(id, statusSpan, archiveBtn) {
     statusSpan.classList.remove("error");
     statusSpan.textContent = "Fetching snapshot...";
     const spinner = utils.createBtnSpinner(archiveBtn, false);
     spinner.start();
 
+    const dbName = repo.getCurrent();
+
     try {
-      const res = await api.archiveURL(url);
+      let res = await api.waybackSnapshot(dbName, id);
+      if (res && res.status === 404 && confirm("No snapshot found. Ask the Internet Archive to save this page now?")) {
+        statusSpan.textContent = "Saving page...";
+        res = await api.waybackSnapshot(dbName, id, true);
+      }
 
-      if (res.ok) {
+      if (res && res.ok) {
         const data = await res.json();
         const link = this._createArchiveLink(data.archive_url, data.archive_timestamp);
         statusSpan.innerHTML = "";
         statusSpan.appendChild(link);
-      } else {
-        statusSpan.textContent = "Not found";
-        statusSpan.classList.add("snapshot-link", "error");
+        return;
       }
+
+      const data = res ? await res.json().catch(() => ({})) : {};
+      statusSpan.textContent = res && res.status === 404 ? "Not found" : "Unavailable";
+      statusSpan.title = data.error ?? "";
+      statusSpan.classList.add("snapshot-link", "error");
     } catch (e) {
       console.error(e);
     } finally {
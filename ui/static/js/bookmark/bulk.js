@@ -0,0 +1,150 @@
+// bulk.js
+
+import repo from "../repo.js";
+import api from "../services/api.js";
+
+/** Operations that need tags, or a target repository. */
+const TAG_OPS = ["add-tags", "remove-tags"];
+const MOVE_OP = "move";
+
+const Bulk = {
+  /**
+   * Wires the selection checkboxes of the bookmark list to the bulk toolbar.
+   * @returns {void}
+   */
+  init() {
+    const toolbar = document.getElementById("bulk-toolbar");
+    if (!toolbar) return;
+
+    document.addEventListener("change", (e) => {
+      if (e.target.matches(".bookmark-select")) this.update();
+    });
+    toolbar.querySelector("#bulk-select-all").addEventListener("change", (e) => {
+      this.checkboxes().forEach((el) => (el.checked = e.currentTarget.checked));
+      this.update();
+    });
+    toolbar.querySelector("#bulk-op").addEventListener("change", () => this.showInputs());
+    toolbar.querySelector("#btn-bulk-apply").addEventListener("click", () => this.apply());
+  },
+
+  /**
+   * Returns the selection checkboxes of the current page.
+   * @returns {HTMLInputElement[]} -
+   */
+  checkboxes() {
+    return [...document.querySelectorAll(".bookmark-select")];
+  },
+
+  /**
+   * Returns the IDs of the selected bookmarks.
+   * @returns {number[]} -
+   */
+  selectedIds() {
+    return this.checkboxes()
+      .filter((el) => el.checked)
+      .map((el) => Number(el.value));
+  },
+
+  /**
+   * Returns the filter of the current list view, as given in its URL.
+   * @returns {{tag: string, query: string, letter: string, filter_by: string}} -
+   */
+  filter() {
+    const q = new URLSearchParams(window.location.search);
+    return {
+      tag: q.get("tag") || "",
+      query: q.get("q") || "",
+      letter: q.get("letter") || "",
+      filter_by: q.get("filter") || "",
+    };
+  },
+
+  /**
+   * Shows the actions once a bookmark is selected, with the selection count.
+   * @returns {void}
+   */
+  update() {
+    const n = this.selectedIds().length;
+    const all = this.checkboxes();
+    const selectAll = document.getElementById("bulk-select-all");
+    selectAll.checked = n > 0 && n === all.length;
+    selectAll.indeterminate = n > 0 && n < all.length;
+    document.getElementById("bulk-count").textContent = n > 0 ? `${n} selected` : "Select";
+    document.getElementById("bulk-actions").classList.toggle("hidden", n === 0);
+    this.showInputs();
+  },
+
+  /**
+   * Shows the tags input or the repository picker the chosen operation needs.
+   * @async
+   * @returns {Promise<void>}
+   */
+  async showInputs() {
+    const op = document.getElementById("bulk-op").value;
+    const repoSelect = document.getElementById("bulk-repo");
+    document.getElementById("bulk-tags").classList.toggle("hidden", !TAG_OPS.includes(op));
+    repoSelect.classList.toggle("hidden", op !== MOVE_OP);
+
+    if (op !== MOVE_OP || repoSelect.options.length > 0) return;
+    const current = repo.getCurrent();
+    const databases = (await api.listDatabases()) ?? [];
+    databases
+      .filter((db) => db.name !== current)
+      .forEach((db) => repoSelect.add(new Option(db.name, db.name)));
+  },
+
+  /**
+   * Runs the chosen operation and reloads the list, reporting the failures.
+   * @async
+   * @returns {Promise<void>}
+   */
+  async apply() {
+    const op = document.getElementById("bulk-op").value;
+    const matching = document.getElementById("bulk-all-matching").checked;
+    const body = { op };
+    if (matching) {
+      body.filter = this.filter();
+      // the server refuses a filter that would select every bookmark.
+      const { tag, query, letter } = body.filter;
+      if (!tag && !query && !letter) {
+        alert("Filter the view by a tag, a search or a letter first.");
+        return;
+      }
+    } else {
+      body.ids = this.selectedIds();
+      if (body.ids.length === 0) return;
+    }
+
+    if (TAG_OPS.includes(op)) {
+      body.tags = document
+        .getElementById("bulk-tags")
+        .value.split(/[\s,]+/)
+        .map((t) => t.trim().replace(/^#/, ""))
+        .filter(Boolean);
+      if (body.tags.length === 0) return;
+    }
+    if (op === MOVE_OP) {
+      body.repo = document.getElementById("bulk-repo").value;
+      if (!body.repo) return;
+    }
+
+    const what = matching ? "every bookmark matching this view" : `${body.ids.length} bookmark(s)`;
+    if (!confirm(`Run "${op}" on ${what}?`)) return;
+
+    const btn = document.getElementById("btn-bulk-apply");
+    btn.disabled = true;
+    const res = await api.bulk(repo.getCurrent(), body);
+    btn.disabled = false;
+    if (!res) return;
+
+    const failed = res.items.filter((item) => !item.ok);
+    if (failed.length > 0) {
+      alert(
+        `${res.succeeded} of ${res.total} done.\n` + failed.map((item) => `#${item.id}: ${item.error}`).join("\n"),
+      );
+    }
+    window.location.reload();
+  },
+};
+
+export default Bulk;
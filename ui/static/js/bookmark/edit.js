@@ -81,11 +81,16 @@ const Edit = {
     utils.resizeTextArea(descInput);
     utils.resizeTextArea(titleInput);
 
+    const suggestions = modal.querySelector("#tag-suggestions");
+    const inputs = { urlInput, tagsInput, titleInput, descInput };
+
     tagsInput.value = tagOps.format(tagsInput.value);
     tagsInput.addEventListener("blur", () => {
       const raw = tagsInput.value || "";
       tagsInput.value = tagOps.format(raw);
+      bUtils.suggestTags(suggestions, inputs);
     });
+    bUtils.suggestTags(suggestions, inputs);
 
     // buttons
     const btnTitleRefresh = modal.querySelector("#btn-refresh-title");
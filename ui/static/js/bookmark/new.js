@@ -68,17 +68,23 @@ const New = {
     const descInput = modal.querySelector("#input-bookmark-desc");
     const faviconInput = modal.querySelector("#input-bookmark-favicon");
     const faviconPreview = modal.querySelector("#img-bookmark-favicon");
+    const suggestions = modal.querySelector("#tag-suggestions");
+    const inputs = { urlInput, tagsInput, titleInput, descInput };
 
     tagsInput.addEventListener("blur", () => {
       const raw = tagsInput.value || "";
       tagsInput.value = tagOps.format(raw);
+      bUtils.suggestTags(suggestions, inputs);
     });
 
     // accordion
     const accordionParams = modal.querySelector("#accordion-url-params");
 
     // fetch website data
-    const fetchTitleDebounced = utils.debounce(bUtils.scrapeURLData, 500);
+    const fetchTitleDebounced = utils.debounce(async (...args) => {
+      await bUtils.scrapeURLData(...args);
+      await bUtils.suggestTags(suggestions, inputs);
+    }, 500);
     const initialUrl = urlInput.value.trim();
     if (initialUrl) {
       fetchTitleDebounced(initialUrl, titleInput, descInput, tagsInput, faviconInput, faviconPreview);
@@ -211,6 +217,9 @@ const New = {
     // If your dropdown has leftover tags/autocomplete state, reset it too
     const dropdown = modal.querySelector("#dropdown-tags-cmp");
     dropdown.innerHTML = "";
+    const suggestions = modal.querySelector("#tag-suggestions");
+    suggestions.innerHTML = "";
+    suggestions.classList.add("hidden");
 
     // Reset URL Params accordion
     const accordion = modal.querySelector(".accordion");
@@ -1,6 +1,8 @@
 // params.js
 
 import config from "../config.js";
+import repo from "../repo.js";
+import api from "../services/api.js";
 import routes from "../services/routes.js";
 import { tagOps } from "../tags.js";
 import utils from "../utils/utils.js";
@@ -98,6 +100,17 @@ async function createUrlParamsList(urlInput, accordion) {
   }
 }
 
+/**
+ * Builds the scrape endpoint of a URL for the current repository, which
+ * decides whether the request bypasses the outbound proxy.
+ * @param {string} url The URL to scrape.
+ * @returns {string} The endpoint.
+ */
+function scrapeEndpoint(url) {
+  const params = new URLSearchParams({ url, db: repo.getCurrent() });
+  return `${routes.api.scrapeUrl}?${params}`;
+}
+
 /**
  * Scrapes metadata (title, description, tags, favicon) from a given URL and populates form fields.
  * @async
@@ -124,7 +137,7 @@ async function scrapeURLData(url, titleInput, descInput, tagsInput, faviconInput
   });
 
   try {
-    const response = await fetch(`${routes.api.scrapeUrl}?url=${encodeURIComponent(url)}`, {
+    const response = await fetch(scrapeEndpoint(url), {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -202,7 +215,7 @@ async function scrapeInput(url, targetInput, type) {
   targetInput.value = `Scraping ${type}...`;
 
   try {
-    const res = await fetch(`${routes.api.scrapeUrl}?url=${encodeURIComponent(url)}`, {
+    const res = await fetch(scrapeEndpoint(url), {
       method: "POST",
       headers: { "Content-Type": "application/json" },
     });
@@ -232,6 +245,58 @@ async function scrapeInput(url, targetInput, type) {
   }
 }
 
+/**
+ * Shows the tags suggested for the page as pills; clicking one adds it to
+ * the tags input.
+ * @async
+ * @param {HTMLElement} container The element holding the pills.
+ * @param {object} inputs The form inputs.
+ * @param {HTMLInputElement} inputs.urlInput The URL input.
+ * @param {HTMLInputElement} inputs.tagsInput The tags input.
+ * @param {HTMLTextAreaElement} inputs.titleInput The title input.
+ * @param {HTMLTextAreaElement} inputs.descInput The description input.
+ * @returns {Promise<void>}
+ */
+async function suggestTags(container, { urlInput, tagsInput, titleInput, descInput }) {
+  const url = urlInput.value.trim();
+  const chosen = () =>
+    tagsInput.value
+      .split(/[\s,]+/)
+      .map((t) => t.trim().replace(/^#/, ""))
+      .filter(Boolean);
+
+  container.innerHTML = "";
+  container.classList.add("hidden");
+  if (!url) return;
+
+  const suggestions = await api.suggestTags(repo.getCurrent(), {
+    url,
+    title: titleInput.value.trim(),
+    desc: descInput.value.trim(),
+    tags: chosen().join(","),
+  });
+  if (!suggestions.length) return;
+
+  suggestions.forEach(({ tag, reasons }) => {
+    const pill = document.createElement("button");
+    pill.type = "button";
+    pill.className = "tag-suggestion";
+    pill.textContent = tag;
+    pill.title = `Suggested from: ${reasons.join(", ")}`;
+    // keep the focus on the tags input, whose blur refreshes the pills
+    pill.addEventListener("mousedown", (e) => e.preventDefault());
+    pill.addEventListener("click", () => {
+      const tags = chosen();
+      if (!tags.some((t) => t.toLowerCase() === tag.toLowerCase())) tags.push(tag);
+      tagsInput.value = tagOps.format(tags.join(", "));
+      pill.remove();
+      if (!container.children.length) container.classList.add("hidden");
+    });
+    container.appendChild(pill);
+  });
+  container.classList.remove("hidden");
+}
+
 const disableInputs = (...inputs) => {
   inputs.forEach((input) => {
     input.disabled = true;
@@ -250,6 +315,7 @@ const bUtils = {
   createUrlParamsList: createUrlParamsList,
   scrapeInput: scrapeInput,
   scrapeURLData: scrapeURLData,
+  suggestTags: suggestTags,
 };
 
 export default bUtils;
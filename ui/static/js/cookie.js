@@ -6,6 +6,7 @@ const EXPIRY_ONE_YEAR = 60 * 60 * 24 * 365;
  * @typedef {object} CookieJar
  * @property {string} vim - VimMode
  * @property {string} compact - Compact UI
+ * @property {string} grid - Grid layout with preview images
  * @property {string} theme - Theme name
  * @property {string} themeMode - Theme mode
  * @property {string} itemsPage - Iterm per page
@@ -32,6 +33,7 @@ const Cookie = {
   jar: {
     vim: "vim_mode",
     compact: "compact_mode",
+    grid: "grid_mode",
     theme: "user_theme",
     themeMode: "theme_mode",
     itemsPage: "items_per_page",
@@ -59,6 +61,7 @@ const Cookie = {
       themeMode: Cookie.get(Cookie.jar.themeMode),
       vimMode: Cookie.get(Cookie.jar.vim) === "true",
       compactMode: Cookie.get(Cookie.jar.compact) === "true",
+      gridMode: Cookie.get(Cookie.jar.grid) === "true",
       itemsPerPage: parseInt(Cookie.get(Cookie.jar.itemsPage)) || 32,
     };
   },
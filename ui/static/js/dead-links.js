@@ -0,0 +1,27 @@
+// dead-links.js
+
+import api from "./services/api.js";
+
+const dbName = document.body.dataset.db;
+
+document.getElementById("btn-linkcheck-run")?.addEventListener("click", async (e) => {
+  const btn = e.currentTarget;
+  btn.disabled = true;
+  if (await api.runLinkCheck(dbName)) {
+    btn.textContent = "Check started";
+    return;
+  }
+  btn.disabled = false;
+});
+
+document.querySelectorAll(".btn-delete-dead").forEach((btn) => {
+  btn.addEventListener("click", async () => {
+    const id = btn.dataset.id;
+    if (!confirm("Delete this bookmark?")) return;
+
+    const res = await api.deleteRecord(dbName, id);
+    if (res?.ok) {
+      document.getElementById(`dead-${id}`)?.remove();
+    }
+  });
+});
@@ -0,0 +1,17 @@
+// duplicates.js
+
+import api from "./services/api.js";
+
+const dbName = document.body.dataset.db;
+
+document.querySelectorAll(".btn-duplicates-merge").forEach((btn) => {
+  btn.addEventListener("click", async () => {
+    const group = document.getElementById(`duplicates-${btn.dataset.group}`);
+    const ids = [...group.querySelectorAll(".duplicates-keep")].map((el) => Number(el.value));
+    const keep = Number(group.querySelector(".duplicates-keep:checked")?.value) || 0;
+    if (!confirm(`Merge ${ids.length} bookmarks into #${keep}? The others are deleted.`)) return;
+
+    const res = await api.mergeDuplicates(dbName, { ids, keep });
+    if (res) group.remove();
+  });
+});
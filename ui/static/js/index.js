@@ -2,6 +2,7 @@
 
 import App from "./app.js";
 import BookmarkMgr from "./bookmark/bookmark.js";
+import BookmarkDetail from "./modals/detail.js";
 
 /**
  * Sets up event delegation on the main document.
@@ -9,6 +10,7 @@ import BookmarkMgr from "./bookmark/bookmark.js";
 const IndexEvents = {
   init() {
     document.addEventListener("click", this.handleClick.bind(this));
+    BookmarkMgr.Bulk.init();
   },
 
   // --- Event Delegation ---
@@ -42,4 +44,8 @@ document.addEventListener("DOMContentLoaded", () => {
   App.init();
   App.setupModals();
   IndexEvents.init();
+
+  // Detail page: open the requested record.
+  const { openDetail } = document.body.dataset;
+  if (openDetail) BookmarkDetail.open(openDetail);
 });
@@ -2,18 +2,30 @@ import config from "./config.js";
 
 (function () {
   /**
-   * Sets up a fallback image for favicon elements that fail to load.
+   * Replaces the favicons that fail to load with the letter tile of their
+   * domain, falling back to the default favicon when the image carries no
+   * letter.
    * @returns {void}
    */
   function setupFaviconFallback() {
     const applyFallback = (img) => {
-      if (!img.dataset.fallbackApplied) {
-        img.dataset.fallbackApplied = "true";
+      if (img.dataset.fallbackApplied) return;
+      img.dataset.fallbackApplied = "true";
+
+      const { letter, color } = img.dataset;
+      if (!letter) {
         img.src = config.static.favicon;
+        return;
       }
+
+      const avatar = document.createElement("span");
+      avatar.className = `${img.className} favicon-avatar avatar-${color}`;
+      avatar.setAttribute("aria-hidden", "true");
+      avatar.textContent = letter;
+      img.replaceWith(avatar);
     };
 
-    document.querySelectorAll(".label-url-favicon").forEach((img) => {
+    document.querySelectorAll("img.label-url-favicon").forEach((img) => {
       if (img.complete && img.naturalWidth === 0) {
         applyFallback(img);
         return;
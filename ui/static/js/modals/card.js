@@ -2,6 +2,7 @@
 
 import BookmarkMgr from "../bookmark/bookmark.js";
 import config from "../config.js";
+import api from "../services/api.js";
 import utils from "../utils/utils.js";
 import BookmarkDetail from "./detail.js";
 import Manager from "./manager.js";
@@ -20,6 +21,7 @@ const ACTIONS = {
   EDIT: "edition",
   DELETE: "delete",
   DETAIL: "detail",
+  FAVICON: "favicon",
 };
 
 const BookmarkCard = {
@@ -95,6 +97,10 @@ const BookmarkCard = {
         this.openEditionModal(record.id);
         break;
       }
+      case ACTIONS.FAVICON: {
+        this.refreshFavicon(record.id);
+        break;
+      }
       case ACTIONS.DELETE: {
         // FIX: Deletion logic is not yet implemented.
         console.log("Deleting bookmark with ID:", record.id);
@@ -164,6 +170,32 @@ const BookmarkCard = {
     }
   },
 
+  /**
+   * Fetches the favicon of the bookmark again and updates the card icon.
+   * @async
+   * @param {string} id The bookmark ID.
+   */
+  async refreshFavicon(id) {
+    const b = await api.refreshFavicon(id);
+    if (!b) return;
+
+    const icon = document.querySelector(`.bookmark-card[data-id="${id}"] .label-url-favicon`);
+    if (!icon || !b.FaviconLocal) return;
+
+    // the card may show the letter tile of a domain that had no favicon.
+    if (icon.tagName !== "IMG") {
+      const img = document.createElement("img");
+      img.className = "label-url-favicon";
+      img.alt = "favicon";
+      img.dataset.letter = icon.textContent.trim();
+      img.dataset.color = [...icon.classList].find((c) => c.startsWith("avatar-"))?.slice(7) ?? "0";
+      icon.replaceWith(img);
+      img.src = `${b.FaviconLocal}?t=${Date.now()}`;
+      return;
+    }
+    icon.src = `${b.FaviconLocal}?t=${Date.now()}`;
+  },
+
   openEditionModal(id) {
     const modal = document.getElementById(`modal-edit-${id}`);
     const controller = Manager.register(modal);
@@ -1,6 +1,7 @@
 // detail.js
 
 import BookmarkMgr from "../bookmark/bookmark.js";
+import api from "../services/api.js";
 import config from "../config.js";
 import ModalNavigator from "../navigation/modal.js";
 import repo from "../repo.js";
@@ -61,6 +62,15 @@ const BookmarkDetail = {
     if (target.closest(".accordion-header")) return this.handleAccordion(target);
     // Handle `Save` notes button
     if (target.closest("#add-note-btn")) return this.addNote(target);
+    // Handle `Restore` revision button
+    if (target.closest(".btn-restore-revision")) return await this.restoreRevision(target);
+    // Handle snapshot buttons
+    if (target.closest(".btn-take-snapshot")) return await this.takeSnapshot(target);
+    if (target.closest(".btn-delete-snapshot")) return await this.deleteSnapshot(target);
+    // Handle `Refresh metadata` button
+    if (target.closest(".btn-scrape-metadata")) return await this.scrapeMetadata(target);
+    // Handle `Reader view` button
+    if (target.closest(".btn-reader-view")) return this.openReader(target);
     // Handle buttons (Edit, Delete)
     if (target.closest("button[data-id]")) return this.buttonsHandler(target);
     // Handle 'Refresh' button (status)
@@ -131,8 +141,7 @@ const BookmarkDetail = {
     const archiveBtn = target.closest("#btn-refresh-archive");
     const parent = archiveBtn.parentNode;
     const spanEle = parent.querySelector("#span-fetch-snapshot");
-    const url = archiveBtn.dataset.url;
-    return await BookmarkMgr.fetchArchive(url, spanEle, archiveBtn);
+    return await BookmarkMgr.fetchArchive(archiveBtn.dataset.bookmarkId, spanEle, archiveBtn);
   },
 
   async bookmarkStatus(target) {
@@ -191,6 +200,26 @@ const BookmarkDetail = {
       return this.openQRAccordion(accordion);
     }
 
+    // History accordion loads its content on open
+    if (accordion.querySelector("#accordion-history-list") && !accordion.classList.contains("open")) {
+      this.loadHistory(accordion);
+    }
+
+    // Snapshots accordion loads its content on open
+    if (accordion.querySelector("#accordion-snapshots-list") && !accordion.classList.contains("open")) {
+      this.loadSnapshots(accordion);
+    }
+
+    // Status accordion shows the URL the bookmark was saved with, if cleaned
+    if (accordion.querySelector("#meta-original-url") && !accordion.classList.contains("open")) {
+      this.loadOriginalURL(accordion);
+    }
+
+    // Metadata accordion loads its content on open
+    if (accordion.querySelector("#accordion-metadata-list") && !accordion.classList.contains("open")) {
+      this.loadMetadata(accordion);
+    }
+
     // Toggle accordion
     accordion.classList.toggle("open");
     const isOpen = accordion.classList.contains("open");
@@ -243,6 +272,221 @@ const BookmarkDetail = {
     toggleBtn.setAttribute("aria-expanded", "true");
   },
 
+  /**
+   * Shows the URL the bookmark was saved with before the URL rules of the
+   * database cleaned it.
+   * @async
+   * @param {HTMLElement} accordion The status accordion.
+   */
+  async loadOriginalURL(accordion) {
+    const item = accordion.querySelector("#meta-original-url");
+    const original = await api.originalURL(repo.getCurrent(), item.dataset.id);
+    if (!original) return;
+
+    const link = item.querySelector("a");
+    link.href = original.url;
+    link.textContent = original.url;
+    link.title = `Cleaned ${new Date(original.cleaned_at).toLocaleString()}`;
+    item.classList.remove("hidden");
+  },
+
+  /**
+   * Renders the revisions of the bookmark, each with the changes made right
+   * after it.
+   * @async
+   * @param {HTMLElement} accordion The history accordion.
+   */
+  async loadHistory(accordion) {
+    const list = accordion.querySelector("#accordion-history-list");
+    list.replaceChildren();
+
+    const history = await api.bookmarkHistory(accordion.dataset.id);
+    if (!history) return;
+
+    if (!history.revisions.length) {
+      const empty = document.createElement("li");
+      empty.className = "history-empty";
+      empty.textContent = "No changes recorded yet.";
+      list.appendChild(empty);
+      return;
+    }
+
+    history.revisions.forEach((rev) => {
+      const item = document.createElement("li");
+      item.className = "history-entry";
+
+      const header = document.createElement("div");
+      header.className = "history-entry-header";
+      const when = document.createElement("span");
+      when.textContent = new Date(rev.created_at).toLocaleString();
+      when.title = rev.user ? `by ${rev.user}` : "";
+      header.appendChild(when);
+
+      const restore = document.createElement("button");
+      restore.type = "button";
+      restore.className = "btn btn-secondary btn-restore-revision requires-write";
+      restore.dataset.id = history.bookmark_id;
+      restore.dataset.rev = rev.id;
+      restore.textContent = "Restore";
+      header.appendChild(restore);
+      item.appendChild(header);
+
+      rev.changes.forEach((c) => {
+        const row = document.createElement("div");
+        row.className = "history-change";
+        const field = document.createElement("strong");
+        field.textContent = `${c.field}: `;
+        const oldVal = document.createElement("del");
+        oldVal.className = "diff-old";
+        oldVal.textContent = c.old || "∅";
+        const newVal = document.createElement("ins");
+        newVal.className = "diff-new";
+        newVal.textContent = c.new || "∅";
+        row.append(field, oldVal, " → ", newVal);
+        item.appendChild(row);
+      });
+
+      list.appendChild(item);
+    });
+  },
+
+  async restoreRevision(target) {
+    const btn = target.closest(".btn-restore-revision");
+    if (!confirm("Restore this version?")) return;
+    if (await api.restoreRevision(btn.dataset.id, btn.dataset.rev)) window.location.reload();
+  },
+
+  /**
+   * Renders the local snapshots of the bookmark, newest first.
+   * @async
+   * @param {HTMLElement} accordion The snapshots accordion.
+   */
+  async loadSnapshots(accordion) {
+    const list = accordion.querySelector("#accordion-snapshots-list");
+    list.replaceChildren();
+
+    const data = await api.bookmarkSnapshots(accordion.dataset.id);
+    if (!data) return;
+
+    if (!data.snapshots.length) {
+      const empty = document.createElement("li");
+      empty.className = "history-empty";
+      empty.textContent = "No snapshots saved yet.";
+      list.appendChild(empty);
+      return;
+    }
+
+    data.snapshots.forEach((s) => {
+      const item = document.createElement("li");
+      item.className = "history-entry-header";
+
+      const link = document.createElement("a");
+      link.href = `${data.view_url}?snapshot=${s.id}`;
+      link.target = "_blank";
+      link.rel = "noopener noreferrer";
+      link.textContent = new Date(s.created_at).toLocaleString();
+      link.title = `${(s.size / 1024).toFixed(0)} KiB`;
+      item.appendChild(link);
+
+      const del = document.createElement("button");
+      del.type = "button";
+      del.className = "btn btn-secondary btn-delete-snapshot requires-write";
+      del.dataset.bookmarkId = data.bookmark_id;
+      del.dataset.snapshot = s.id;
+      del.textContent = "Delete";
+      item.appendChild(del);
+
+      list.appendChild(item);
+    });
+  },
+
+  async loadMetadata(accordion) {
+    const list = accordion.querySelector("#accordion-metadata-list");
+    list.replaceChildren();
+
+    const data = await api.bookmarkMetadata(accordion.dataset.id);
+    if (data === undefined) return;
+    if (data === null) {
+      const empty = document.createElement("p");
+      empty.className = "history-empty";
+      empty.textContent = "No metadata saved yet.";
+      list.appendChild(empty);
+      return;
+    }
+
+    const fields = [
+      ["Site", data.site_name],
+      ["Type", data.type],
+      ["Author", data.author],
+      ["Published", data.published && new Date(data.published).toLocaleDateString()],
+      ["Language", data.lang],
+      ["Canonical", data.canonical, true],
+      ["Image", data.image, true],
+      ["Scraped", new Date(data.scraped_at).toLocaleString()],
+    ];
+    fields.forEach(([label, value, isLink]) => {
+      if (!value) return;
+      const dt = document.createElement("dt");
+      dt.textContent = label;
+      const dd = document.createElement("dd");
+      if (isLink) {
+        const link = document.createElement("a");
+        link.href = value;
+        link.target = "_blank";
+        link.rel = "noopener noreferrer";
+        link.textContent = value;
+        dd.appendChild(link);
+      } else {
+        dd.textContent = value;
+      }
+      list.append(dt, dd);
+    });
+
+    if (data.preview) {
+      const dt = document.createElement("dt");
+      dt.textContent = "Preview";
+      const dd = document.createElement("dd");
+      const img = document.createElement("img");
+      img.className = "metadata-preview";
+      img.src = routes.front.preview(data.preview, "small");
+      img.alt = "preview";
+      img.loading = "lazy";
+      dd.appendChild(img);
+      list.append(dt, dd);
+    }
+  },
+
+  async scrapeMetadata(target) {
+    const btn = target.closest(".btn-scrape-metadata");
+    const spinner = utils.createBtnSpinner(btn, false);
+    spinner.start();
+    const ok = await api.scrapeMetadata(btn.dataset.bookmarkId);
+    spinner.stop();
+    if (ok) this.loadMetadata(btn.closest(".accordion"));
+  },
+
+  openReader(target) {
+    const btn = target.closest(".btn-reader-view");
+    window.location.href = routes.front.readBookmark(repo.getCurrent(), btn.dataset.bookmarkId);
+  },
+
+  async takeSnapshot(target) {
+    const btn = target.closest(".btn-take-snapshot");
+    const spinner = utils.createBtnSpinner(btn, false);
+    spinner.start();
+    const ok = await api.createSnapshot(btn.dataset.bookmarkId);
+    spinner.stop();
+    if (ok) this.loadSnapshots(btn.closest(".accordion"));
+  },
+
+  async deleteSnapshot(target) {
+    const btn = target.closest(".btn-delete-snapshot");
+    if (!confirm("Delete this snapshot?")) return;
+    if (await api.deleteSnapshot(btn.dataset.bookmarkId, btn.dataset.snapshot)) {
+      this.loadSnapshots(btn.closest(".accordion"));
+    }
+  },
+
   toggleAllParams(target) {
     const allParams = target.closest(".accordion-content").querySelector("#url-useless-params");
     console.log("toggle-all-params", allParams);
@@ -11,6 +11,7 @@ import QRCode from "./qrcode.js";
 import Repository from "./repo.js";
 import SettingsApp from "./settings.js";
 import SideMenu from "./sidemenu.js";
+import TagsManager from "./tags.js";
 
 /**
  * UI utilities for showing modals, side menus, and dropdowns.
@@ -41,6 +42,7 @@ const Modal = {
   Repository,
   SettingsApp,
   SideMenu,
+  TagsManager,
 };
 
 export default Modal;
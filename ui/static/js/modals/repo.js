@@ -18,6 +18,21 @@ const Repository = {
     if (target.matches("#current-repo-info")) return await this.open();
     // Handle 'Change Repo' button or 'Repositories' button in Side menu
     if (target.closest("#btn-list-repos")) return await this.openRepoList();
+    // Handle integrity check and vacuum
+    if (target.closest("#btn-repo-maintenance")) {
+      e.preventDefault();
+      return await this.maintenance();
+    }
+    // Handle favicons refresh
+    if (target.closest("#btn-repo-favicons")) {
+      e.preventDefault();
+      return await this.refreshFavicons();
+    }
+    // Handle read-only toggle
+    if (target.closest("#btn-repo-readonly")) {
+      e.preventDefault();
+      return await this.toggleReadOnly(target.closest("#btn-repo-readonly"));
+    }
   },
 
   /**
@@ -42,6 +57,16 @@ const Repository = {
       modal.querySelector("#repo-info-count").innerText = dbInfo.bookmarks;
       modal.querySelector("#repo-info-fav-count").innerText = dbInfo.favorites;
       modal.querySelector("#repo-info-tag-count").innerText = dbInfo.tags;
+      modal.querySelector("#repo-info-schema").innerText = `v${dbInfo.schema_version}`;
+      modal.querySelector("#repo-info-readonly").innerText = dbInfo.read_only ? "read-only" : "read-write";
+      modal.querySelector("#repo-info-archive").innerText = `${(dbInfo.archive_size / 1048576).toFixed(1)} MiB`;
+      const favicons = dbInfo.favicons;
+      modal.querySelector("#repo-info-favicons").innerText = favicons
+        ? `${favicons.files} (${(favicons.bytes / 1024).toFixed(1)} KiB), ${favicons.failures} failing`
+        : "disabled";
+      const btnReadOnly = modal.querySelector("#btn-repo-readonly");
+      btnReadOnly.dataset.readOnly = dbInfo.read_only;
+      btnReadOnly.querySelector("span").innerText = dbInfo.read_only ? "Unlock" : "Lock";
     } catch (error) {
       console.error("Error fetching DB info:", error);
       alert("Failed to load database information. Please try again.");
@@ -51,6 +76,56 @@ const Repository = {
     controller.open();
   },
 
+  /**
+   * Runs the integrity check and vacuum on the current database and shows
+   * the report in the repository modal.
+   * @async
+   */
+  async maintenance() {
+    const output = document.getElementById("repo-maintenance-result");
+    output.classList.remove("error", "success");
+    output.innerText = "Checking...";
+
+    const report = await api.maintainDatabase(repo.getCurrent());
+    if (!report) {
+      output.classList.add("error");
+      output.innerText = "Maintenance failed, see the server log.";
+      return;
+    }
+
+    const kb = (n) => `${(n / 1024).toFixed(1)} KB`;
+    output.classList.add(report.ok ? "success" : "error");
+    output.innerText = report.ok
+      ? `Integrity OK${report.vacuumed ? `, vacuumed ${kb(report.size_before)} → ${kb(report.size_after)}` : ""} (${report.duration})`
+      : `Integrity check failed: ${report.integrity.join("; ")}`;
+  },
+
+  /**
+   * Starts refreshing every favicon of the current database and reports it
+   * in the repository modal.
+   * @async
+   */
+  async refreshFavicons() {
+    const output = document.getElementById("repo-maintenance-result");
+    output.classList.remove("error", "success");
+
+    const ok = await api.refreshFavicons(repo.getCurrent());
+    output.classList.add(ok ? "success" : "error");
+    output.innerText = ok ? "Refreshing favicons in the background..." : "Favicon refresh failed.";
+  },
+
+  /**
+   * Toggles the read-only mode of the current database and reloads the page
+   * so the edit controls match the new mode.
+   * @async
+   * @param {HTMLElement} btn - The toggle button.
+   */
+  async toggleReadOnly(btn) {
+    const readOnly = btn.dataset.readOnly !== "true";
+    const res = await api.setReadOnly(repo.getCurrent(), readOnly);
+    if (res) window.location.reload();
+  },
+
   async openRepoList() {
     const repos = await api.listDatabases();
     return repo.renderList(repos);
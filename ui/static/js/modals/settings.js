@@ -2,9 +2,22 @@
 
 import config from "../config.js";
 import Cookie from "../cookie.js";
+import repo from "../repo.js";
+import api from "../services/api.js";
 import utils from "../utils/utils.js";
 import Manager from "./manager.js";
 
+/**
+ * Splits a comma separated list.
+ * @param {string} value The raw input value.
+ * @returns {string[]} The items.
+ */
+const splitList = (value) =>
+  value
+    .split(",")
+    .map((s) => s.trim())
+    .filter(Boolean);
+
 /**
  * Settings manages the initialization and event handling for the settings modal.
  * @namespace SettingsApp
@@ -27,6 +40,7 @@ const SettingsApp = {
     this.modal = document.getElementById("modal-settings");
     this.setVimMode();
     this.setCompactMode();
+    this.setGridMode();
     this.setThemeMode();
     this.setItemsPerPage();
   },
@@ -37,6 +51,8 @@ const SettingsApp = {
 
     // Handle open settings modal
     if (target.closest("#btn-settings")) return this.open();
+    // Handle `Save rules` of the URL cleaning section
+    if (target.closest("#btn-url-rules-save")) return this.saveURLRules();
     // Handle `Theme` selection
     // if (target.closest("#select-theme")) return this.selectTheme(target);
     // // Handle `CompactMode` toggle
@@ -59,9 +75,44 @@ const SettingsApp = {
    */
   open() {
     const controller = Manager.register(this.modal);
+    this.loadURLRules();
     controller.open();
   },
 
+  /**
+   * Fills the URL cleaning section with the rules of the current database.
+   * @async
+   */
+  async loadURLRules() {
+    const rules = (await api.getURLRules(repo.getCurrent())) || {};
+    this.modal.querySelector("#url-rules-strip-tracking").checked = !!rules.strip_tracking;
+    this.modal.querySelector("#url-rules-strip-params").value = (rules.strip_params || []).join(", ");
+    this.modal.querySelector("#url-rules-force-https").checked = !!rules.force_https;
+    this.modal.querySelector("#url-rules-resolve").checked = !!rules.resolve_shorteners;
+    this.modal.querySelector("#url-rules-shorteners").value = (rules.shorteners || []).join(", ");
+    this.modal.querySelector("#url-rules-result").innerText = "";
+  },
+
+  /**
+   * Saves the URL cleaning rules applied to the bookmarks saved from now on.
+   * @async
+   */
+  async saveURLRules() {
+    const output = this.modal.querySelector("#url-rules-result");
+    output.classList.remove("success");
+
+    const rules = {
+      strip_tracking: this.modal.querySelector("#url-rules-strip-tracking").checked,
+      strip_params: splitList(this.modal.querySelector("#url-rules-strip-params").value),
+      force_https: this.modal.querySelector("#url-rules-force-https").checked,
+      resolve_shorteners: this.modal.querySelector("#url-rules-resolve").checked,
+      shorteners: splitList(this.modal.querySelector("#url-rules-shorteners").value),
+    };
+    if (!(await api.setURLRules(repo.getCurrent(), rules))) return;
+    output.classList.add("success");
+    output.innerText = "Rules saved.";
+  },
+
   selectTheme(target) {
     const themeSelect = target.closest("#select-theme");
     Cookie.set(Cookie.jar.theme, themeSelect.value);
@@ -74,6 +125,11 @@ const SettingsApp = {
     Cookie.set(Cookie.jar.compact, compactModeToggle.checked);
   },
 
+  toggleGridMode(target) {
+    const gridModeToggle = target.closest("#checkbox-grid-mode");
+    Cookie.set(Cookie.jar.grid, gridModeToggle.checked);
+  },
+
   toggleVimMode(target) {
     const vimModeToggle = target.closest("#checkbox-vim-mode");
     Cookie.set(Cookie.jar.vim, vimModeToggle.checked);
@@ -118,6 +174,14 @@ const SettingsApp = {
     compactModeToggle.checked = cookieValue === "true";
   },
 
+  setGridMode() {
+    const gridModeToggle = this.modal.querySelector("#checkbox-grid-mode");
+    if (!gridModeToggle) return;
+
+    const cookieValue = Cookie.get(Cookie.jar.grid);
+    gridModeToggle.checked = cookieValue === "true";
+  },
+
   setThemeMode() {
     const settingsToggle = this.modal.querySelector("#settings-dark-mode");
     if (!settingsToggle) return;
@@ -0,0 +1,237 @@
+// tags.js
+
+import api from "../services/api.js";
+import Manager from "./manager.js";
+import repo from "../repo.js";
+import { tagOps } from "../tags.js";
+
+/**
+ * Splits a comma or space separated list of tags.
+ * @param {string} value The raw input value.
+ * @returns {string[]} The tags.
+ */
+const splitTags = (value) =>
+  value
+    .split(/[\s,]+/)
+    .map((t) => t.trim().replace(/^#/, ""))
+    .filter(Boolean);
+
+const TagsManager = {
+  /**
+   * The operation waiting for confirmation.
+   * @type {{op: string, body: object}|null}
+   */
+  pending: null,
+
+  init() {
+    document.addEventListener("click", this.handleClick.bind(this));
+    document.addEventListener("input", (e) => {
+      if (e.target.matches("#tags-manager-filter")) this.filter(e.target.value);
+    });
+  },
+
+  // --- Event Delegation ---
+  async handleClick(e) {
+    const { target } = e;
+
+    if (target.closest("#btn-tags-manager")) {
+      e.preventDefault();
+      return await this.open();
+    }
+    const btn = target.closest("#modal-tags [data-op]");
+    if (btn) return await this.preview(btn.dataset.op);
+    if (target.closest("#btn-tags-apply")) return await this.apply();
+    if (target.closest("#btn-tags-cancel")) return this.reset();
+    if (target.closest("#btn-tags-aliases")) return await this.saveAliases();
+    if (target.closest("#btn-tags-rules")) return await this.saveRules();
+  },
+
+  async open() {
+    const modal = document.getElementById("modal-tags");
+    const controller = Manager.register(modal);
+    await this.load();
+    controller.open();
+  },
+
+  /**
+   * Lists the tags of the current database with their use, and its alias
+   * and normalisation rules.
+   * @async
+   */
+  async load() {
+    const list = document.getElementById("tags-manager-list");
+    const tags = (await tagOps.fetch()) || [];
+
+    const aliases = (await api.getTagAliases(repo.getCurrent())) || {};
+    document.getElementById("tags-manager-aliases").value = Object.entries(aliases)
+      .map(([from, to]) => `${from}=${to}`)
+      .sort()
+      .join("\n");
+
+    const rules = (await api.getTagRules(repo.getCurrent())) || {};
+    document.getElementById("tags-rules-lowercase").checked = !!rules.lowercase;
+    document.getElementById("tags-rules-max-length").value = rules.max_length || "";
+    document.getElementById("tags-rules-charset").value = rules.charset || "";
+
+    list.innerHTML = "";
+    tags.forEach(({ name, count }) => {
+      const li = document.createElement("li");
+      li.dataset.tag = name;
+      li.innerHTML = `<label><input type="checkbox" class="minimal-checkbox" /> <span></span></label>
+        <span class="tags-manager-count">${count}</span>`;
+      li.querySelector("input").value = name;
+      li.querySelector("span").textContent = name;
+      list.appendChild(li);
+    });
+
+    this.reset();
+  },
+
+  /**
+   * Hides the tags not containing the query.
+   * @param {string} query The filter.
+   */
+  filter(query) {
+    const q = query.trim().toLowerCase();
+    document.querySelectorAll("#tags-manager-list li").forEach((li) => {
+      li.classList.toggle("hidden", q !== "" && !li.dataset.tag.toLowerCase().includes(q));
+    });
+  },
+
+  /** @returns {string[]} The checked tags. */
+  selected() {
+    return [...document.querySelectorAll("#tags-manager-list input:checked")].map((i) => i.value);
+  },
+
+  /**
+   * Builds the request of the operation from the modal inputs.
+   * @param {string} op The operation.
+   * @returns {object|null} The request body, or null when incomplete.
+   */
+  request(op) {
+    const to = document.getElementById("tags-manager-target").value.trim().replace(/^#/, "");
+    const tags = this.selected();
+
+    switch (op) {
+      case "rename":
+        return tags.length === 1 && to ? { tags, to } : null;
+      case "merge":
+        return tags.length > 0 && to ? { tags, to } : null;
+      case "delete":
+        return tags.length > 0 ? { tags } : null;
+      case "normalize":
+        return {};
+      case "retag": {
+        const ids = [...new Set([...document.querySelectorAll(".bookmark-card[data-id]")].map((c) => Number(c.dataset.id)))];
+        const add = splitTags(document.getElementById("tags-manager-add").value);
+        const remove = splitTags(document.getElementById("tags-manager-remove").value);
+        return ids.length && (add.length || remove.length) ? { ids, add, remove } : null;
+      }
+      default:
+        return null;
+    }
+  },
+
+  /**
+   * Shows the bookmarks and tag counts the operation would change.
+   * @async
+   * @param {string} op The operation.
+   */
+  async preview(op) {
+    const output = document.getElementById("tags-manager-preview");
+    output.classList.remove("error", "success");
+
+    const body = this.request(op);
+    if (!body) {
+      output.classList.add("error");
+      output.innerText = {
+        rename: "Select one tag and type its new name.",
+        merge: "Select the tags and type the tag to merge them into.",
+        delete: "Select the tags to delete.",
+        retag: "Type the tags to add or remove.",
+      }[op];
+      return;
+    }
+
+    const res = await api.tagsOp(repo.getCurrent(), op, { ...body, preview: true });
+    if (!res) return;
+
+    const counts = Object.keys(res.before)
+      .map((t) => `${t}: ${res.before[t]} → ${res.after[t]}`)
+      .join(", ");
+    output.classList.add("success");
+    output.innerText = `${res.affected} bookmark(s) affected. ${counts}`;
+
+    this.pending = res.affected > 0 ? { op, body } : null;
+    document.getElementById("tags-manager-confirm").classList.toggle("hidden", !this.pending);
+  },
+
+  /**
+   * Applies the previewed operation and reloads the page to show the new tags.
+   * @async
+   */
+  async apply() {
+    if (!this.pending) return;
+
+    const { op, body } = this.pending;
+    const res = await api.tagsOp(repo.getCurrent(), op, body);
+    if (!res) return;
+
+    const failed = Object.keys(res.failed || {}).length;
+    if (failed) alert(`${failed} bookmark(s) could not be updated, see the server log.`);
+    window.location.reload();
+  },
+
+  /**
+   * Saves the alias rules typed one per line as from=to.
+   * @async
+   */
+  async saveAliases() {
+    const output = document.getElementById("tags-manager-preview");
+    output.classList.remove("error", "success");
+
+    const aliases = {};
+    for (const line of document.getElementById("tags-manager-aliases").value.split("\n")) {
+      if (!line.trim()) continue;
+      const [from, to] = line.split("=").map((s) => s.trim());
+      if (!from || !to) {
+        output.classList.add("error");
+        output.innerText = `Invalid alias "${line}", expected from=to.`;
+        return;
+      }
+      aliases[from] = to;
+    }
+
+    if (!(await api.setTagAliases(repo.getCurrent(), aliases))) return;
+    output.classList.add("success");
+    output.innerText = `${Object.keys(aliases).length} alias(es) saved.`;
+  },
+
+  /**
+   * Saves the normalisation rules applied to the tags written from now on.
+   * @async
+   */
+  async saveRules() {
+    const output = document.getElementById("tags-manager-preview");
+    output.classList.remove("error", "success");
+
+    const rules = {
+      lowercase: document.getElementById("tags-rules-lowercase").checked,
+      max_length: Number(document.getElementById("tags-rules-max-length").value) || 0,
+      charset: document.getElementById("tags-rules-charset").value.trim(),
+    };
+    if (!(await api.setTagRules(repo.getCurrent(), rules))) return;
+    output.classList.add("success");
+    output.innerText = "Rules saved. Use 'Normalise existing tags' to apply them to the stored tags.";
+  },
+
+  reset() {
+    this.pending = null;
+    const output = document.getElementById("tags-manager-preview");
+    output.classList.remove("error", "success");
+    output.innerText = "";
+    document.getElementById("tags-manager-confirm").classList.add("hidden");
+  },
+};
+
+export default TagsManager;
@@ -0,0 +1,20 @@
+// reader.js
+
+import api from "./services/api.js";
+
+const dbName = document.body.dataset.db;
+
+document.getElementById("btn-extract-article")?.addEventListener("click", async (e) => {
+  const btn = e.currentTarget;
+  btn.disabled = true;
+  btn.textContent = "Extracting...";
+
+  const article = await api.extractArticle(dbName, btn.dataset.id);
+  if (article) {
+    window.location.reload();
+    return;
+  }
+
+  btn.disabled = false;
+  btn.textContent = "Extract article";
+});
@@ -0,0 +1,63 @@
+// redirects.js
+
+import api from "./services/api.js";
+
+const dbName = document.body.dataset.db;
+
+/**
+ * Returns the bookmark IDs of the selected rows.
+ * @returns {number[]} -
+ */
+function selectedIds() {
+  return [...document.querySelectorAll(".redirect-select:checked")].map((el) => Number(el.value));
+}
+
+/**
+ * Removes the rows of the handled redirects.
+ * @param {object|false|undefined} res The result of the request.
+ */
+function removeRows(res) {
+  if (!res) return;
+
+  [...(res.accepted ?? []), ...(res.dismissed ?? [])].forEach((id) => {
+    document.getElementById(`redirect-${id}`)?.remove();
+  });
+
+  const failed = Object.entries(res.failed ?? {});
+  if (failed.length > 0) {
+    alert(failed.map(([id, err]) => `#${id}: ${err}`).join("\n"));
+  }
+}
+
+document.getElementById("redirects-select-all")?.addEventListener("change", (e) => {
+  document.querySelectorAll(".redirect-select").forEach((el) => (el.checked = e.currentTarget.checked));
+});
+
+document.getElementById("btn-redirects-accept")?.addEventListener("click", async () => {
+  const ids = selectedIds();
+  if (ids.length === 0) return;
+  removeRows(await api.acceptRedirects(dbName, { ids }));
+});
+
+document.getElementById("btn-redirects-accept-permanent")?.addEventListener("click", async () => {
+  if (!confirm("Accept every permanent redirect?")) return;
+  removeRows(await api.acceptRedirects(dbName, { all: true, permanent_only: true }));
+});
+
+document.getElementById("btn-redirects-dismiss")?.addEventListener("click", async () => {
+  const ids = selectedIds();
+  if (ids.length === 0) return;
+  removeRows(await api.dismissRedirects(dbName, { ids }));
+});
+
+document.querySelectorAll(".btn-redirect-accept").forEach((btn) => {
+  btn.addEventListener("click", async () => {
+    removeRows(await api.acceptRedirects(dbName, { ids: [Number(btn.dataset.id)] }));
+  });
+});
+
+document.querySelectorAll(".btn-redirect-dismiss").forEach((btn) => {
+  btn.addEventListener("click", async () => {
+    removeRows(await api.dismissRedirects(dbName, { ids: [Number(btn.dataset.id)] }));
+  });
+});
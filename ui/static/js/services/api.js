@@ -149,12 +149,14 @@ const api = {
   },
 
   /**
-   * Sends a URL to the Internet Archive to be saved.
+   * Looks up the Wayback Machine snapshot of a bookmark and stores it.
    * @async
-   * @param {string} url The URL to be archived.
-   * @returns {Promise<object|string|false|undefined>} -
+   * @param {string} dbName The database name.
+   * @param {string} id The unique ID of the bookmark.
+   * @param {boolean} [save=false] Ask the Wayback Machine to save the page first.
+   * @returns {Promise<Response|false|undefined>} -
    */
-  async archiveURL(url) {
+  async waybackSnapshot(dbName, id, save = false) {
     if (!config.security.csrfToken() && !window.DEV_MODE) {
       console.error("Error: CSRF token is missing. The request was not sent.");
       alert("An internal error occurred. Please refresh the page and try again.");
@@ -162,17 +164,16 @@ const api = {
     }
 
     try {
-      const res = await fetch(`${routes.api.archiveUrl}?url=${encodeURIComponent(url)}`, {
+      const url = routes.api.wayback(dbName, id) + (save ? "?save=true" : "");
+      return await fetch(url, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
           "X-CSRF-Token": config.security.csrfToken(),
         },
       });
-
-      return await res.json();
     } catch (error) {
-      console.error("Network or parsing error fetching URL data:", error);
+      console.error("Network error fetching the Wayback Machine snapshot:", error);
     }
   },
 
@@ -280,6 +281,813 @@ const api = {
     }
   },
 
+  /**
+   * Fetches the revisions of a bookmark.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<object|undefined>} The history, newest revision first.
+   */
+  async bookmarkHistory(id) {
+    try {
+      const res = await fetch(routes.api.bookmarkHistory(repo.getCurrent(), id));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error fetching history:", res.status, res.statusText, data.error);
+        return;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to fetch history: ${error.message}`);
+    }
+  },
+
+  /**
+   * Restores a bookmark to the given revision.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @param {string} rev The revision ID.
+   * @returns {Promise<boolean>} Whether the revision was restored.
+   */
+  async restoreRevision(id, rev) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.restoreRevision(repo.getCurrent(), id, rev), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error restoring revision:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to restore revision: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Fetches the local snapshots of a bookmark.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<object|undefined>} The snapshots, newest first.
+   */
+  async bookmarkSnapshots(id) {
+    try {
+      const res = await fetch(routes.api.snapshots(repo.getCurrent(), id));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error fetching snapshots:", res.status, res.statusText, data.error);
+        return;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to fetch snapshots: ${error.message}`);
+    }
+  },
+
+  /**
+   * Saves a local snapshot of a bookmark's page.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<boolean>} Whether the snapshot was saved.
+   */
+  async createSnapshot(id) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.snapshots(repo.getCurrent(), id), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error saving snapshot:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to save snapshot: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Deletes a local snapshot of a bookmark.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @param {string} sid The snapshot ID.
+   * @returns {Promise<boolean>} Whether the snapshot was deleted.
+   */
+  async deleteSnapshot(id, sid) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.snapshot(repo.getCurrent(), id, sid), {
+        method: "DELETE",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error deleting snapshot:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to delete snapshot: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Runs an integrity check and vacuum on a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<object|false|undefined>} The maintenance report.
+   */
+  async maintainDatabase(dbName) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.maintainDb(dbName), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error running maintenance:", res.status, res.statusText, data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to run maintenance: ${error.message}`);
+    }
+  },
+
+  async setReadOnly(dbName, readOnly) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.readOnlyDb(dbName), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify({ read_only: readOnly }),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error setting read-only mode:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to set read-only mode: ${error.message}`);
+    }
+  },
+
+  /**
+   * Starts checking every link of a database in the background.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<boolean>} Whether the check was started.
+   */
+  async runLinkCheck(dbName) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.linkCheck(dbName), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error starting link check:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to start link check: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Moves the selected bookmarks to the final URL of their redirect.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{ids?: number[], all?: boolean, permanent_only?: boolean}} selection The redirects to accept.
+   * @returns {Promise<object|false|undefined>} The accepted and failed IDs.
+   */
+  async acceptRedirects(dbName, selection) {
+    return this.postRedirects(routes.api.acceptRedirects(dbName), selection);
+  },
+
+  /**
+   * Discards the selected redirects, keeping the stored URLs.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{ids?: number[], all?: boolean}} selection The redirects to dismiss.
+   * @returns {Promise<object|false|undefined>} The dismissed and failed IDs.
+   */
+  async dismissRedirects(dbName, selection) {
+    return this.postRedirects(routes.api.dismissRedirects(dbName), selection);
+  },
+
+  /**
+   * Extracts and stores the readable article of a bookmark.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {string} id The unique ID of the bookmark.
+   * @returns {Promise<object|false|undefined>} The extracted article.
+   */
+  async extractArticle(dbName, id) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.article(dbName, id), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error extracting article:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to extract article: ${error.message}`);
+    }
+  },
+
+  /**
+   * Fetches the saved metadata of a bookmark's page.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<object|null|undefined>} The metadata, null when none was saved.
+   */
+  async bookmarkMetadata(id) {
+    try {
+      const res = await fetch(routes.api.metadata(repo.getCurrent(), id));
+      const data = await res.json();
+      if (res.status === 404) return null;
+      if (!res.ok) {
+        console.error("Error fetching metadata:", res.status, res.statusText, data.error);
+        return;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to fetch metadata: ${error.message}`);
+    }
+  },
+
+  /**
+   * Scrapes a bookmark's page and saves its metadata.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<boolean>} Whether the metadata was saved.
+   */
+  async scrapeMetadata(id) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.metadata(repo.getCurrent(), id), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error scraping metadata:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to scrape metadata: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Discards the cached favicon of a bookmark and fetches it again.
+   * @async
+   * @param {string} id The bookmark ID.
+   * @returns {Promise<object|false>} The updated bookmark.
+   */
+  async refreshFavicon(id) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.favicon(repo.getCurrent(), id), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error refreshing favicon:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to refresh favicon: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Starts refreshing the favicons of every bookmark of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<boolean>} Whether the refresh started.
+   */
+  async refreshFavicons(dbName) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.refreshFavicons(dbName), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+      });
+
+      if (!res.ok) {
+        const data = await res.json();
+        console.error("Error refreshing favicons:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to refresh favicons: ${error.message}`);
+      return false;
+    }
+  },
+
+  async postRedirects(url, selection) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(url, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(selection),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error updating redirects:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to update redirects: ${error.message}`);
+    }
+  },
+
+  /**
+   * Runs a tag operation on a database, or previews it.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {string} op The operation: rename, merge, delete or retag.
+   * @param {object} body The tags, target and selected bookmarks of the operation.
+   * @returns {Promise<object|false>} The affected bookmarks and tag counts.
+   */
+  async tagsOp(dbName, op, body) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.tagsOp(dbName, op), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(body),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error updating tags:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to update tags: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Runs an operation on the selected bookmarks, or on the ones matching a filter.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{op: string, ids?: number[], filter?: object, tags?: string[], repo?: string}} body The operation and its selection.
+   * @returns {Promise<{op: string, total: number, succeeded: number, failed: number, items: Array<{id: number, ok: boolean, error?: string}>}|false>} The outcome on each bookmark.
+   */
+  async bulk(dbName, body) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.bulk(dbName), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(body),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error running bulk operation:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to run bulk operation: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Merges duplicated bookmarks into one, deleting the others.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{ids: number[], keep?: number}} selection The duplicates and the one to keep, the richest by default.
+   * @returns {Promise<{bookmark: object, deleted: number[]}|false>} The merged bookmark and the deleted IDs.
+   */
+  async mergeDuplicates(dbName, selection) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.mergeDuplicates(dbName), {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(selection),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error merging duplicates:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to merge duplicates: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Suggests existing tags for the page being saved.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{url: string, title: string, desc: string, tags: string}} page The page and the tags already chosen.
+   * @returns {Promise<Array<{tag: string, score: number, reasons: string[]}>>} The suggestions, best first.
+   */
+  async suggestTags(dbName, page) {
+    try {
+      const res = await fetch(routes.api.suggestTags(dbName, page));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error suggesting tags:", res.status, res.statusText, data.error);
+        return [];
+      }
+
+      return data || [];
+    } catch (error) {
+      console.error(`Failed to suggest tags: ${error.message}`);
+      return [];
+    }
+  },
+
+  /**
+   * Returns the tag normalisation rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<{lowercase?: boolean, max_length?: number, charset?: string}|false>} The rules.
+   */
+  async getTagRules(dbName) {
+    try {
+      const res = await fetch(routes.api.tagRules(dbName));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error loading tag rules:", res.status, res.statusText, data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to load tag rules: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Replaces the tag normalisation rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{lowercase: boolean, max_length: number, charset: string}} rules The rules.
+   * @returns {Promise<boolean>} True on success.
+   */
+  async setTagRules(dbName, rules) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.tagRules(dbName), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(rules),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error saving tag rules:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to save tag rules: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Returns the URL cleaning rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<object|false>} The rules.
+   */
+  async getURLRules(dbName) {
+    try {
+      const res = await fetch(routes.api.urlRules(dbName));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error loading url rules:", res.status, res.statusText, data.error);
+        return false;
+      }
+
+      return data;
+    } catch (error) {
+      console.error(`Failed to load url rules: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Replaces the URL cleaning rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {{strip_tracking: boolean, strip_params: string[], force_https: boolean, resolve_shorteners: boolean, shorteners: string[]}} rules The rules.
+   * @returns {Promise<boolean>} True on success.
+   */
+  async setURLRules(dbName, rules) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.urlRules(dbName), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify(rules),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error saving url rules:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to save url rules: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Returns the URL a bookmark was saved with before it was cleaned.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {number|string} id The bookmark ID.
+   * @returns {Promise<{url: string, cleaned_at: string}|null>} The original URL, or null when kept as saved.
+   */
+  async originalURL(dbName, id) {
+    try {
+      const res = await fetch(routes.api.originalURL(dbName, id));
+      if (!res.ok) return null;
+
+      return await res.json();
+    } catch (error) {
+      console.error(`Failed to load original url: ${error.message}`);
+      return null;
+    }
+  },
+
+  /**
+   * Returns the tag alias rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @returns {Promise<Object<string, string>|false>} The tag each alias is replaced with.
+   */
+  async getTagAliases(dbName) {
+    try {
+      const res = await fetch(routes.api.tagAliases(dbName));
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error loading tag aliases:", res.status, res.statusText, data.error);
+        return false;
+      }
+
+      return data.aliases;
+    } catch (error) {
+      console.error(`Failed to load tag aliases: ${error.message}`);
+      return false;
+    }
+  },
+
+  /**
+   * Replaces the tag alias rules of a database.
+   * @async
+   * @param {string} dbName The database name.
+   * @param {Object<string, string>} aliases The tag each alias is replaced with.
+   * @returns {Promise<boolean>} True on success.
+   */
+  async setTagAliases(dbName, aliases) {
+    if (!config.security.csrfToken() && !window.DEV_MODE) {
+      console.error("Error: CSRF token is missing. The request was not sent.");
+      alert("An internal error occurred. Please refresh the page and try again.");
+      return false;
+    }
+
+    try {
+      const res = await fetch(routes.api.tagAliases(dbName), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+          "X-CSRF-Token": config.security.csrfToken(),
+        },
+        body: JSON.stringify({ aliases }),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        console.error("Error saving tag aliases:", res.status, res.statusText, data.error);
+        alert(data.error);
+        return false;
+      }
+
+      return true;
+    } catch (error) {
+      console.error(`Failed to save tag aliases: ${error.message}`);
+      return false;
+    }
+  },
+
   async shutdown() {
     try {
       const res = await fetch(routes.api.shutdown, {
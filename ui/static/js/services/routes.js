@@ -15,17 +15,42 @@ import config from "../config.js";
  * @property {(db: string) => string} importRepoGpg - Import repo with GPG verification.
  * @property {(db: string) => string} listBookmarks - Fetch all bookmarks.
  * @property {(db: string) => string} listTags - Fetch all tags.
+ * @property {(db: string, op: string) => string} tagsOp - Rename, merge, delete tags or retag bookmarks.
+ * @property {(db: string) => string} tagAliases - Get or set the tag alias rules of a database.
+ * @property {(db: string) => string} tagRules - Get or set the tag normalisation rules of a database.
+ * @property {(db: string) => string} urlRules - Get or set the URL cleaning rules of a database.
+ * @property {(db: string, params: object) => string} suggestTags - Suggest tags for a page.
+ * @property {(db: string) => string} bulk - Run an operation on many bookmarks.
+ * @property {(db: string) => string} duplicates - List the bookmarks saved more than once.
+ * @property {(db: string) => string} mergeDuplicates - Merge duplicated bookmarks into one.
  * @property {(db: string) => string} createBookmark - Create a new bookmark.
  * @property {(db: string, id: string) => string} toggleFavorite - Mark a bookmark as favorite.
  * @property {(db: string, id: string) => string} recordVisit - Record a bookmark visit.
  * @property {(db: string, id: string) => string} updateBookmark - Update a bookmark.
  * @property {(db: string, id: string) => string} updateNotes - Update a bookmark's notes.
+ * @property {(db: string, id: string) => string} bookmarkHistory - List a bookmark's revisions.
+ * @property {(db: string, id: string, rev: string) => string} restoreRevision - Restore a bookmark revision.
+ * @property {(db: string, id: string) => string} snapshots - List or save a bookmark's snapshots.
+ * @property {(db: string, id: string, sid: string) => string} snapshot - Delete a bookmark snapshot.
+ * @property {(db: string, id: string) => string} wayback - Look up or save a bookmark's Wayback Machine snapshot.
+ * @property {(db: string, id: string) => string} article - Get or extract a bookmark's article.
+ * @property {(db: string, id: string) => string} metadata - Get or scrape a bookmark's page metadata.
+ * @property {(db: string, id: string) => string} favicon - Refresh a bookmark's favicon.
+ * @property {(db: string, id: string) => string} originalURL - URL a bookmark was saved with before cleaning.
  * @property {(db: string, id: string) => string} deleteBookmark - Delete a bookmark.
  * @property {(db: string, id: string) => string} updateStatus - Get bookmark status.
  * @property {(db: string, id: string) => string} getBookmarkById - Get a bookmark by ID.
  * @property {(db: string) => string} getDbInfo - Get database info.
  * @property {(db: string) => string} createDb - Create a new database.
  * @property {(db: string) => string} deleteDb - Delete a database.
+ * @property {(db: string) => string} maintainDb - Run integrity check and vacuum on a database.
+ * @property {(db: string) => string} readOnlyDb - Set or clear the read-only mode of a database.
+ * @property {(db: string) => string} linkCheck - Link check status and trigger.
+ * @property {(db: string) => string} refreshFavicons - Refresh the favicons of a database.
+ * @property {(db: string) => string} deadLinks - List the dead links of a database.
+ * @property {(db: string) => string} redirects - List the moved links of a database.
+ * @property {(db: string) => string} acceptRedirects - Accept URL rewrites.
+ * @property {(db: string) => string} dismissRedirects - Dismiss URL rewrites.
  * @property {string} listDatabases - List available databases.
  * @property {string} getAllDbInfo - Get info about all databases.
  */
@@ -47,11 +72,28 @@ const API = {
   // Bookmark/Record Endpoints
   listBookmarks: (db) => `${API_BASE_PATH}/${db}/bookmarks/all`,
   listTags: (db) => `${API_BASE_PATH}/${db}/bookmarks/tags`,
+  tagsOp: (db, op) => `${API_BASE_PATH}/${db}/bookmarks/tags/${op}`,
+  tagAliases: (db) => `${API_BASE_PATH}/${db}/tags/aliases`,
+  tagRules: (db) => `${API_BASE_PATH}/${db}/tags/rules`,
+  urlRules: (db) => `${API_BASE_PATH}/${db}/urls/rules`,
+  suggestTags: (db, params) => `${API_BASE_PATH}/${db}/bookmarks/suggest-tags?${new URLSearchParams(params)}`,
+  bulk: (db) => `${API_BASE_PATH}/${db}/bookmarks/bulk`,
+  duplicates: (db) => `${API_BASE_PATH}/${db}/bookmarks/duplicates`,
+  mergeDuplicates: (db) => `${API_BASE_PATH}/${db}/bookmarks/duplicates/merge`,
   createBookmark: (db) => `${API_BASE_PATH}/${db}/bookmarks/new`,
   toggleFavorite: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/favorite`,
   recordVisit: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/visit`,
   updateBookmark: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/update`,
   updateNotes: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/notes`,
+  bookmarkHistory: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/history`,
+  restoreRevision: (db, id, rev) => `${API_BASE_PATH}/${db}/bookmarks/${id}/history/${rev}/restore`,
+  snapshots: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/snapshots`,
+  snapshot: (db, id, sid) => `${API_BASE_PATH}/${db}/bookmarks/${id}/snapshots/${sid}`,
+  wayback: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/wayback`,
+  article: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/article`,
+  metadata: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/metadata`,
+  favicon: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/favicon`,
+  originalURL: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/original-url`,
   deleteBookmark: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/delete`,
   updateStatus: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}/status`,
   getBookmarkById: (db, id) => `${API_BASE_PATH}/${db}/bookmarks/${id}`,
@@ -60,6 +102,14 @@ const API = {
   getDbInfo: (db) => `${API_BASE_PATH}/${db}/info`,
   createDb: (db) => `${API_BASE_PATH}/${db}/new`,
   deleteDb: (db) => `${API_BASE_PATH}/${db}/delete`,
+  maintainDb: (db) => `${API_BASE_PATH}/${db}/maintenance`,
+  readOnlyDb: (db) => `${API_BASE_PATH}/${db}/readonly`,
+  linkCheck: (db) => `${API_BASE_PATH}/${db}/linkcheck`,
+  refreshFavicons: (db) => `${API_BASE_PATH}/${db}/favicons/refresh`,
+  deadLinks: (db) => `${API_BASE_PATH}/${db}/bookmarks/dead`,
+  redirects: (db) => `${API_BASE_PATH}/${db}/bookmarks/redirects`,
+  acceptRedirects: (db) => `${API_BASE_PATH}/${db}/bookmarks/redirects/accept`,
+  dismissRedirects: (db) => `${API_BASE_PATH}/${db}/bookmarks/redirects/dismiss`,
   // Database Management Endpoints
   listDatabases: `${API_BASE_PATH}/repo/list`,
   getAllDbInfo: `${API_BASE_PATH}/repo/all`,
@@ -71,6 +121,9 @@ const API = {
  * @property {(db: string) => string} createBookmarkPage - Web route to add a new bookmark.
  * @property {(db: string, id: string) => string} viewBookmark - Web route to view a single bookmark.
  * @property {(db: string, id: string) => string} viewQrCode - Web route for bookmark QR code.
+ * @property {(db: string, id: string) => string} readBookmark - Web route for the reader view of a bookmark.
+ * @property {(db: string) => string} deadLinks - Web route for the dead links report.
+ * @property {(key: string, size: string) => string} preview - Cached preview image of a bookmark.
  * @property {string} changeTheme - Web route for changing theme.
  */
 
@@ -82,6 +135,9 @@ const WEB = {
   createBookmarkPage: (db) => `${WEB_BASE_PATH}/${db}/bookmarks/new`,
   viewBookmark: (db, id) => `${WEB_BASE_PATH}/${db}/bookmarks/view/${id}`,
   viewQrCode: (db, id) => `${WEB_BASE_PATH}/${db}/bookmarks/qr/${id}`,
+  readBookmark: (db, id) => `${WEB_BASE_PATH}/${db}/bookmarks/read/${id}`,
+  deadLinks: (db) => `${WEB_BASE_PATH}/${db}/bookmarks/dead`,
+  preview: (key, size) => `/cache/preview/${key}-${size}.jpg`,
   changeTheme: `${WEB_BASE_PATH}/theme/change`,
 };
 